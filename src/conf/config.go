@@ -7,8 +7,10 @@ package conf
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -28,12 +30,33 @@ type AppConfig struct {
 	Web struct {
 		Port int `yaml:"port"`
 	} `yaml:"web"`
+	Chrome struct {
+		// MaxConcurrency limits the number of pages rendered simultaneously, across all endpoints.
+		MaxConcurrency int `yaml:"max_concurrency"`
+		// RemoteUrl is the DevTools endpoint of an already-running Chrome, e.g. “ws://chrome:9222” or
+		// “http://chrome:9222”. If set, no local Chrome is launched, and ExecPath & Flags are ignored.
+		RemoteUrl string `yaml:"remote_url"`
+		// ExecPath is the path to the Chrome binary to launch; found automatically if empty.
+		ExecPath string `yaml:"exec_path"`
+		// Flags are additional command-line flags for a locally-launched Chrome, e.g. “--disable-dev-shm-usage”.
+		Flags []string `yaml:"flags"`
+	} `yaml:"chrome"`
 	Dashboard struct {
 		Username   string     `yaml:"username"`
 		Password   string     `yaml:"password"`
 		Pagination pagination `yaml:"pagination"`
 	} `yaml:"dashboard"`
-	Logs struct {
+	Domains struct {
+		Credentials struct {
+			// EncryptionKey is used to encrypt per-domain credentials, and Wi-Fi QR Codes, at rest; neither can be
+			// stored or used until this is set.
+			EncryptionKey string `yaml:"encryption_key"`
+		} `yaml:"credentials"`
+	} `yaml:"domains"`
+	// UrlCanonicalization is applied to every URL before it is cached or recorded, so that trivially-different
+	// URLs for the same page share a single cached copy. Pages are still fetched using the URL as requested.
+	UrlCanonicalization UrlCanonicalization `yaml:"url-canonicalization"`
+	Logs                struct {
 		Retention  time.Duration `yaml:"retention"`
 		Pagination pagination    `yaml:"pagination"`
 	} `yaml:"logs"`
@@ -46,21 +69,196 @@ type AppConfig struct {
 			TTL          time.Duration `yaml:"ttl"`
 			MaxSizeBytes int64         `yaml:"max_size_bytes"`
 		} `yaml:"cache"`
+		ReferenceCheck struct {
+			// TTL is how long the verdict of a reference check is reused, before the page is fetched again.
+			TTL time.Duration `yaml:"ttl"`
+			// BaseUrl is the public URL of this server, e.g. “https://butterfly.example.com”, which pages must
+			// reference. If empty, the Host of each request is used.
+			BaseUrl string `yaml:"base_url"`
+		} `yaml:"reference_check"`
+		History struct {
+			// Versions is the number of recent renders kept for each Link Preview, so that a broken design
+			// can be rolled back from the Dashboard. Set to 0 to disable history.
+			Versions *int `yaml:"versions"`
+		} `yaml:"history"`
 	} `yaml:"link-previews"`
+	Screenshots struct {
+		Viewport struct {
+			Width  int `yaml:"width"`
+			Height int `yaml:"height"`
+		} `yaml:"viewport"`
+		// MaxHeight caps the height of full-page & clipped screenshots, to bound memory & file sizes.
+		MaxHeight int `yaml:"max_height"`
+	} `yaml:"screenshots"`
+	Pdfs struct {
+		Render struct {
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"render"`
+		Cache struct {
+			Enabled      *bool         `yaml:"enabled"`
+			TTL          time.Duration `yaml:"ttl"`
+			MaxSizeBytes int64         `yaml:"max_size_bytes"`
+		} `yaml:"cache"`
+	} `yaml:"pdfs"`
+	ImageProcessing struct {
+		// Pipelines are named sequences of steps, applied to Link Previews before they are served & cached.
+		Pipelines map[string][]ImageStep `yaml:"pipelines"`
+		// Default is the name of the pipeline applied to domains that do not select one of their own.
+		Default string `yaml:"default"`
+	} `yaml:"image-processing"`
+	Compression struct {
+		// MinQuality is the lowest quality, from 0 to 100, at which a PNG may be reduced to a palette of
+		// 256 colors; otherwise, it is compressed losslessly. Set to 100 to always keep images lossless.
+		MinQuality int `yaml:"min_quality"`
+	} `yaml:"compression"`
+	Background struct {
+		// Workers limits the number of background tasks (compression, cache writes, etc.) run simultaneously.
+		Workers int `yaml:"workers"`
+		// QueueSize limits the number of pending tasks; new tasks are dropped while the queue is full.
+		QueueSize int `yaml:"queue_size"`
+		// Retries is the number of times a failed task is retried, after waiting for RetryDelay.
+		Retries    *int          `yaml:"retries"`
+		RetryDelay time.Duration `yaml:"retry_delay"`
+		// DrainTimeout is how long to wait for pending tasks to finish when shutting down.
+		DrainTimeout time.Duration `yaml:"drain_timeout"`
+	} `yaml:"background"`
+	SizeBudgets struct {
+		// Presets are named byte budgets for Link Previews, e.g. “whatsapp: 300000”, since some platforms
+		// reject or downscale larger images. Selected per domain, or per request using “&budget={preset}”.
+		Presets map[string]int64 `yaml:"presets"`
+		// Default is the name of the preset applied to domains that do not select one of their own.
+		Default string `yaml:"default"`
+	} `yaml:"size-budgets"`
 	QrCodes struct {
 		Cache struct {
 			Enabled      *bool         `yaml:"enabled"`
 			TTL          time.Duration `yaml:"ttl"`
 			MaxSizeBytes int64         `yaml:"max_size_bytes"`
 		} `yaml:"cache"`
+		ShortLinks struct {
+			// BaseUrl is the public URL of this server, e.g. “https://butterfly.example.com”, used in short links
+			// encoded in tracked QR Codes. Tracked QR Codes cannot be created unless it is set.
+			BaseUrl string `yaml:"base_url"`
+		} `yaml:"short_links"`
 	} `yaml:"qr-codes"`
+	Github struct {
+		// Fields are added to, or replace, [DefaultGithubFields], keyed by the {type} in
+		// “/github/v1/{user}/{repo}/{type}”. A field set to null is removed.
+		Fields map[string]GithubField `yaml:"fields"`
+	} `yaml:"github"`
 	Debug bool `yaml:"debug"`
 }
 
+// GithubField selects a value about a repository from the GitHub API. In butterfly.yml, a field may also be
+// written as just its path, e.g. “stars: stargazers_count”.
+type GithubField struct {
+	// Path is a dot-separated path into the JSON response, e.g. “license.spdx_id”, or “topics.0” for the
+	// first element of an array. Arrays are joined with “, ”.
+	Path string `yaml:"path"`
+	// Source is “repo” (default) for the repository itself, or “release” for its latest release.
+	Source string `yaml:"source,omitempty"`
+	// Format derives a value from a timestamp: “date” for just the date, as “2006-01-02”, or “days_since” for
+	// the number of whole days since then.
+	Format string `yaml:"format,omitempty"`
+}
+
+func (f *GithubField) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&f.Path)
+	}
+	type plain GithubField // Without this method, to avoid infinite recursion.
+	return value.Decode((*plain)(f))
+}
+
+// DefaultGithubFields are available unless configured otherwise.
+var DefaultGithubFields = map[string]GithubField{
+	"name":            {Path: "name"},
+	"description":     {Path: "description"},
+	"stars":           {Path: "stargazers_count"},
+	"forks":           {Path: "forks_count"},
+	"issues":          {Path: "open_issues_count"},
+	"watchers":        {Path: "subscribers_count"},
+	"release":         {Path: "tag_name", Source: "release"},
+	"release_date":    {Path: "published_at", Source: "release", Format: "date"},
+	"license":         {Path: "license.spdx_id"},
+	"language":        {Path: "language"},
+	"topics":          {Path: "topics"},
+	"default_branch":  {Path: "default_branch"},
+	"pushed":          {Path: "pushed_at", Format: "date"},
+	"days_since_push": {Path: "pushed_at", Format: "days_since"},
+	"archived":        {Path: "archived"},
+	"avatar":          {Path: "owner.avatar_url"},
+}
+
+// UrlCanonicalization is a policy for normalizing URLs; nil fields are treated as enabled.
+type UrlCanonicalization struct {
+	LowercaseHost     *bool `yaml:"lowercase_host"`
+	StripDefaultPorts *bool `yaml:"strip_default_ports"` // :80 for http, :443 for https.
+	StripFragments    *bool `yaml:"strip_fragments"`
+	SortQuery         *bool `yaml:"sort_query"`
+	// TrackingParams are removed from query strings; a trailing “*” matches any suffix, as in “utm_*”.
+	TrackingParams []string `yaml:"tracking_params"`
+	// TrailingSlash is “strip” to remove trailing slashes from paths, “add” to always add one, or “keep”.
+	TrailingSlash string `yaml:"trailing_slash"`
+	// FollowCanonical fetches each page, and uses the URL in its <link rel="canonical"> instead, as long as
+	// that URL is on an authorized domain.
+	FollowCanonical bool `yaml:"follow_canonical"`
+}
+
+// DefaultTrackingParams are removed from URLs unless configured otherwise.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid"}
+
 type pagination struct {
 	Limit int `yaml:"limit"`
 }
 
+// ImageStep is a single step of an image processing pipeline; exactly one of its fields must be set.
+type ImageStep struct {
+	Overlay *OverlayStep `yaml:"overlay,omitempty"`
+	Badge   *BadgeStep   `yaml:"badge,omitempty"`
+	Pad     *PadStep     `yaml:"pad,omitempty"`
+	Round   *RoundStep   `yaml:"round,omitempty"`
+	Fit     *FitStep     `yaml:"fit,omitempty"`
+}
+
+// OverlayStep draws an image from a file (e.g. a logo) on top of the preview.
+type OverlayStep struct {
+	File     string  `yaml:"file"`     // Relative to the directory containing butterfly.yml.
+	Position string  `yaml:"position"` // top-left, top-right, bottom-left, bottom-right (default), or center.
+	Margin   int     `yaml:"margin"`   // Distance from the edges, in pixels.
+	Width    int     `yaml:"width"`    // Scales the image to this width, preserving its aspect ratio; 0 to keep as-is.
+	Opacity  float64 `yaml:"opacity"`  // From 0 to 1 (default).
+}
+
+// BadgeStep stamps a short text label, such as “DRAFT”, on top of the preview.
+type BadgeStep struct {
+	Text       string `yaml:"text"`
+	Position   string `yaml:"position"`   // Same as [OverlayStep.Position]; defaults to top-right.
+	Margin     int    `yaml:"margin"`     // Distance from the edges, in pixels.
+	Size       int    `yaml:"size"`       // Font size, in pixels.
+	Color      string `yaml:"color"`      // Text color, as “#rrggbb” or “#rrggbbaa”.
+	Background string `yaml:"background"` // Badge color, as “#rrggbb” or “#rrggbbaa”.
+}
+
+// PadStep adds a solid border around the preview.
+type PadStep struct {
+	Size  int    `yaml:"size"`  // Width of the border on each side, in pixels.
+	Color string `yaml:"color"` // As “#rrggbb” or “#rrggbbaa”.
+}
+
+// RoundStep makes the corners of the preview transparent, so that they appear rounded.
+type RoundStep struct {
+	Radius int `yaml:"radius"`
+}
+
+// FitStep resizes the preview to an exact size.
+type FitStep struct {
+	Width      int    `yaml:"width"`
+	Height     int    `yaml:"height"`
+	Mode       string `yaml:"mode"`       // “cover” (default) crops to fill; “contain” letterboxes instead.
+	Background string `yaml:"background"` // Letterbox color for “contain”, as “#rrggbb” or “#rrggbbaa”.
+}
+
 var configYmlPath string
 
 func ReadConfig(configYmlFile string) (AppConfig, error) {
@@ -97,10 +295,33 @@ func setDefaultsAndPrint(c *AppConfig) {
 	if c.Web.Port == 0 {
 		c.Web.Port = 9999
 	}
+	if c.Chrome.MaxConcurrency == 0 {
+		c.Chrome.MaxConcurrency = runtime.NumCPU()
+	}
 	if c.Dashboard.Pagination.Limit == 0 {
 		c.Dashboard.Pagination.Limit = 30
 	}
 
+	if c.Compression.MinQuality == 0 {
+		c.Compression.MinQuality = 70
+	}
+	if c.Background.Workers == 0 {
+		c.Background.Workers = runtime.NumCPU()
+	}
+	if c.Background.QueueSize == 0 {
+		c.Background.QueueSize = c.Background.Workers * 16
+	}
+	if c.Background.Retries == nil {
+		retries := 2
+		c.Background.Retries = &retries
+	}
+	if c.Background.RetryDelay == 0 {
+		c.Background.RetryDelay = 1 * time.Second
+	}
+	if c.Background.DrainTimeout == 0 {
+		c.Background.DrainTimeout = 30 * time.Second
+	}
+
 	// Cache for Link Previews is enabled by default; only disable it when testing or debugging.
 	if c.LinkPreviews.Cache.Enabled == nil {
 		enabled := true
@@ -112,6 +333,35 @@ func setDefaultsAndPrint(c *AppConfig) {
 	if c.LinkPreviews.Screenshot.Timeout == 0 {
 		c.LinkPreviews.Screenshot.Timeout = 20 * time.Second
 	}
+	if c.LinkPreviews.ReferenceCheck.TTL == 0 {
+		c.LinkPreviews.ReferenceCheck.TTL = 1 * time.Hour
+	}
+	if c.LinkPreviews.History.Versions == nil {
+		versions := 5
+		c.LinkPreviews.History.Versions = &versions
+	}
+
+	if c.Screenshots.Viewport.Width == 0 {
+		c.Screenshots.Viewport.Width = 1280
+	}
+	if c.Screenshots.Viewport.Height == 0 {
+		c.Screenshots.Viewport.Height = 800
+	}
+	if c.Screenshots.MaxHeight == 0 {
+		c.Screenshots.MaxHeight = 10000
+	}
+
+	// Cache for PDFs is enabled by default; only disable it when testing or debugging.
+	if c.Pdfs.Cache.Enabled == nil {
+		enabled := true
+		c.Pdfs.Cache.Enabled = &enabled
+	}
+	if c.Pdfs.Cache.MaxSizeBytes == 0 {
+		c.Pdfs.Cache.MaxSizeBytes = 1 * 1024 * 1024 * 1024 // 1GB
+	}
+	if c.Pdfs.Render.Timeout == 0 {
+		c.Pdfs.Render.Timeout = 30 * time.Second
+	}
 
 	// Cache for QR Codes is enabled by default; only disable it when testing or debugging.
 	if c.QrCodes.Cache.Enabled == nil {
@@ -122,6 +372,39 @@ func setDefaultsAndPrint(c *AppConfig) {
 		c.QrCodes.Cache.MaxSizeBytes = 1 * 1024 * 1024 * 1024 // 1GB
 	}
 
+	if c.UrlCanonicalization.LowercaseHost == nil {
+		enabled := true
+		c.UrlCanonicalization.LowercaseHost = &enabled
+	}
+	if c.UrlCanonicalization.StripDefaultPorts == nil {
+		enabled := true
+		c.UrlCanonicalization.StripDefaultPorts = &enabled
+	}
+	if c.UrlCanonicalization.StripFragments == nil {
+		enabled := false // Single-page apps route using fragments, e.g. “/#/settings”.
+		c.UrlCanonicalization.StripFragments = &enabled
+	}
+	if c.UrlCanonicalization.SortQuery == nil {
+		enabled := true
+		c.UrlCanonicalization.SortQuery = &enabled
+	}
+	if c.UrlCanonicalization.TrackingParams == nil { // An empty list in butterfly.yml keeps all parameters.
+		c.UrlCanonicalization.TrackingParams = DefaultTrackingParams
+	}
+	if c.UrlCanonicalization.TrailingSlash == "" {
+		c.UrlCanonicalization.TrailingSlash = "keep" // Not every server redirects between “/path” & “/path/”.
+	}
+
+	fields := maps.Clone(DefaultGithubFields)
+	for name, field := range c.Github.Fields {
+		if field.Path == "" {
+			delete(fields, name)
+		} else {
+			fields[name] = field
+		}
+	}
+	c.Github.Fields = fields
+
 	if c.Logs.Retention == 0 {
 		c.Logs.Retention = 30 * 24 * time.Hour
 	}
@@ -130,14 +413,56 @@ func setDefaultsAndPrint(c *AppConfig) {
 	}
 
 	// Print warnings for unsafe settings, just as FYI.
-	yaml, _ := yaml.Marshal(*c)
+	// Secrets are redacted, since this output typically ends up in container logs.
+	printable := *c
+	if printable.Domains.Credentials.EncryptionKey != "" {
+		printable.Domains.Credentials.EncryptionKey = "[redacted]"
+	}
+	yaml, _ := yaml.Marshal(printable)
 	fmt.Println(string(yaml))
 	if c.Debug {
 		slog.Warn("Debug mode is enabled")
 	}
 
+	if name := c.ImageProcessing.Default; name != "" {
+		if _, ok := c.ImageProcessing.Pipelines[name]; !ok {
+			slog.Warn("Default image processing pipeline not found; no processing will be applied", "pipeline", name)
+		}
+	}
+
+	if name := c.SizeBudgets.Default; name != "" {
+		if _, ok := c.SizeBudgets.Presets[name]; !ok {
+			slog.Warn("Default size budget preset not found; no budget will be applied", "preset", name)
+		}
+	}
+
+	switch c.UrlCanonicalization.TrailingSlash {
+	case "strip", "add", "keep":
+	default:
+		slog.Warn("Unknown trailing_slash setting; trailing slashes will be kept", "trailing_slash", c.UrlCanonicalization.TrailingSlash)
+	}
+
+	for name, field := range c.Github.Fields {
+		switch field.Source {
+		case "", "repo", "release":
+		default:
+			slog.Warn("Unknown source for GitHub field; it will be read from the repository", "field", name, "source", field.Source)
+		}
+		switch field.Format {
+		case "", "date", "days_since":
+		default:
+			slog.Warn("Unknown format for GitHub field; its value will be served as-is", "field", name, "format", field.Format)
+		}
+	}
+
 	if !*c.LinkPreviews.Cache.Enabled {
 		slog.Warn("Screenshot cache disabled for Link Previews; performance will be affected")
+		if *c.LinkPreviews.History.Versions > 0 {
+			slog.Warn("History of Link Previews requires the screenshot cache; no versions will be kept")
+		}
+	}
+	if !*c.Pdfs.Cache.Enabled {
+		slog.Warn("Cache disabled for PDFs; performance will be affected")
 	}
 	if !*c.QrCodes.Cache.Enabled {
 		slog.Warn("Cache disabled for QR Codes; performance will be affected")
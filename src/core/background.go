@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lmittmann/tint"
+)
+
+// Background work (compressing images, writing caches, etc.) runs on a fixed number of workers, fed by a
+// bounded queue, instead of a goroutine per request, which could otherwise pile up without limit under a
+// burst of traffic. Since cached items are regenerated on the next request if needed, tasks that do not
+// fit in the queue are dropped, rather than holding up requests.
+
+// Background is the shared pipeline for all background work; set up in main().
+var Background *Pipeline
+
+// ErrQueueFull is returned when a task cannot be queued because the queue is full, or is shutting down.
+var ErrQueueFull = errors.New("background queue is full")
+
+// Task is a unit of background work. Tasks that return an error are retried, up to the pipeline’s limit.
+type Task func() error
+
+// Pipeline runs [Task]s on a fixed number of workers.
+type Pipeline struct {
+	workers    int
+	retries    int
+	retryDelay time.Duration
+
+	queue   chan queuedTask
+	mu      sync.RWMutex // Guards closed, so that tasks are never sent on a closed queue.
+	closed  bool
+	running sync.WaitGroup
+
+	processed      atomic.Int64
+	failed         atomic.Int64
+	retried        atomic.Int64
+	dropped        atomic.Int64
+	processingTime atomic.Int64 // Total, in nanoseconds.
+}
+
+type queuedTask struct {
+	name string
+	task Task
+}
+
+// PipelineOption configures the Pipeline.
+type PipelineOption func(*Pipeline)
+
+// WithRetries retries failed tasks up to n times, waiting for delay before each retry.
+func WithRetries(n int, delay time.Duration) PipelineOption {
+	return func(p *Pipeline) {
+		p.retries = n
+		p.retryDelay = delay
+	}
+}
+
+// NewPipeline starts a Pipeline with the given number of workers, and room for queueSize pending tasks.
+func NewPipeline(workers, queueSize int, opts ...PipelineOption) *Pipeline {
+	p := &Pipeline{
+		workers: max(workers, 1),
+		queue:   make(chan queuedTask, max(queueSize, 0)),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.running.Add(p.workers)
+	for range p.workers {
+		go func() {
+			defer p.running.Done()
+			for t := range p.queue {
+				p.run(t)
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues a task to run in the background, and returns immediately. If the queue is full, the task
+// is dropped, and false is returned.
+func (p *Pipeline) Submit(name string, task Task) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.closed {
+		select {
+		case p.queue <- queuedTask{name: name, task: task}:
+			return true
+		default:
+		}
+	}
+	p.dropped.Add(1)
+	slog.Warn("background queue full; dropping task", "task", name, "queued", len(p.queue))
+	return false
+}
+
+// Do runs a task on p, sharing its workers with all other background work, and waits for its result, or
+// for ctx to be done. The task is given ctx, so that it can stop early once the caller has gone away, and
+// is skipped if ctx is done before it starts. Results are only handed back via the return values, so tasks
+// must not write to the caller’s variables, which may no longer be in use by the time the task finishes.
+//
+// Returns [ErrQueueFull] without running the task if it could not be queued. Callers serving a request
+// should then respond with HTTP 503 (Service Unavailable), so that the client retries later, instead of
+// running the task inline, which would defeat the bound on background work. The task is not retried,
+// since the caller is waiting for it, and can decide what to do about any error.
+func Do[T any](ctx context.Context, p *Pipeline, name string, task func(context.Context) (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1) // Buffered, so that the task never blocks once the caller has gone away.
+	if !p.Submit(name, func() error {
+		if err := ctx.Err(); err != nil {
+			done <- result{err: err}
+			return nil
+		}
+		value, err := task(ctx)
+		done <- result{value, err}
+		return nil
+	}) {
+		var zero T
+		return zero, ErrQueueFull
+	}
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (p *Pipeline) run(t queuedTask) {
+	start := time.Now()
+	defer func() { p.processingTime.Add(int64(time.Since(start))) }()
+
+	for attempt := 0; ; attempt++ {
+		err := t.task()
+		if err == nil {
+			p.processed.Add(1)
+			return
+		}
+		if attempt >= p.retries {
+			p.failed.Add(1)
+			slog.Error("background task failed", tint.Err(err), "task", t.name, "attempts", attempt+1)
+			return
+		}
+		p.retried.Add(1)
+		time.Sleep(p.retryDelay)
+	}
+}
+
+// Shutdown stops accepting new tasks, and waits for queued & running tasks to finish, or for ctx to be done.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PipelineStats is a snapshot of a Pipeline’s metrics.
+type PipelineStats struct {
+	Workers       int
+	QueueDepth    int
+	QueueCapacity int
+	Processed     int64
+	Failed        int64
+	Retried       int64
+	Dropped       int64
+	// AvgProcessingTime is the mean time taken per task, including retries.
+	AvgProcessingTime time.Duration
+}
+
+func (p *Pipeline) Stats() PipelineStats {
+	stats := PipelineStats{
+		Workers:       p.workers,
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Processed:     p.processed.Load(),
+		Failed:        p.failed.Load(),
+		Retried:       p.retried.Load(),
+		Dropped:       p.dropped.Load(),
+	}
+	if finished := stats.Processed + stats.Failed; finished > 0 {
+		stats.AvgProcessingTime = time.Duration(p.processingTime.Load() / finished)
+	}
+	return stats
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipeline_RetriesFailedTasks(t *testing.T) {
+	p := NewPipeline(1, 4, WithRetries(2, time.Millisecond))
+
+	var attempts atomic.Int32
+	p.Submit("flaky", func() error {
+		if attempts.Add(1) < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	p.Submit("broken", func() error { return errors.New("permanent") })
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("flaky task attempts = %d, want 3", got)
+	}
+	stats := p.Stats()
+	if stats.Processed != 1 || stats.Failed != 1 || stats.Retried != 4 {
+		t.Errorf("Stats() = %+v, want 1 processed, 1 failed, 4 retried", stats)
+	}
+}
+
+func TestPipeline_DropsWhenFull(t *testing.T) {
+	p := NewPipeline(1, 2)
+
+	// Occupy the only worker, so that queued tasks stay queued.
+	started, release := make(chan struct{}), make(chan struct{})
+	p.Submit("blocker", func() error { close(started); <-release; return nil })
+	<-started
+
+	for i := range 2 {
+		if !p.Submit("queued", func() error { return nil }) {
+			t.Fatalf("Submit() dropped task %d, before the queue was full", i)
+		}
+	}
+	if p.Submit("overflow", func() error { return nil }) {
+		t.Error("Submit() accepted a task when the queue was full")
+	}
+	if got := p.Stats().QueueDepth; got != 2 {
+		t.Errorf("Stats().QueueDepth = %d, want 2", got)
+	}
+
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if stats := p.Stats(); stats.Processed != 3 || stats.Dropped != 1 {
+		t.Errorf("Stats() = %+v, want 3 processed, 1 dropped", stats)
+	}
+}
+
+func TestPipeline_ShutdownDrainsQueue(t *testing.T) {
+	p := NewPipeline(2, 10)
+	var ran atomic.Int32
+	for range 10 {
+		p.Submit("slow", func() error {
+			time.Sleep(5 * time.Millisecond)
+			ran.Add(1)
+			return nil
+		})
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got := ran.Load(); got != 10 {
+		t.Errorf("tasks run before shutdown = %d, want 10", got)
+	}
+	if p.Submit("late", func() error { return nil }) {
+		t.Error("Submit() accepted a task after shutdown")
+	}
+}
+
+func TestPipeline_ShutdownTimesOut(t *testing.T) {
+	p := NewPipeline(1, 1)
+	release := make(chan struct{})
+	defer close(release)
+	p.Submit("stuck", func() error { <-release; return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := NewPipeline(1, 1)
+	defer p.Shutdown(context.Background())
+
+	want := errors.New("failed")
+	if _, err := Do(context.Background(), p, "sync", func(context.Context) (int, error) { return 0, want }); !errors.Is(err, want) {
+		t.Errorf("Do() error = %v, want %v", err, want)
+	}
+	got, err := Do(context.Background(), p, "sync", func(context.Context) (int, error) { return 42, nil })
+	if err != nil || got != 42 {
+		t.Errorf("Do() = %d, %v, want 42", got, err)
+	}
+}
+
+func TestDo_SkipsCanceledTasks(t *testing.T) {
+	p := NewPipeline(1, 1)
+	defer p.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var ran atomic.Bool
+	if _, err := Do(ctx, p, "canceled", func(context.Context) (int, error) {
+		ran.Store(true)
+		return 0, nil
+	}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	p.Shutdown(context.Background())
+	if ran.Load() {
+		t.Error("Do() ran a task after its context was canceled")
+	}
+}
+
+func TestDo_StopsWhenCanceled(t *testing.T) {
+	p := NewPipeline(1, 1)
+	defer p.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started, stopped := make(chan struct{}), make(chan error, 1)
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := Do(ctx, p, "long", func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("task saw ctx error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Card is the content of a link preview drawn by [RenderCard].
+type Card struct {
+	Title       string
+	Description string
+	// SiteName is shown in place of the title for pages that do not have one.
+	SiteName string
+}
+
+// Layout of the card, matching the default template (embedfs/default-template.html) in pixels.
+const (
+	cardWidth           = 1200
+	cardMinHeight       = 630
+	cardPaddingX        = 120
+	cardPaddingY        = 60
+	cardTitleSize       = 84
+	cardTitleLineHeight = 101 // CSS “normal” line height, ~1.2em.
+	cardTitleMargin     = 42
+	cardDescSize        = 54
+	cardDescLineHeight  = 81 // 1.5em
+	cardMaxLines        = 3
+	cardShadowOffset    = 2
+	cardShadowBlur      = 2 // Sigma; about half of the CSS blur radius.
+	cardShadowOpacity   = 0.4
+	cardEllipsis        = "…"
+)
+
+var (
+	cardGradientStart = color.NRGBA{R: 0x6a, G: 0x11, B: 0xcb, A: 0xff}
+	cardGradientEnd   = color.NRGBA{R: 0x25, G: 0x75, B: 0xfc, A: 0xff}
+)
+
+// Fonts are parsed once, but faces must be created per render, since they are not safe for concurrent use.
+var (
+	boldFont    = sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(gobold.TTF) })
+	regularFont = sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(goregular.TTF) })
+)
+
+// RenderCard draws a link preview that looks like the default template, without using a browser.
+// It is much faster than [TakeScreenshotWithTemplate], and works even when Chrome is unavailable.
+func RenderCard(card Card) ([]byte, error) {
+	title := strings.TrimSpace(card.Title)
+	if title == "" {
+		title = strings.TrimSpace(card.SiteName)
+	}
+	description := strings.TrimSpace(card.Description)
+
+	titleFace, err := newFace(boldFont, cardTitleSize)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	descFace, err := newFace(regularFont, cardDescSize)
+	if err != nil {
+		return nil, err
+	}
+	defer descFace.Close()
+
+	textWidth := cardWidth - 2*cardPaddingX
+	titleLines := clampLines(wrapText(titleFace, title, textWidth), titleFace, textWidth, cardMaxLines)
+	descLines := clampLines(wrapText(descFace, description, textWidth), descFace, textWidth, cardMaxLines)
+
+	// Like the template’s “min-height”, the card grows to fit its contents.
+	height := 2*cardPaddingY + len(titleLines)*cardTitleLineHeight + len(descLines)*cardDescLineHeight
+	if len(titleLines) > 0 && len(descLines) > 0 {
+		height += cardTitleMargin
+	}
+	height = max(height, cardMinHeight)
+
+	canvas := horizontalGradient(cardWidth, height, cardGradientStart, cardGradientEnd)
+
+	// Text is drawn twice: first as a blurred shadow, then in white on top of it.
+	shadow := image.NewNRGBA(canvas.Bounds())
+	text := image.NewNRGBA(canvas.Bounds())
+	y := cardPaddingY
+	for _, block := range []struct {
+		face       font.Face
+		lines      []string
+		lineHeight int
+		marginTop  int
+	}{
+		{titleFace, titleLines, cardTitleLineHeight, 0},
+		{descFace, descLines, cardDescLineHeight, cardTitleMargin},
+	} {
+		if len(block.lines) == 0 {
+			continue
+		}
+		if y > cardPaddingY {
+			y += block.marginTop
+		}
+		metrics := block.face.Metrics()
+		for _, line := range block.lines {
+			// Center the glyphs vertically within the line box, as CSS does.
+			baseline := y + (block.lineHeight-(metrics.Ascent+metrics.Descent).Ceil())/2 + metrics.Ascent.Ceil()
+			for _, layer := range []struct {
+				dst   draw.Image
+				color color.Color
+			}{
+				{shadow, color.Black},
+				{text, color.White},
+			} {
+				drawer := &font.Drawer{
+					Dst:  layer.dst,
+					Src:  image.NewUniform(layer.color),
+					Face: block.face,
+					Dot:  fixed.P(cardPaddingX, baseline),
+				}
+				drawer.DrawString(line)
+			}
+			y += block.lineHeight
+		}
+	}
+
+	out := imaging.Overlay(canvas, imaging.Blur(shadow, cardShadowBlur), image.Pt(0, cardShadowOffset), cardShadowOpacity)
+	out = imaging.Overlay(out, text, image.Point{}, 1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newFace(parsed func() (*opentype.Font, error), size float64) (font.Face, error) {
+	f, err := parsed()
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func horizontalGradient(width, height int, start, end color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	lerp := func(a, b uint8, t float64) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5) }
+	for x := range width {
+		t := float64(x) / float64(max(width-1, 1))
+		c := color.NRGBA{R: lerp(start.R, end.R, t), G: lerp(start.G, end.G, t), B: lerp(start.B, end.B, t), A: 0xff}
+		for y := range height {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// wrapText breaks text into lines no wider than maxWidth, breaking between words where possible,
+// and within words only when a single word is too wide to fit on a line by itself.
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	limit := fixed.I(maxWidth)
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.MeasureString(face, candidate) <= limit {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+		// Break overlong words at whichever character no longer fits.
+		for font.MeasureString(face, word) > limit {
+			runes := []rune(word)
+			n := 1
+			for n < len(runes) && font.MeasureString(face, string(runes[:n+1])) <= limit {
+				n++
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// clampLines keeps at most maxLines lines, ending the last one with an ellipsis if any text was cut off.
+func clampLines(lines []string, face font.Face, maxWidth, maxLines int) []string {
+	if len(lines) <= maxLines {
+		return lines
+	}
+	lines = lines[:maxLines]
+	last := []rune(lines[maxLines-1])
+	for len(last) > 0 && font.MeasureString(face, string(last)+cardEllipsis) > fixed.I(maxWidth) {
+		last = last[:len(last)-1]
+	}
+	lines[maxLines-1] = strings.TrimRight(string(last), " ") + cardEllipsis
+	return lines
+}
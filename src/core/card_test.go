@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestWrapText(t *testing.T) {
+	face, err := newFace(regularFont, cardDescSize)
+	if err != nil {
+		t.Fatalf("Failed to create font face: %v", err)
+	}
+	defer face.Close()
+
+	const maxWidth = 400
+	text := "The quick brown fox jumps over the lazy dog, then keeps on running across the field"
+	lines := wrapText(face, text, maxWidth)
+	if len(lines) < 2 {
+		t.Fatalf("Expected text to wrap onto multiple lines, got: %q", lines)
+	}
+	for _, line := range lines {
+		if font.MeasureString(face, line) > fixed.I(maxWidth) {
+			t.Errorf("Line is wider than %dpx: %q", maxWidth, line)
+		}
+	}
+	if got := strings.Join(lines, " "); got != text {
+		t.Errorf("Expected wrapped lines to contain the original text, got: %q", got)
+	}
+
+	// Words that cannot fit on a line by themselves are broken.
+	long := strings.Repeat("W", 40)
+	lines = wrapText(face, long, maxWidth)
+	if len(lines) < 2 || strings.Join(lines, "") != long {
+		t.Errorf("Expected long word to be broken across lines, got: %q", lines)
+	}
+
+	if lines := wrapText(face, "   ", maxWidth); len(lines) != 0 {
+		t.Errorf("Expected no lines for blank text, got: %q", lines)
+	}
+}
+
+func TestClampLines(t *testing.T) {
+	face, err := newFace(regularFont, cardDescSize)
+	if err != nil {
+		t.Fatalf("Failed to create font face: %v", err)
+	}
+	defer face.Close()
+
+	lines := []string{"one", "two"}
+	if got := clampLines(lines, face, 400, 3); len(got) != 2 || got[1] != "two" {
+		t.Errorf("Expected lines under the limit to be unchanged, got: %q", got)
+	}
+
+	got := clampLines([]string{"one", "two", "three", "four"}, face, 400, 3)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 lines, got: %q", got)
+	}
+	if got[2] != "three"+cardEllipsis {
+		t.Errorf("Expected last line to end with an ellipsis, got: %q", got[2])
+	}
+}
+
+func TestRenderCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		card       Card
+		wantHeight int
+	}{
+		{"short", Card{Title: "Hello", Description: "World"}, cardMinHeight},
+		{"site name only", Card{SiteName: "example.com"}, cardMinHeight},
+		{
+			"clamped",
+			Card{
+				Title:       strings.Repeat("A very long title that goes on and on ", 10),
+				Description: strings.Repeat("An even longer description that never seems to end ", 10),
+			},
+			2*cardPaddingY + cardMaxLines*cardTitleLineHeight + cardTitleMargin + cardMaxLines*cardDescLineHeight,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := RenderCard(tt.card)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			assertValidPNG(t, data)
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Failed to decode PNG: %v", err)
+			}
+			if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != cardWidth || h != tt.wantHeight {
+				t.Errorf("Expected %dx%d, got %dx%d", cardWidth, tt.wantHeight, w, h)
+			}
+		})
+	}
+}
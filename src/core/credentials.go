@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Credentials grant access to pages that are not publicly accessible, such as password-protected
+// staging sites. They are only ever sent to the host of the page being fetched, never to third-party
+// hosts that the page loads resources from.
+type Credentials struct {
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Cookies  map[string]string `json:"cookies,omitempty"`
+}
+
+// IsEmpty returns true if no credentials of any kind are configured.
+func (c *Credentials) IsEmpty() bool {
+	return c == nil || (c.Username == "" && c.Password == "" && len(c.Headers) == 0 && len(c.Cookies) == 0)
+}
+
+// Summary describes which kinds of credentials are configured, without revealing any of their values.
+func (c *Credentials) Summary() string {
+	if c.IsEmpty() {
+		return "None"
+	}
+	var parts []string
+	if c.Username != "" || c.Password != "" {
+		parts = append(parts, "Basic auth")
+	}
+	if n := len(c.Headers); n > 0 {
+		parts = append(parts, pluralize(n, "header"))
+	}
+	if n := len(c.Cookies); n > 0 {
+		parts = append(parts, pluralize(n, "cookie"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// headers returns all HTTP headers to be added to requests, including `Authorization` for Basic auth.
+func (c *Credentials) headers() map[string]string {
+	headers := make(map[string]string, len(c.Headers)+1)
+	for name, value := range c.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	if c.Username != "" || c.Password != "" {
+		token := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+		headers["Authorization"] = "Basic " + token
+	}
+	return headers
+}
+
+// applyToRequest adds credentials to an outgoing HTTP request made by the Go HTTP client.
+func (c *Credentials) applyToRequest(req *http.Request) {
+	for name, value := range c.headers() {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+}
+
+// scopedClient returns a copy of client that drops credentials when following redirects to another host.
+// The Go HTTP client already strips `Authorization` & `Cookie` headers in that case, but not custom headers.
+func (c *Credentials) scopedClient(client *http.Client, host string) *http.Client {
+	scoped := *client
+	headers := c.headers()
+	scoped.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		if !strings.EqualFold(req.URL.Hostname(), host) {
+			for name := range headers {
+				req.Header.Del(name)
+			}
+			req.Header.Del("Cookie")
+		}
+		return nil
+	}
+	return &scoped
+}
+
+// chromedpActions returns actions to be run before navigating to pageUrl, so that Chrome sends these
+// credentials with every request made to the page’s host. Requests are intercepted instead of using
+// [network.SetExtraHTTPHeaders] so that secrets are not leaked to CDNs, analytics, and other hosts.
+func (c *Credentials) chromedpActions(ctx context.Context, pageUrl string) ([]chromedp.Action, error) {
+	u, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	headers := c.headers()
+	hasBasicAuth := c.Username != "" || c.Password != ""
+
+	chromedp.ListenTarget(ctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go func() {
+				continueReq := fetch.ContinueRequest(ev.RequestID)
+				if isSameHost(ev.Request.URL, host) {
+					continueReq = continueReq.WithHeaders(mergeHeaders(ev.Request.Headers, headers))
+				}
+				_ = continueReq.Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target))
+			}()
+
+		case *fetch.EventAuthRequired:
+			go func() {
+				response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+				if ev.AuthChallenge.Source != fetch.AuthChallengeSourceProxy && isSameHost(ev.Request.URL, host) {
+					response = &fetch.AuthChallengeResponse{
+						Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+						Username: c.Username,
+						Password: c.Password,
+					}
+				}
+				_ = fetch.ContinueWithAuth(ev.RequestID, response).Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target))
+			}()
+		}
+	})
+
+	actions := []chromedp.Action{
+		fetch.Enable().WithHandleAuthRequests(hasBasicAuth),
+	}
+	for name, value := range c.Cookies {
+		actions = append(actions, network.SetCookie(name, value).WithURL(pageUrl))
+	}
+	return actions, nil
+}
+
+func isSameHost(rawUrl, host string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Hostname(), host)
+}
+
+// mergeHeaders combines headers from an intercepted request with additional headers; the additional
+// headers take precedence. Output is sorted by name so requests are deterministic.
+func mergeHeaders(original network.Headers, additional map[string]string) []*fetch.HeaderEntry {
+	merged := make(map[string]string, len(original)+len(additional))
+	for name, value := range original {
+		merged[http.CanonicalHeaderKey(name)] = fmt.Sprint(value)
+	}
+	for name, value := range additional {
+		merged[http.CanonicalHeaderKey(name)] = value
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	entries := make([]*fetch.HeaderEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, &fetch.HeaderEntry{Name: name, Value: merged[name]})
+	}
+	return entries
+}
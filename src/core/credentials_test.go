@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCredentialsSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds *Credentials
+		want  string
+	}{
+		{"nil", nil, "None"},
+		{"empty", &Credentials{}, "None"},
+		{"basic auth", &Credentials{Username: "user", Password: "pass"}, "Basic auth"},
+		{"one header", &Credentials{Headers: map[string]string{"X-Token": "secret"}}, "1 header"},
+		{
+			"everything",
+			&Credentials{
+				Username: "user",
+				Headers:  map[string]string{"X-A": "a", "X-B": "b"},
+				Cookies:  map[string]string{"session": "xyz"},
+			},
+			"Basic auth, 2 headers, 1 cookie",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.creds.Summary()
+			if got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(got, "secret") || strings.Contains(got, "pass") {
+				t.Errorf("Summary() leaked a secret: %q", got)
+			}
+		})
+	}
+}
+
+func TestCredentialsApplyToRequest(t *testing.T) {
+	creds := &Credentials{
+		Username: "staging",
+		Password: "hunter2",
+		Headers:  map[string]string{"x-bypass-token": "abc123"},
+		Cookies:  map[string]string{"session": "xyz"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://staging.example.com/", nil)
+	creds.applyToRequest(req)
+
+	username, password, ok := req.BasicAuth()
+	if !ok || username != "staging" || password != "hunter2" {
+		t.Errorf("Expected basic auth staging:hunter2, got %q:%q (ok=%v)", username, password, ok)
+	}
+	if got := req.Header.Get("X-Bypass-Token"); got != "abc123" {
+		t.Errorf("Expected X-Bypass-Token header, got %q", got)
+	}
+	if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "xyz" {
+		t.Errorf("Expected session cookie, got %v (err=%v)", cookie, err)
+	}
+}
+
+func TestFetchTitleAndDescription_WithCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "staging" || password != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<html><head><title>Staging</title></head></html>`))
+	}))
+	defer server.Close()
+
+	if _, _, err := FetchTitleAndDescription(context.Background(), server.URL); err == nil {
+		t.Error("Expected an error when fetching without credentials")
+	}
+
+	title, _, err := FetchTitleAndDescription(context.Background(), server.URL,
+		WithCredentials(&Credentials{Username: "staging", Password: "hunter2"}))
+	if err != nil {
+		t.Fatalf("Expected no error when fetching with credentials, got: %v", err)
+	}
+	if title != "Staging" {
+		t.Errorf("Expected title %q, got %q", "Staging", title)
+	}
+}
+
+func TestFetchTitleAndDescription_CredentialsNotSentToOtherHosts(t *testing.T) {
+	var leakedHeader, leakedAuth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leakedHeader = r.Header.Get("X-Bypass-Token")
+		leakedAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`<html><head><title>Elsewhere</title></head></html>`))
+	}))
+	defer other.Close()
+
+	// Redirect from 127.0.0.1 to localhost, so that the hostnames differ.
+	otherUrl := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherUrl, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	_, _, err := FetchTitleAndDescription(context.Background(), origin.URL, WithCredentials(&Credentials{
+		Username: "staging",
+		Password: "hunter2",
+		Headers:  map[string]string{"X-Bypass-Token": "abc123"},
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if leakedHeader != "" || leakedAuth != "" {
+		t.Errorf("Credentials leaked to another host: X-Bypass-Token=%q, Authorization=%q", leakedHeader, leakedAuth)
+	}
+}
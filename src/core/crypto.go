@@ -0,0 +1,50 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrMissingEncryptionKey = errors.New("missing encryption key")
+
+// Encrypt seals plaintext using AES-256-GCM. The AES key is derived from the SHA-256 of the provided
+// passphrase, so any non-empty string can be used. The random nonce is prepended to the ciphertext.
+func Encrypt(passphrase string, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens ciphertext produced by [Encrypt] using the same passphrase.
+func Decrypt(passphrase string, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func newGCM(passphrase string) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, ErrMissingEncryptionKey
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	plaintext := []byte(`{"username":"staging","password":"hunter2"}`)
+
+	ciphertext, err := Encrypt("correct horse battery staple", plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if bytes.Contains(ciphertext, []byte("hunter2")) {
+		t.Error("Expected ciphertext to not contain the plaintext")
+	}
+
+	decrypted, err := Decrypt("correct horse battery staple", ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected %s, got %s", plaintext, decrypted)
+	}
+}
+
+func TestEncrypt_UsesRandomNonce(t *testing.T) {
+	a, _ := Encrypt("key", []byte("same input"))
+	b, _ := Encrypt("key", []byte("same input"))
+	if bytes.Equal(a, b) {
+		t.Error("Expected two encryptions of the same input to differ")
+	}
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	ciphertext, err := Encrypt("key-one", []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := Decrypt("key-two", ciphertext); err == nil {
+		t.Error("Expected error when decrypting with the wrong key")
+	}
+}
+
+func TestDecrypt_TruncatedCiphertext(t *testing.T) {
+	if _, err := Decrypt("key", []byte{1, 2, 3}); err == nil {
+		t.Error("Expected error for truncated ciphertext")
+	}
+}
+
+func TestEncrypt_MissingKey(t *testing.T) {
+	if _, err := Encrypt("", []byte("secret")); !errors.Is(err, ErrMissingEncryptionKey) {
+		t.Errorf("Expected ErrMissingEncryptionKey, got %v", err)
+	}
+	if _, err := Decrypt("", []byte("secret")); !errors.Is(err, ErrMissingEncryptionKey) {
+		t.Errorf("Expected ErrMissingEncryptionKey, got %v", err)
+	}
+}
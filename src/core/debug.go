@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// RenderStep is a step in rendering a page, as reported by [DebugScreenshot].
+type RenderStep struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// RenderReport describes each step of a render in detail, for debugging pages that fail to render, or
+// render incorrectly.
+type RenderReport struct {
+	Steps          []RenderStep
+	Console        []ConsoleMessage
+	FailedRequests []FailedRequest
+	// SelectorHtml is the outer HTML of the selected element, after it was un-hidden.
+	SelectorHtml string
+	// Screenshot is the image of the selected element, after any steps that follow the render.
+	Screenshot []byte
+	// ViewportScreenshot is the image of the whole viewport, for context.
+	ViewportScreenshot []byte
+}
+
+// Step runs fn, and records its duration & error, if any. It returns true if fn succeeded.
+func (r *RenderReport) Step(name string, fn func() error) bool {
+	start := time.Now()
+	err := fn()
+	r.Steps = append(r.Steps, RenderStep{Name: name, Duration: time.Since(start), Err: err})
+	return err == nil
+}
+
+// Err returns the error of the first failed step, if any.
+func (r *RenderReport) Err() error {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return step.Err
+		}
+	}
+	return nil
+}
+
+// DebugScreenshot works like [TakeScreenshot], but records each step into r, along with the page’s
+// console messages, failed network requests, the DOM of the selected element, and a screenshot of the
+// whole viewport, even if the element could not be found.
+func DebugScreenshot(ctx context.Context, r *RenderReport, url, selector string, opts ...PageOption) {
+	diagnostics := &Diagnostics{}
+	o := newPageOptions(append(opts, WithDiagnostics(diagnostics)))
+	defer func() {
+		r.Console = diagnostics.ConsoleMessages()
+		r.FailedRequests = diagnostics.FailedRequests()
+	}()
+
+	var cancel context.CancelFunc
+	if !r.Step("Launch Chrome", func() (err error) {
+		ctx, cancel, err = newChromedpContext(ctx)
+		return err
+	}) {
+		return
+	}
+	defer cancel()
+
+	if !r.Step("Prepare page", func() error {
+		setupActions, err := o.chromedpActions(ctx, url)
+		if err != nil {
+			return err
+		}
+		return runChromedp(ctx,
+			chromedp.Tasks(setupActions),
+			chromedp.EmulateViewport(o.viewport[0], o.viewport[1]),
+		)
+	}) {
+		return
+	}
+
+	if !r.Step("Navigate", func() error {
+		return runChromedp(ctx, chromedp.Navigate(url))
+	}) {
+		return
+	}
+
+	found := r.Step("Find & un-hide selector", func() error {
+		var found bool
+		if err := runChromedp(ctx, chromedp.Evaluate(revealElementJs(selector), &found)); err != nil {
+			return err
+		}
+		if !found {
+			return ErrMissingSelector
+		}
+		return nil
+	})
+	if found {
+		r.Step("Read DOM of selector", func() error {
+			return runChromedp(ctx, chromedp.OuterHTML(selector, &r.SelectorHtml, chromedp.ByQuery))
+		})
+	}
+
+	// Captured before waiting for the element, so that there is some context even if waiting times out.
+	r.Step("Screenshot viewport", func() error {
+		return runChromedp(ctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			r.ViewportScreenshot, err = page.CaptureScreenshot().WithFormat(page.CaptureScreenshotFormatPng).Do(ctx)
+			return err
+		}))
+	})
+
+	if found && r.Step("Wait until visible", func() error {
+		return runChromedp(ctx,
+			chromedp.WaitVisible(selector, chromedp.ByQuery),
+			chromedp.Sleep(time.Second), // Allow fonts to finish downloading.
+		)
+	}) {
+		r.Step("Screenshot selector", func() error {
+			return runChromedp(ctx, chromedp.Screenshot(selector, &r.Screenshot))
+		})
+	}
+}
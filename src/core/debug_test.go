@@ -0,0 +1,29 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenderReport_Step(t *testing.T) {
+	r := &RenderReport{}
+	if !r.Step("first", func() error { return nil }) {
+		t.Error("Step() = false for a successful step")
+	}
+	if r.Err() != nil {
+		t.Errorf("Err() = %v, want nil", r.Err())
+	}
+
+	errFailed := errors.New("failed")
+	if r.Step("second", func() error { return errFailed }) {
+		t.Error("Step() = true for a failed step")
+	}
+	r.Step("third", func() error { return errors.New("also failed") })
+
+	if len(r.Steps) != 3 || r.Steps[1].Name != "second" {
+		t.Errorf("Steps = %+v, want 3 steps in order", r.Steps)
+	}
+	if !errors.Is(r.Err(), errFailed) {
+		t.Errorf("Err() = %v, want the first error", r.Err())
+	}
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// ConsoleMessage is a message logged to the console by a page, or an uncaught exception.
+type ConsoleMessage struct {
+	// Level is the console method called (e.g. “log”, “warning”, “error”), or “exception”.
+	Level string
+	Text  string
+}
+
+// IsError returns true for console errors & uncaught exceptions.
+func (m ConsoleMessage) IsError() bool {
+	return m.Level == "error" || m.Level == "assert" || m.Level == "exception"
+}
+
+// FailedRequest is a network request made by a page that failed, or returned an HTTP error.
+type FailedRequest struct {
+	Method string
+	Url    string
+	Status int64  // Zero if no response was received.
+	Error  string // Network error, if no response was received.
+}
+
+func (f FailedRequest) String() string {
+	if f.Status != 0 {
+		return fmt.Sprintf("%s %s (%d)", f.Method, f.Url, f.Status)
+	}
+	return fmt.Sprintf("%s %s (%s)", f.Method, f.Url, f.Error)
+}
+
+// Diagnostics collects console messages, uncaught exceptions, and failed network requests while a page
+// is rendered by Chrome. It is safe for concurrent use, since events arrive on Chrome’s goroutines.
+type Diagnostics struct {
+	mu       sync.Mutex
+	console  []ConsoleMessage
+	failed   []FailedRequest
+	requests map[network.RequestID]pendingRequest // Requests in flight, so that failures can be attributed.
+}
+
+type pendingRequest struct {
+	method string
+	url    string
+}
+
+// These limits bound the memory used by pages that log, request, or fail excessively.
+const (
+	maxDiagnosticEntries    = 200
+	maxDiagnosticTextLength = 1024 // bytes, per message or URL
+	maxPendingRequests      = 1000
+)
+
+// WithDiagnostics captures console messages & failed network requests into d while rendering.
+func WithDiagnostics(d *Diagnostics) PageOption {
+	return func(o *pageOptions) {
+		o.diagnostics = d
+	}
+}
+
+// ConsoleMessages returns the console messages & uncaught exceptions captured so far.
+func (d *Diagnostics) ConsoleMessages() []ConsoleMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]ConsoleMessage(nil), d.console...)
+}
+
+// FailedRequests returns the failed network requests captured so far.
+func (d *Diagnostics) FailedRequests() []FailedRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]FailedRequest(nil), d.failed...)
+}
+
+// Summary returns a compact, single-line description of console errors, uncaught exceptions, and failed
+// requests, suitable for storing alongside error logs, or an empty string if there were none.
+func (d *Diagnostics) Summary() string {
+	const maxLength = 2000
+	var parts []string
+	for _, m := range d.ConsoleMessages() {
+		if m.IsError() {
+			parts = append(parts, m.Level+": "+m.Text)
+		}
+	}
+	for _, f := range d.FailedRequests() {
+		parts = append(parts, "failed: "+f.String())
+	}
+	summary := strings.Join(strings.Fields(strings.Join(parts, "; ")), " ")
+	if len(summary) > maxLength {
+		summary = strings.ToValidUTF8(summary[:maxLength], "") + "…"
+	}
+	return summary
+}
+
+// chromedpActions returns actions that start capturing events; they must be run before navigating.
+// Nothing is captured if d is nil.
+func (d *Diagnostics) chromedpActions(ctx context.Context) []chromedp.Action {
+	if d == nil {
+		return nil
+	}
+	chromedp.ListenTarget(ctx, d.handleEvent)
+	return []chromedp.Action{runtime.Enable(), network.Enable()}
+}
+
+func (d *Diagnostics) handleEvent(ev any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		var args []string
+		for _, arg := range ev.Args {
+			args = append(args, remoteObjectString(arg))
+		}
+		d.addConsoleMessage(ConsoleMessage{Level: string(ev.Type), Text: strings.Join(args, " ")})
+
+	case *runtime.EventExceptionThrown:
+		text := ev.ExceptionDetails.Text
+		if ex := ev.ExceptionDetails.Exception; ex != nil && ex.Description != "" {
+			text = ex.Description
+		}
+		d.addConsoleMessage(ConsoleMessage{Level: "exception", Text: text})
+
+	case *network.EventRequestWillBeSent:
+		if d.requests == nil {
+			d.requests = map[network.RequestID]pendingRequest{}
+		}
+		// Requests beyond the limit are not tracked, so their failures are reported without a method or URL.
+		if _, ok := d.requests[ev.RequestID]; ok || len(d.requests) < maxPendingRequests {
+			d.requests[ev.RequestID] = pendingRequest{method: ev.Request.Method, url: truncateText(ev.Request.URL)}
+		}
+
+	case *network.EventResponseReceived:
+		if ev.Response.Status >= 400 {
+			method := "GET"
+			if r, ok := d.requests[ev.RequestID]; ok {
+				method = r.method
+			}
+			d.addFailedRequest(FailedRequest{Method: method, Url: ev.Response.URL, Status: ev.Response.Status})
+		}
+
+	case *network.EventLoadingFinished:
+		delete(d.requests, ev.RequestID)
+
+	case *network.EventLoadingFailed:
+		r, ok := d.requests[ev.RequestID]
+		delete(d.requests, ev.RequestID)
+		if ev.Canceled {
+			return // Requests are canceled by the page itself, or when rendering completes.
+		}
+		failed := FailedRequest{Method: "GET", Error: ev.ErrorText}
+		if ok {
+			failed.Method, failed.Url = r.method, r.url
+		}
+		if ev.BlockedReason != "" {
+			failed.Error += " (blocked: " + string(ev.BlockedReason) + ")"
+		}
+		d.addFailedRequest(failed)
+	}
+}
+
+func (d *Diagnostics) addConsoleMessage(m ConsoleMessage) {
+	if len(d.console) < maxDiagnosticEntries {
+		m.Text = truncateText(m.Text)
+		d.console = append(d.console, m)
+	}
+}
+
+func (d *Diagnostics) addFailedRequest(f FailedRequest) {
+	if len(d.failed) < maxDiagnosticEntries {
+		f.Url, f.Error = truncateText(f.Url), truncateText(f.Error)
+		d.failed = append(d.failed, f)
+	}
+}
+
+// truncateText limits s to maxDiagnosticTextLength bytes, so that a single huge message (or data: URL)
+// cannot use up memory on its own.
+func truncateText(s string) string {
+	if len(s) <= maxDiagnosticTextLength {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxDiagnosticTextLength], "") + "…"
+}
+
+// remoteObjectString formats an argument to a console method, the way DevTools does, but without
+// expanding objects.
+func remoteObjectString(o *runtime.RemoteObject) string {
+	if o == nil {
+		return ""
+	}
+	if len(o.Value) > 0 {
+		var s string
+		if err := json.Unmarshal([]byte(o.Value), &s); err == nil {
+			return s
+		}
+		return string(o.Value)
+	}
+	if o.UnserializableValue != "" {
+		return string(o.UnserializableValue)
+	}
+	if o.Description != "" {
+		return o.Description
+	}
+	return string(o.Type)
+}
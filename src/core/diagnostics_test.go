@@ -0,0 +1,111 @@
+package core
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func TestDiagnostics_HandleEvent(t *testing.T) {
+	d := &Diagnostics{}
+	d.handleEvent(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeError,
+		Args: []*runtime.RemoteObject{
+			{Type: runtime.TypeString, Value: []byte(`"Failed to load"`)},
+			{Type: runtime.TypeNumber, Value: []byte(`42`)},
+			{Type: runtime.TypeObject, Description: "Object"},
+		},
+	})
+	d.handleEvent(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:      "Uncaught",
+		Exception: &runtime.RemoteObject{Type: runtime.TypeObject, Description: "TypeError: x is undefined"},
+	}})
+	d.handleEvent(&network.EventRequestWillBeSent{RequestID: "1", Request: &network.Request{Method: "GET", URL: "https://example.com/font.woff2"}})
+	d.handleEvent(&network.EventRequestWillBeSent{RequestID: "2", Request: &network.Request{Method: "POST", URL: "https://example.com/api"}})
+	d.handleEvent(&network.EventRequestWillBeSent{RequestID: "3", Request: &network.Request{Method: "GET", URL: "https://example.com/ok.css"}})
+	d.handleEvent(&network.EventLoadingFailed{RequestID: "1", ErrorText: "net::ERR_NAME_NOT_RESOLVED"})
+	d.handleEvent(&network.EventResponseReceived{RequestID: "2", Response: &network.Response{URL: "https://example.com/api", Status: 503}})
+	d.handleEvent(&network.EventResponseReceived{RequestID: "3", Response: &network.Response{URL: "https://example.com/ok.css", Status: 200}})
+	d.handleEvent(&network.EventLoadingFailed{RequestID: "3", ErrorText: "net::ERR_ABORTED", Canceled: true})
+
+	wantConsole := []ConsoleMessage{
+		{Level: "error", Text: "Failed to load 42 Object"},
+		{Level: "exception", Text: "TypeError: x is undefined"},
+	}
+	if got := d.ConsoleMessages(); !slices.Equal(got, wantConsole) {
+		t.Errorf("ConsoleMessages() = %v, want %v", got, wantConsole)
+	}
+	wantFailed := []FailedRequest{
+		{Method: "GET", Url: "https://example.com/font.woff2", Error: "net::ERR_NAME_NOT_RESOLVED"},
+		{Method: "POST", Url: "https://example.com/api", Status: 503},
+	}
+	if got := d.FailedRequests(); !slices.Equal(got, wantFailed) {
+		t.Errorf("FailedRequests() = %v, want %v", got, wantFailed)
+	}
+}
+
+func TestDiagnostics_BoundsMemory(t *testing.T) {
+	d := &Diagnostics{}
+	huge := strings.Repeat("x", 100*1024)
+	for range maxDiagnosticEntries + 10 {
+		d.handleEvent(&runtime.EventConsoleAPICalled{
+			Type: runtime.APITypeLog,
+			Args: []*runtime.RemoteObject{{Type: runtime.TypeString, Value: []byte(`"` + huge + `"`)}},
+		})
+	}
+	messages := d.ConsoleMessages()
+	if len(messages) != maxDiagnosticEntries {
+		t.Errorf("ConsoleMessages() = %d messages, want %d", len(messages), maxDiagnosticEntries)
+	}
+	for _, m := range messages {
+		if len(m.Text) > maxDiagnosticTextLength+len("…") {
+			t.Fatalf("ConsoleMessage.Text = %d bytes, want at most %d", len(m.Text), maxDiagnosticTextLength)
+		}
+	}
+
+	for i := range maxPendingRequests + 10 {
+		id := network.RequestID(strconv.Itoa(i))
+		d.handleEvent(&network.EventRequestWillBeSent{RequestID: id, Request: &network.Request{Method: "GET", URL: "https://example.com/" + huge}})
+		if i%2 == 0 {
+			d.handleEvent(&network.EventLoadingFinished{RequestID: id})
+		} else {
+			d.handleEvent(&network.EventLoadingFailed{RequestID: id, ErrorText: "net::ERR_ABORTED", Canceled: true})
+		}
+	}
+	if len(d.requests) != 0 {
+		t.Errorf("requests = %d entries after loading finished, want 0", len(d.requests))
+	}
+
+	for i := range maxPendingRequests + 10 {
+		d.handleEvent(&network.EventRequestWillBeSent{RequestID: network.RequestID(strconv.Itoa(i)), Request: &network.Request{Method: "GET", URL: "https://example.com/"}})
+	}
+	if len(d.requests) != maxPendingRequests {
+		t.Errorf("requests = %d entries, want at most %d", len(d.requests), maxPendingRequests)
+	}
+}
+
+func TestDiagnostics_Summary(t *testing.T) {
+	d := &Diagnostics{}
+	if got := d.Summary(); got != "" {
+		t.Errorf("Summary() = %q, want empty", got)
+	}
+
+	d.addConsoleMessage(ConsoleMessage{Level: "log", Text: "Just logging"})
+	d.addConsoleMessage(ConsoleMessage{Level: "exception", Text: "TypeError:\n    at main.js:1"})
+	d.addFailedRequest(FailedRequest{Method: "GET", Url: "https://example.com/a.png", Status: 404})
+	want := "exception: TypeError: at main.js:1; failed: GET https://example.com/a.png (404)"
+	if got := d.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	for range 3 {
+		d.addConsoleMessage(ConsoleMessage{Level: "error", Text: strings.Repeat("é", 2000)})
+	}
+	if got := d.Summary(); len(got) > 2010 || !strings.HasSuffix(got, "…") {
+		t.Errorf("Summary() was not truncated: %d bytes", len(got))
+	}
+}
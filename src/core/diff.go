@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// diffTolerance is the largest difference in any channel (out of 255) between two pixels that are still
+// considered identical, so that lossy compression does not show up as a change.
+const diffTolerance = 16
+
+var diffHighlight = color.NRGBA{R: 0xff, G: 0x00, B: 0x55, A: 0xff}
+
+// DiffImages compares two images (PNG or JPEG) pixel by pixel, and returns a PNG of the same size as the
+// larger one, with unchanged pixels faded to gray and changed pixels highlighted, along with the fraction
+// of pixels that changed. Pixels outside the bounds of either image are considered changed. Stops with
+// ctx’s error if it is done before the comparison is complete.
+func DiffImages(ctx context.Context, a, b []byte) ([]byte, float64, error) {
+	imgA, _, err := image.Decode(bytes.NewReader(a))
+	if err != nil {
+		return nil, 0, err
+	}
+	imgB, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	boundsA, boundsB := imgA.Bounds(), imgB.Bounds()
+	width := max(boundsA.Dx(), boundsB.Dx())
+	height := max(boundsA.Dy(), boundsB.Dy())
+	diff := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	changed := 0
+	for y := range height {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		for x := range width {
+			pA := image.Pt(boundsA.Min.X+x, boundsA.Min.Y+y)
+			pB := image.Pt(boundsB.Min.X+x, boundsB.Min.Y+y)
+			if !pA.In(boundsA) || !pB.In(boundsB) {
+				changed++
+				diff.SetNRGBA(x, y, diffHighlight)
+				continue
+			}
+			cA := color.NRGBAModel.Convert(imgA.At(pA.X, pA.Y)).(color.NRGBA)
+			cB := color.NRGBAModel.Convert(imgB.At(pB.X, pB.Y)).(color.NRGBA)
+			if !similar(cA, cB) {
+				changed++
+				diff.SetNRGBA(x, y, diffHighlight)
+				continue
+			}
+			// Fade to a light gray, so that highlighted pixels stand out, while the layout remains recognizable.
+			gray := color.GrayModel.Convert(cA).(color.Gray).Y
+			faded := 0xff - (0xff-gray)/4
+			diff.SetNRGBA(x, y, color.NRGBA{R: faded, G: faded, B: faded, A: 0xff})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, diff); err != nil {
+		return nil, 0, err
+	}
+	fraction := 0.0
+	if width*height > 0 {
+		fraction = float64(changed) / float64(width*height)
+	}
+	return buf.Bytes(), fraction, nil
+}
+
+func similar(a, b color.NRGBA) bool {
+	return absDiff(a.R, b.R) <= diffTolerance &&
+		absDiff(a.G, b.G) <= diffTolerance &&
+		absDiff(a.B, b.B) <= diffTolerance &&
+		absDiff(a.A, b.A) <= diffTolerance
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
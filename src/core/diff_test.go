@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestDiffImages_Identical(t *testing.T) {
+	img := encodeTestPNG(t, 40, 20, color.White)
+	diff, fraction, err := DiffImages(context.Background(), img, img)
+	if err != nil {
+		t.Fatalf("DiffImages() error = %v", err)
+	}
+	if fraction != 0 {
+		t.Errorf("DiffImages() fraction = %v, want 0", fraction)
+	}
+	if got := decodeTestPNG(t, diff).Bounds(); got.Dx() != 40 || got.Dy() != 20 {
+		t.Errorf("DiffImages() size = %v, want 40×20", got)
+	}
+}
+
+func TestDiffImages_ChangedPixels(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	b := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := range 10 {
+		for x := range 10 {
+			a.Set(x, y, color.White)
+			b.Set(x, y, color.White)
+		}
+	}
+	b.Set(0, 0, color.Black)
+	b.Set(1, 0, color.NRGBA{R: 0xf8, G: 0xf8, B: 0xf8, A: 0xff}) // Within tolerance.
+
+	diff, fraction, err := DiffImages(context.Background(), encodeImage(t, a), encodeImage(t, b))
+	if err != nil {
+		t.Fatalf("DiffImages() error = %v", err)
+	}
+	if fraction != 0.01 {
+		t.Errorf("DiffImages() fraction = %v, want 0.01", fraction)
+	}
+	img := decodeTestPNG(t, diff)
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != diffHighlight {
+		t.Errorf("changed pixel = %v, want %v", got, diffHighlight)
+	}
+	if got := color.NRGBAModel.Convert(img.At(1, 0)); got == diffHighlight {
+		t.Errorf("pixel within tolerance was highlighted")
+	}
+}
+
+func TestDiffImages_DifferentSizes(t *testing.T) {
+	_, fraction, err := DiffImages(context.Background(), encodeTestPNG(t, 10, 10, color.White), encodeTestPNG(t, 10, 20, color.White))
+	if err != nil {
+		t.Fatalf("DiffImages() error = %v", err)
+	}
+	if fraction != 0.5 {
+		t.Errorf("DiffImages() fraction = %v, want 0.5", fraction)
+	}
+}
+
+func TestDiffImages_InvalidImage(t *testing.T) {
+	if _, _, err := DiffImages(context.Background(), []byte("not an image"), encodeTestPNG(t, 1, 1, color.White)); err == nil {
+		t.Error("DiffImages() should fail for invalid images")
+	}
+}
+
+func encodeImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
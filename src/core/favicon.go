@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"io"
+	neturl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+)
+
+// maxFaviconBytes limits the size of icons downloaded from sites.
+const maxFaviconBytes = 1024 * 1024
+
+// FetchFavicon retrieves a web page, and returns the largest icon it declares (via <link rel="icon"> or
+// <link rel="apple-touch-icon">), falling back to /favicon.ico. SVG icons, and ICO files without PNG
+// images, cannot be decoded, so they are skipped. Credentials are only sent for icons on the page’s own host,
+// never to CDNs or other third-party hosts.
+func FetchFavicon(ctx context.Context, url string, opts ...PageOption) (image.Image, error) {
+	o := newPageOptions(opts)
+	doc, err := fetchPage(ctx, url, o)
+	if err != nil {
+		return nil, err
+	}
+	base, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	thirdParty := *o
+	thirdParty.credentials = nil
+
+	lastErr := errors.New("no icons declared")
+	for _, iconUrl := range faviconCandidates(doc, base) {
+		iconOpts := o
+		if u, err := neturl.Parse(iconUrl); err != nil || !strings.EqualFold(u.Hostname(), base.Hostname()) {
+			iconOpts = &thirdParty
+		}
+		img, err := fetchIcon(ctx, iconUrl, iconOpts)
+		if err == nil {
+			return img, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", iconUrl, err)
+	}
+	return nil, fmt.Errorf("no usable favicon: %w", lastErr)
+}
+
+// faviconCandidates returns the URLs of icons declared by a page, largest first, followed by /favicon.ico.
+func faviconCandidates(doc *html.Node, base *neturl.URL) []string {
+	type candidate struct {
+		url  string
+		size int
+	}
+	var candidates []candidate
+	var parse func(*html.Node)
+	parse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rel, href, sizes, mimeType string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = strings.ToLower(attr.Val)
+				case "href":
+					href = strings.TrimSpace(attr.Val)
+				case "sizes":
+					sizes = strings.ToLower(attr.Val)
+				case "type":
+					mimeType = strings.ToLower(attr.Val)
+				}
+			}
+			fields := strings.Fields(rel)
+			isIcon := slices.Contains(fields, "icon")
+			isTouchIcon := slices.Contains(fields, "apple-touch-icon") || slices.Contains(fields, "apple-touch-icon-precomposed")
+			if (isIcon || isTouchIcon) && href != "" && mimeType != "image/svg+xml" && !strings.HasSuffix(strings.ToLower(href), ".svg") {
+				if ref, err := neturl.Parse(href); err == nil {
+					size := iconSize(sizes)
+					if size == 0 && isTouchIcon {
+						size = 180 // The size assumed by iOS when unspecified.
+					}
+					candidates = append(candidates, candidate{url: base.ResolveReference(ref).String(), size: size})
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			parse(c)
+		}
+	}
+	parse(doc)
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return b.size - a.size })
+	urls := make([]string, 0, len(candidates)+1)
+	for _, c := range candidates {
+		if !slices.Contains(urls, c.url) {
+			urls = append(urls, c.url)
+		}
+	}
+	if favicon := base.ResolveReference(&neturl.URL{Path: "/favicon.ico"}).String(); !slices.Contains(urls, favicon) {
+		urls = append(urls, favicon)
+	}
+	return urls
+}
+
+// iconSize returns the largest width declared in a sizes attribute, e.g. “16x16 32x32”.
+func iconSize(sizes string) int {
+	var largest int
+	for _, size := range strings.Fields(sizes) {
+		width, _, _ := strings.Cut(size, "x")
+		if w, err := strconv.Atoi(width); err == nil {
+			largest = max(largest, w)
+		}
+	}
+	return largest
+}
+
+func fetchIcon(ctx context.Context, url string, o *pageOptions) (image.Image, error) {
+	resp, err := httpGet(ctx, url, o)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0, 0, 1, 0}) {
+		return decodeIco(data)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// decodeIco returns the largest PNG image within an ICO file. Older ICO files contain BMP images instead,
+// which are not supported.
+func decodeIco(data []byte) (image.Image, error) {
+	const headerSize, entrySize = 6, 16
+	if len(data) < headerSize {
+		return nil, errors.New("truncated ICO file")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	var largest image.Image
+	for i := range count {
+		entry := data[headerSize+i*entrySize:]
+		if len(entry) < entrySize {
+			return nil, errors.New("truncated ICO file")
+		}
+		size := int(binary.LittleEndian.Uint32(entry[8:12]))
+		offset := int(binary.LittleEndian.Uint32(entry[12:16]))
+		if offset < 0 || size < 0 || offset+size > len(data) || !bytes.HasPrefix(data[offset:], []byte("\x89PNG")) {
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(data[offset : offset+size]))
+		if err == nil && (largest == nil || img.Bounds().Dx() > largest.Bounds().Dx()) {
+			largest = img
+		}
+	}
+	if largest == nil {
+		return nil, errors.New("ICO file contains no PNG images")
+	}
+	return largest, nil
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"slices"
+	"testing"
+)
+
+func TestFetchFavicon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><head>
+				<link rel="icon" href="/icon-16.png" sizes="16x16">
+				<link rel="icon" href="/icon.svg" type="image/svg+xml">
+				<link rel="apple-touch-icon" href="/touch.png">
+			</head></html>`))
+		case "/icon-16.png":
+			w.Write(encodeTestPNG(t, 16, 16, color.White))
+		case "/touch.png":
+			w.Write(encodeTestPNG(t, 180, 180, color.Black))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	img, err := FetchFavicon(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("FetchFavicon() error = %v", err)
+	}
+	if got := img.Bounds().Dx(); got != 180 {
+		t.Errorf("FetchFavicon() width = %d, want 180", got)
+	}
+}
+
+func TestFetchFavicon_Ico(t *testing.T) {
+	png := encodeTestPNG(t, 32, 32, color.White)
+	ico := []byte{0, 0, 1, 0, 1, 0}
+	entry := make([]byte, 16)
+	entry[0], entry[1] = 32, 32
+	binary.LittleEndian.PutUint32(entry[8:], uint32(len(png)))
+	binary.LittleEndian.PutUint32(entry[12:], uint32(len(ico)+len(entry)))
+	ico = append(append(ico, entry...), png...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico":
+			w.Write(ico)
+		default:
+			w.Write([]byte(`<html><head><title>No icons</title></head></html>`))
+		}
+	}))
+	defer server.Close()
+
+	img, err := FetchFavicon(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("FetchFavicon() error = %v", err)
+	}
+	if got := img.Bounds().Dx(); got != 32 {
+		t.Errorf("FetchFavicon() width = %d, want 32", got)
+	}
+}
+
+func TestFetchFavicon_CrossHostWithoutCredentials(t *testing.T) {
+	png := encodeTestPNG(t, 64, 64, color.White)
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok || r.Header.Get("X-Bypass-Token") != "" || len(r.Cookies()) > 0 {
+			t.Errorf("credentials sent to a third-party host: %v", r.Header)
+		}
+		w.Write(png)
+	}))
+	defer cdn.Close()
+	cdnUrl, _ := neturl.Parse(cdn.URL)
+	iconUrl := "http://localhost:" + cdnUrl.Port() + "/icon.png" // A different hostname than the page’s 127.0.0.1.
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok || r.Header.Get("X-Bypass-Token") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<html><head><link rel="icon" href="` + iconUrl + `"></head></html>`))
+	}))
+	defer server.Close()
+
+	creds := &Credentials{
+		Username: "staging",
+		Password: "hunter2",
+		Headers:  map[string]string{"x-bypass-token": "abc123"},
+		Cookies:  map[string]string{"session": "xyz"},
+	}
+	img, err := FetchFavicon(context.Background(), server.URL+"/", WithCredentials(creds))
+	if err != nil {
+		t.Fatalf("FetchFavicon() error = %v", err)
+	}
+	if got := img.Bounds().Dx(); got != 64 {
+		t.Errorf("FetchFavicon() width = %d, want 64", got)
+	}
+}
+
+func TestFetchFavicon_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<html><head><link rel="icon" href="/missing.png"></head></html>`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	if _, err := FetchFavicon(context.Background(), server.URL+"/"); err == nil {
+		t.Error("FetchFavicon() should fail when no icon can be fetched")
+	}
+}
+
+func TestIconSize(t *testing.T) {
+	tests := map[string]int{"": 0, "any": 0, "16x16": 16, "16x16 192x192 32x32": 192}
+	for sizes, want := range tests {
+		if got := iconSize(sizes); got != want {
+			t.Errorf("iconSize(%q) = %d, want %d", sizes, got, want)
+		}
+	}
+}
+
+func TestFaviconCandidates_Deduplicates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+			<link rel="shortcut icon" href="/favicon.ico">
+			<link rel="icon" href="/favicon.ico" sizes="32x32">
+		</head></html>`))
+	}))
+	defer server.Close()
+
+	o := newPageOptions(nil)
+	doc, err := fetchPage(context.Background(), server.URL, o)
+	if err != nil {
+		t.Fatalf("fetchPage() error = %v", err)
+	}
+	base, _ := neturl.Parse(server.URL)
+	got := faviconCandidates(doc, base)
+	if want := []string{server.URL + "/favicon.ico"}; !slices.Equal(got, want) {
+		t.Errorf("faviconCandidates() = %q, want %q", got, want)
+	}
+}
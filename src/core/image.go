@@ -2,19 +2,113 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
 	"image/png"
+
+	"butterfly.chimbori.dev/conf"
 )
 
+// CompressPNG quantizes a PNG image to a palette of at most 256 colors (see [Quantize]), as long as its
+// quality does not drop below conf.Config.Compression.MinQuality. It falls back to lossless compression
+// when the quantized image would look worse than that, or would not be any smaller.
 func CompressPNG(input []byte) ([]byte, error) {
 	img, _, err := image.Decode(bytes.NewReader(input))
 	if err != nil {
 		return nil, err
 	}
-	var buf bytes.Buffer
 	enc := png.Encoder{CompressionLevel: png.BestCompression}
-	if err := enc.Encode(&buf, img); err != nil {
+	var lossless bytes.Buffer
+	if err := enc.Encode(&lossless, img); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+
+	quantized, quality := Quantize(img, 256)
+	if quality < conf.Config.Compression.MinQuality {
+		return lossless.Bytes(), nil
+	}
+	var lossy bytes.Buffer
+	if err := enc.Encode(&lossy, quantized); err != nil {
+		return nil, err
+	}
+	if lossy.Len() >= lossless.Len() {
+		return lossless.Bytes(), nil
+	}
+	return lossy.Bytes(), nil
+}
+
+// budgetJpegQualities are tried in order, from best-looking to smallest, when PNGs do not fit
+// within a size budget.
+var budgetJpegQualities = []int{90, 80, 70, 60, 50, 40, 30}
+
+// budgetPaletteSizes are tried in order when the compressed PNG does not fit within a size budget,
+// regardless of the configured minimum quality, since a budget that is met takes precedence.
+var budgetPaletteSizes = []int{256, 128, 64, 32}
+
+// FitToBudget compresses a PNG image until it is no larger than budget bytes, trying progressively
+// lossier encodings in turn: a PNG compressed by [CompressPNG], then PNGs with ever-smaller palettes,
+// and finally JPEGs of decreasing quality. It returns the first encoding that fits, or the smallest one if none does,
+// along with whether the budget was met. JPEGs cannot be transparent, so they are flattened on white.
+// Stops with ctx’s error if it is done before an encoding that fits is found.
+func FitToBudget(ctx context.Context, input []byte, budget int64) ([]byte, bool, error) {
+	compressed, err := CompressPNG(input)
+	if err != nil {
+		return nil, false, err
+	}
+	if fits(compressed, budget) {
+		return compressed, true, nil
+	}
+
+	// Start over from the original, so that lossy encodings do not compound.
+	img, _, err := image.Decode(bytes.NewReader(input))
+	if err != nil {
+		return nil, false, err
+	}
+	smallest := compressed
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	for _, size := range budgetPaletteSizes {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		quantized, _ := Quantize(img, size)
+		buf.Reset()
+		if err := enc.Encode(&buf, quantized); err != nil {
+			return nil, false, err
+		}
+		if fits(buf.Bytes(), budget) {
+			return buf.Bytes(), true, nil
+		}
+		if buf.Len() < len(smallest) {
+			smallest = bytes.Clone(buf.Bytes())
+		}
+	}
+
+	opaque := image.NewRGBA(img.Bounds())
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+	for _, quality := range budgetJpegQualities {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		buf.Reset()
+		if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, false, err
+		}
+		if fits(buf.Bytes(), budget) {
+			return buf.Bytes(), true, nil
+		}
+		if buf.Len() < len(smallest) {
+			smallest = bytes.Clone(buf.Bytes())
+		}
+	}
+	return smallest, false, nil
+}
+
+func fits(data []byte, budget int64) bool {
+	return int64(len(data)) <= budget
 }
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"testing"
+)
+
+// encodeNoisyTestPNG returns a PNG of random pixels, which compresses poorly as a PNG but well as a JPEG.
+func encodeNoisyTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(rng.IntN(256)), G: uint8(x), B: uint8(y), A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFitToBudget_LosslessWhenItFits(t *testing.T) {
+	input := encodeTestPNG(t, 200, 100, color.White)
+	output, ok, err := FitToBudget(context.Background(), input, 100_000)
+	if err != nil {
+		t.Fatalf("FitToBudget() error = %v", err)
+	}
+	if !ok {
+		t.Errorf("FitToBudget() did not meet an easy budget")
+	}
+	if got := http.DetectContentType(output); got != "image/png" {
+		t.Errorf("FitToBudget() content type = %s, want image/png", got)
+	}
+}
+
+func TestFitToBudget_FallsBackToLossy(t *testing.T) {
+	input := encodeNoisyTestPNG(t, 256, 256)
+	compressed, err := CompressPNG(input)
+	if err != nil {
+		t.Fatalf("CompressPNG() error = %v", err)
+	}
+	budget := int64(len(compressed) / 2)
+
+	output, ok, err := FitToBudget(context.Background(), input, budget)
+	if err != nil {
+		t.Fatalf("FitToBudget() error = %v", err)
+	}
+	if !ok {
+		t.Fatalf("FitToBudget() did not meet budget %d, got %d bytes", budget, len(output))
+	}
+	if int64(len(output)) > budget {
+		t.Errorf("FitToBudget() = %d bytes, want at most %d", len(output), budget)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(output)); err != nil {
+		t.Errorf("FitToBudget() output is not a valid image: %v", err)
+	}
+}
+
+func TestFitToBudget_ReturnsSmallestWhenUnmet(t *testing.T) {
+	input := encodeNoisyTestPNG(t, 256, 256)
+	output, ok, err := FitToBudget(context.Background(), input, 10)
+	if err != nil {
+		t.Fatalf("FitToBudget() error = %v", err)
+	}
+	if ok {
+		t.Errorf("FitToBudget() claims to meet an impossible budget")
+	}
+	if len(output) == 0 || len(output) >= len(input) {
+		t.Errorf("FitToBudget() = %d bytes, want smaller than input (%d bytes)", len(output), len(input))
+	}
+}
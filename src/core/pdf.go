@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// PaperSizes lists supported paper sizes as portrait width × height, in inches.
+var PaperSizes = map[string][2]float64{
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+}
+
+// PdfOptions controls the layout of PDFs rendered by [RenderPdf]. All dimensions are in inches.
+type PdfOptions struct {
+	PaperWidth      float64
+	PaperHeight     float64
+	MarginTop       float64
+	MarginRight     float64
+	MarginBottom    float64
+	MarginLeft      float64
+	Landscape       bool
+	PrintBackground bool
+}
+
+// RenderPdf prints a web page to PDF, using print media styles, as if the user had printed it from Chrome.
+func RenderPdf(ctx context.Context, url string, options PdfOptions, opts ...PageOption) ([]byte, error) {
+	slog.Debug("renderPdf", "url", url)
+
+	ctx, cancel, err := newChromedpContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	setupActions, err := newPageOptions(opts).chromedpActions(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	if err := runChromedp(ctx,
+		chromedp.Tasks(setupActions),
+		emulation.SetEmulatedMedia().WithMedia("print"),
+		chromedp.Navigate(url),
+		chromedp.Sleep(time.Second), // Allow fonts to finish downloading.
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			buf, _, err = page.PrintToPDF().
+				WithPaperWidth(options.PaperWidth).
+				WithPaperHeight(options.PaperHeight).
+				WithMarginTop(options.MarginTop).
+				WithMarginRight(options.MarginRight).
+				WithMarginBottom(options.MarginBottom).
+				WithMarginLeft(options.MarginLeft).
+				WithLandscape(options.Landscape).
+				WithPrintBackground(options.PrintBackground).
+				Do(ctx)
+			return err
+		}),
+	); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
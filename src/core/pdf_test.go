@@ -0,0 +1,30 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRenderPdf_ValidPage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	url := "data:text/html,<html><body><h1>Invoice</h1></body></html>"
+	size := PaperSizes["a4"]
+
+	pdf, err := RenderPdf(ctx, url, PdfOptions{
+		PaperWidth:  size[0],
+		PaperHeight: size[1],
+		MarginTop:   0.4,
+		MarginLeft:  0.4,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error for valid page, got: %s", err.Error())
+	}
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Errorf("Expected PDF magic bytes, got: %q", pdf[:min(len(pdf), 8)])
+	}
+}
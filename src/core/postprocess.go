@@ -0,0 +1,253 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"butterfly.chimbori.dev/conf"
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// ErrInvalidImageStep is returned when a pipeline step does not specify exactly one operation.
+var ErrInvalidImageStep = errors.New("each image processing step must specify exactly one operation")
+
+// ProcessImage applies a pipeline of steps to a PNG image, such as overlaying a logo, stamping a badge,
+// or adding a border, and returns the resulting PNG. The output is not compressed; see [CompressPNG].
+func ProcessImage(input []byte, steps []conf.ImageStep) ([]byte, error) {
+	if len(steps) == 0 {
+		return input, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if img, err = applyImageStep(img, step); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func applyImageStep(img image.Image, step conf.ImageStep) (image.Image, error) {
+	operations := 0
+	for _, set := range []bool{step.Overlay != nil, step.Badge != nil, step.Pad != nil, step.Round != nil, step.Fit != nil} {
+		if set {
+			operations++
+		}
+	}
+	if operations != 1 {
+		return nil, ErrInvalidImageStep
+	}
+
+	switch {
+	case step.Overlay != nil:
+		return applyOverlay(img, step.Overlay)
+	case step.Badge != nil:
+		return applyBadge(img, step.Badge)
+	case step.Pad != nil:
+		return applyPad(img, step.Pad)
+	case step.Round != nil:
+		return applyRound(img, step.Round), nil
+	default:
+		return applyFit(img, step.Fit)
+	}
+}
+
+// overlayImages holds decoded overlay files, since the same few logos are drawn on every preview.
+var overlayImages sync.Map
+
+func loadOverlayImage(file string) (image.Image, error) {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(conf.Config.DataDir, path)
+	}
+	if cached, ok := overlayImages.Load(path); ok {
+		return cached.(image.Image), nil
+	}
+	img, err := imaging.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	overlayImages.Store(path, img)
+	return img, nil
+}
+
+func applyOverlay(img image.Image, step *conf.OverlayStep) (image.Image, error) {
+	if step.File == "" {
+		return nil, errors.New("overlay: missing file")
+	}
+	overlay, err := loadOverlayImage(step.File)
+	if err != nil {
+		return nil, fmt.Errorf("overlay: %w", err)
+	}
+	if step.Width > 0 {
+		overlay = imaging.Resize(overlay, step.Width, 0, imaging.Lanczos)
+	}
+	opacity := step.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	pos, err := anchorPosition(img.Bounds().Size(), overlay.Bounds().Size(), step.Position, "bottom-right", step.Margin)
+	if err != nil {
+		return nil, fmt.Errorf("overlay: %w", err)
+	}
+	return imaging.Overlay(img, overlay, pos, opacity), nil
+}
+
+func applyBadge(img image.Image, step *conf.BadgeStep) (image.Image, error) {
+	if step.Text == "" {
+		return nil, errors.New("badge: missing text")
+	}
+	textColor, err := parseHexColor(step.Color, color.White)
+	if err != nil {
+		return nil, fmt.Errorf("badge: %w", err)
+	}
+	background, err := parseHexColor(step.Background, color.NRGBA{R: 0xd3, G: 0x2f, B: 0x2f, A: 0xff})
+	if err != nil {
+		return nil, fmt.Errorf("badge: %w", err)
+	}
+	size := step.Size
+	if size <= 0 {
+		size = 32
+	}
+
+	face, err := newFace(boldFont, float64(size))
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	// Size the badge to fit the text, with half the font size as padding on all sides.
+	padding := size / 2
+	metrics := face.Metrics()
+	textWidth := font.MeasureString(face, step.Text).Ceil()
+	textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+	badge := imaging.New(textWidth+2*padding, textHeight+2*padding, background)
+	drawer := &font.Drawer{
+		Dst:  badge,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.P(padding, padding+metrics.Ascent.Ceil()),
+	}
+	drawer.DrawString(step.Text)
+
+	pos, err := anchorPosition(img.Bounds().Size(), badge.Bounds().Size(), step.Position, "top-right", step.Margin)
+	if err != nil {
+		return nil, fmt.Errorf("badge: %w", err)
+	}
+	return imaging.Overlay(img, badge, pos, 1), nil
+}
+
+func applyPad(img image.Image, step *conf.PadStep) (image.Image, error) {
+	if step.Size <= 0 {
+		return img, nil
+	}
+	background, err := parseHexColor(step.Color, color.White)
+	if err != nil {
+		return nil, fmt.Errorf("pad: %w", err)
+	}
+	size := img.Bounds().Size()
+	canvas := imaging.New(size.X+2*step.Size, size.Y+2*step.Size, background)
+	return imaging.Overlay(canvas, img, image.Pt(step.Size, step.Size), 1), nil
+}
+
+// applyRound makes the corners outside a circle of the given radius transparent, anti-aliasing the edge.
+func applyRound(img image.Image, step *conf.RoundStep) image.Image {
+	out := imaging.Clone(img)
+	w, h := out.Bounds().Dx(), out.Bounds().Dy()
+	r := min(step.Radius, w/2, h/2)
+	if r <= 0 {
+		return out
+	}
+	for y := range r {
+		for x := range r {
+			// Distance from the center of this pixel to the center of the corner’s circle.
+			d := math.Hypot(float64(r)-float64(x)-0.5, float64(r)-float64(y)-0.5)
+			coverage := math.Max(0, math.Min(1, float64(r)-d+0.5))
+			if coverage == 1 {
+				continue
+			}
+			for _, p := range []image.Point{{x, y}, {w - 1 - x, y}, {x, h - 1 - y}, {w - 1 - x, h - 1 - y}} {
+				c := out.NRGBAAt(p.X, p.Y)
+				c.A = uint8(float64(c.A) * coverage)
+				out.SetNRGBA(p.X, p.Y, c)
+			}
+		}
+	}
+	return out
+}
+
+func applyFit(img image.Image, step *conf.FitStep) (image.Image, error) {
+	if step.Width <= 0 || step.Height <= 0 {
+		return nil, errors.New("fit: width and height must be positive")
+	}
+	switch step.Mode {
+	case "", "cover":
+		return imaging.Fill(img, step.Width, step.Height, imaging.Center, imaging.Lanczos), nil
+	case "contain":
+		background, err := parseHexColor(step.Background, color.White)
+		if err != nil {
+			return nil, fmt.Errorf("fit: %w", err)
+		}
+		canvas := imaging.New(step.Width, step.Height, background)
+		return imaging.OverlayCenter(canvas, imaging.Fit(img, step.Width, step.Height, imaging.Lanczos), 1), nil
+	default:
+		return nil, fmt.Errorf("fit: unsupported mode: %s", step.Mode)
+	}
+}
+
+// anchorPosition returns the top-left point at which to draw an item of the given size within
+// a container, so that it is placed at the named position, inset by margin pixels.
+func anchorPosition(container, item image.Point, position, defaultPosition string, margin int) (image.Point, error) {
+	if position == "" {
+		position = defaultPosition
+	}
+	left, top := margin, margin
+	right, bottom := container.X-item.X-margin, container.Y-item.Y-margin
+	switch position {
+	case "top-left":
+		return image.Pt(left, top), nil
+	case "top-right":
+		return image.Pt(right, top), nil
+	case "bottom-left":
+		return image.Pt(left, bottom), nil
+	case "bottom-right":
+		return image.Pt(right, bottom), nil
+	case "center":
+		return image.Pt((container.X-item.X)/2, (container.Y-item.Y)/2), nil
+	default:
+		return image.Point{}, fmt.Errorf("unsupported position: %s", position)
+	}
+}
+
+// parseHexColor parses colors in the form “#rrggbb” or “#rrggbbaa”, returning defaultColor if empty.
+func parseHexColor(s string, defaultColor color.Color) (color.Color, error) {
+	if s == "" {
+		return defaultColor, nil
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || (len(b) != 3 && len(b) != 4) {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
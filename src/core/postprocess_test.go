@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+// encodeTestPNG returns a PNG of the given size, filled with a single color.
+func encodeTestPNG(t *testing.T, width, height int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decodeTestPNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode output PNG: %v", err)
+	}
+	return img
+}
+
+func TestProcessImage_NoSteps(t *testing.T) {
+	input := encodeTestPNG(t, 10, 10, color.White)
+	output, err := ProcessImage(input, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !bytes.Equal(input, output) {
+		t.Error("Expected input to be returned unchanged")
+	}
+}
+
+func TestProcessImage_Pad(t *testing.T) {
+	input := encodeTestPNG(t, 100, 50, color.White)
+	output, err := ProcessImage(input, []conf.ImageStep{{Pad: &conf.PadStep{Size: 10, Color: "#ff0000"}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	img := decodeTestPNG(t, output)
+	if size := img.Bounds().Size(); size != image.Pt(120, 70) {
+		t.Errorf("Expected 120x70, got %v", size)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 0xff || g != 0 || b != 0 {
+		t.Errorf("Expected red border, got %v", img.At(0, 0))
+	}
+	if r, g, b, _ := img.At(60, 35).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+		t.Errorf("Expected white center, got %v", img.At(60, 35))
+	}
+}
+
+func TestProcessImage_Fit(t *testing.T) {
+	input := encodeTestPNG(t, 200, 100, color.White)
+	for _, mode := range []string{"cover", "contain"} {
+		t.Run(mode, func(t *testing.T) {
+			output, err := ProcessImage(input, []conf.ImageStep{{Fit: &conf.FitStep{Width: 120, Height: 120, Mode: mode}}})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if size := decodeTestPNG(t, output).Bounds().Size(); size != image.Pt(120, 120) {
+				t.Errorf("Expected 120x120, got %v", size)
+			}
+		})
+	}
+}
+
+func TestProcessImage_Round(t *testing.T) {
+	input := encodeTestPNG(t, 100, 100, color.White)
+	output, err := ProcessImage(input, []conf.ImageStep{{Round: &conf.RoundStep{Radius: 20}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	img := decodeTestPNG(t, output)
+	for _, p := range []image.Point{{0, 0}, {99, 0}, {0, 99}, {99, 99}} {
+		if _, _, _, a := img.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("Expected transparent corner at %v, got alpha %d", p, a)
+		}
+	}
+	if _, _, _, a := img.At(50, 50).RGBA(); a != 0xffff {
+		t.Errorf("Expected opaque center, got alpha %d", a)
+	}
+}
+
+func TestProcessImage_Badge(t *testing.T) {
+	input := encodeTestPNG(t, 400, 200, color.White)
+	output, err := ProcessImage(input, []conf.ImageStep{{Badge: &conf.BadgeStep{Text: "DRAFT", Background: "#000000"}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	img := decodeTestPNG(t, output)
+	// Defaults to the top-right corner, so the top-left corner stays white.
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 != 0xff {
+		t.Errorf("Expected top-left to remain white, got %v", img.At(0, 0))
+	}
+	if r, _, _, _ := img.At(399, 0).RGBA(); r != 0 {
+		t.Errorf("Expected badge background at top-right, got %v", img.At(399, 0))
+	}
+}
+
+func TestProcessImage_Overlay(t *testing.T) {
+	conf.Config.DataDir = t.TempDir()
+	logo := encodeTestPNG(t, 10, 10, color.Black)
+	if err := os.WriteFile(filepath.Join(conf.Config.DataDir, "logo.png"), logo, 0o644); err != nil {
+		t.Fatalf("Failed to write logo: %v", err)
+	}
+
+	input := encodeTestPNG(t, 100, 100, color.White)
+	output, err := ProcessImage(input, []conf.ImageStep{
+		{Overlay: &conf.OverlayStep{File: "logo.png", Position: "bottom-right", Margin: 5, Width: 20}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	img := decodeTestPNG(t, output)
+	if r, _, _, _ := img.At(85, 85).RGBA(); r != 0 {
+		t.Errorf("Expected logo at bottom-right, got %v", img.At(85, 85))
+	}
+	if r, _, _, _ := img.At(97, 97).RGBA(); r>>8 != 0xff {
+		t.Errorf("Expected margin to remain white, got %v", img.At(97, 97))
+	}
+}
+
+func TestProcessImage_InvalidSteps(t *testing.T) {
+	input := encodeTestPNG(t, 10, 10, color.White)
+
+	_, err := ProcessImage(input, []conf.ImageStep{{}})
+	if !errors.Is(err, ErrInvalidImageStep) {
+		t.Errorf("Expected ErrInvalidImageStep for empty step, got: %v", err)
+	}
+
+	_, err = ProcessImage(input, []conf.ImageStep{{Pad: &conf.PadStep{Size: 1}, Round: &conf.RoundStep{Radius: 1}}})
+	if !errors.Is(err, ErrInvalidImageStep) {
+		t.Errorf("Expected ErrInvalidImageStep for multiple operations, got: %v", err)
+	}
+
+	if _, err := ProcessImage(input, []conf.ImageStep{{Pad: &conf.PadStep{Size: 1, Color: "red"}}}); err == nil {
+		t.Error("Expected an error for an invalid color")
+	}
+}
@@ -4,3 +4,12 @@ package core
 func Ptr[T any](x T) *T {
 	return &x
 }
+
+// Deref returns the value pointed to by p, or the zero value if p is nil.
+func Deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
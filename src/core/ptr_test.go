@@ -145,3 +145,15 @@ func TestPtr(t *testing.T) {
 		}
 	})
 }
+
+func TestDeref(t *testing.T) {
+	if got := Deref(Ptr("hello")); got != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", got)
+	}
+	if got := Deref[string](nil); got != "" {
+		t.Errorf("Expected empty string for nil, got %q", got)
+	}
+	if got := Deref[int](nil); got != 0 {
+		t.Errorf("Expected 0 for nil, got %d", got)
+	}
+}
@@ -0,0 +1,320 @@
+package core
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+)
+
+// Quantization reduces screenshots to a palette of at most 256 colors, in the style of pngquant: a palette
+// is chosen by median cut, refined using a few rounds of k-means, and then applied with dithering. Since
+// Link Previews are mostly flat colors, text, and gradients, this typically shrinks them considerably,
+// compared to lossless compression alone, without any visible difference.
+
+const (
+	// kMeansIterations refines the median cut palette; quality gains taper off quickly after the first few.
+	kMeansIterations = 3
+	// maxHistogramSize bounds the work needed for images with many distinct colors, such as photos, by
+	// grouping similar colors together, down to a precision of minHistogramBits per channel.
+	maxHistogramSize = 1 << 15
+	minHistogramBits = 5
+	// ditherStrength scales the error diffused to neighboring pixels. Full-strength dithering adds so much
+	// noise that PNGs barely compress, while none at all leaves visible bands in gradients.
+	ditherStrength = 0.5
+)
+
+// weightedColor is a group of similar colors in an image, along with the number of pixels in the group.
+type weightedColor struct {
+	c     [4]float64 // Mean premultiplied R, G, B, A, from 0 to 255.
+	count int
+}
+
+// Quantize reduces an image to at most maxColors colors, and returns it along with its quality, from 0 to
+// 100 (lossless). Quality is measured before dithering, as the error between each color & its nearest
+// palette color, mapped linearly from a PSNR of 20 dB (0) to 50 dB (100); it is approximate for images
+// with so many colors that they had to be grouped.
+func Quantize(img image.Image, maxColors int) (*image.Paletted, int) {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	// Images with few enough colors need no quantization at all, so they stay lossless.
+	if exact := exactPalette(rgba, maxColors); exact != nil {
+		paletted := image.NewPaletted(rgba.Bounds(), exact)
+		indices := make(map[color.RGBA]uint8, len(exact))
+		for i, c := range exact {
+			indices[c.(color.RGBA)] = uint8(i)
+		}
+		for i := 0; i < len(rgba.Pix); i += 4 {
+			paletted.Pix[i/4] = indices[color.RGBA{R: rgba.Pix[i], G: rgba.Pix[i+1], B: rgba.Pix[i+2], A: rgba.Pix[i+3]}]
+		}
+		return paletted, 100
+	}
+
+	histogram := colorHistogram(rgba)
+	centers := medianCut(histogram, maxColors)
+	for range kMeansIterations {
+		centers = refineKMeans(histogram, centers)
+	}
+	palette := make(color.Palette, len(centers))
+	for i, c := range centers {
+		palette[i] = toRGBA(c)
+	}
+	return dither(rgba, palette), paletteQuality(histogram, palette)
+}
+
+// exactPalette returns every distinct color in an image, or nil if there are more than maxColors.
+func exactPalette(img *image.RGBA, maxColors int) color.Palette {
+	seen := map[color.RGBA]bool{}
+	var palette color.Palette
+	for i := 0; i < len(img.Pix); i += 4 {
+		c := color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+		if seen[c] {
+			continue
+		}
+		if len(palette) == maxColors {
+			return nil
+		}
+		seen[c] = true
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// colorHistogram groups the colors in an image, at the highest precision per channel that keeps the number
+// of groups below maxHistogramSize.
+func colorHistogram(img *image.RGBA) []weightedColor {
+	var cells map[uint32]*weightedColor
+	for bits := 8; bits >= minHistogramBits; bits-- {
+		cells = map[uint32]*weightedColor{}
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := img.Pix[i : i+4 : i+4]
+			key := histogramKey(p[0], p[1], p[2], p[3], bits)
+			cell, ok := cells[key]
+			if !ok {
+				cell = &weightedColor{}
+				cells[key] = cell
+			}
+			for ch := range 4 {
+				cell.c[ch] += float64(p[ch])
+			}
+			cell.count++
+		}
+		if len(cells) <= maxHistogramSize {
+			break
+		}
+	}
+	histogram := make([]weightedColor, 0, len(cells))
+	for _, cell := range cells {
+		for ch := range 4 {
+			cell.c[ch] /= float64(cell.count)
+		}
+		histogram = append(histogram, *cell)
+	}
+	return histogram
+}
+
+// histogramKey identifies the group to which a color belongs, using only the top bits of each channel.
+func histogramKey(r, g, b, a uint8, bits int) uint32 {
+	shift := 8 - bits
+	return uint32(r>>shift)<<(3*bits) | uint32(g>>shift)<<(2*bits) | uint32(b>>shift)<<bits | uint32(a>>shift)
+}
+
+// medianCut splits the color space into at most n boxes, each time splitting the box with the largest
+// weighted spread along its widest channel, at the median pixel, and returns the mean color of each box.
+func medianCut(histogram []weightedColor, n int) [][4]float64 {
+	boxes := [][]weightedColor{histogram}
+	for len(boxes) < n {
+		best, bestChannel, bestScore := -1, 0, 0.0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, spread := widestChannel(box)
+			if score := spread * float64(boxWeight(box)); score > bestScore {
+				best, bestChannel, bestScore = i, channel, score
+			}
+		}
+		if best < 0 {
+			break // Every remaining box holds a single color.
+		}
+
+		box := boxes[best]
+		slices.SortFunc(box, func(a, b weightedColor) int { return cmp.Compare(a.c[bestChannel], b.c[bestChannel]) })
+		half, seen, split := boxWeight(box)/2, 0, 1
+		for i, wc := range box[:len(box)-1] {
+			seen += wc.count
+			split = i + 1
+			if seen >= half {
+				break
+			}
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	centers := make([][4]float64, len(boxes))
+	for i, box := range boxes {
+		centers[i] = boxMean(box)
+	}
+	return centers
+}
+
+func widestChannel(box []weightedColor) (int, float64) {
+	lo := [4]float64{255, 255, 255, 255}
+	var hi [4]float64
+	for _, wc := range box {
+		for ch, v := range wc.c {
+			lo[ch], hi[ch] = min(lo[ch], v), max(hi[ch], v)
+		}
+	}
+	channel := 0
+	for ch := range 4 {
+		if hi[ch]-lo[ch] > hi[channel]-lo[channel] {
+			channel = ch
+		}
+	}
+	return channel, hi[channel] - lo[channel]
+}
+
+func boxWeight(box []weightedColor) int {
+	total := 0
+	for _, wc := range box {
+		total += wc.count
+	}
+	return total
+}
+
+func boxMean(box []weightedColor) [4]float64 {
+	var sum [4]float64
+	total := 0.0
+	for _, wc := range box {
+		for ch := range 4 {
+			sum[ch] += wc.c[ch] * float64(wc.count)
+		}
+		total += float64(wc.count)
+	}
+	for ch := range 4 {
+		sum[ch] /= total
+	}
+	return sum
+}
+
+// refineKMeans moves each center to the mean of the colors nearest to it; centers with no colors are kept.
+func refineKMeans(histogram []weightedColor, centers [][4]float64) [][4]float64 {
+	sums := make([][4]float64, len(centers))
+	totals := make([]float64, len(centers))
+	for _, wc := range histogram {
+		i, _ := nearest(centers, wc.c)
+		for ch := range 4 {
+			sums[i][ch] += wc.c[ch] * float64(wc.count)
+		}
+		totals[i] += float64(wc.count)
+	}
+	refined := make([][4]float64, len(centers))
+	for i := range centers {
+		if totals[i] == 0 {
+			refined[i] = centers[i]
+			continue
+		}
+		for ch := range 4 {
+			refined[i][ch] = sums[i][ch] / totals[i]
+		}
+	}
+	return refined
+}
+
+func nearest(centers [][4]float64, c [4]float64) (int, float64) {
+	best, bestDistance := 0, math.Inf(1)
+	for i, center := range centers {
+		if d := sqDistance(center, c); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best, bestDistance
+}
+
+func sqDistance(a, b [4]float64) float64 {
+	d := 0.0
+	for ch := range 4 {
+		d += (a[ch] - b[ch]) * (a[ch] - b[ch])
+	}
+	return d
+}
+
+// dither maps each pixel to the palette using Floyd–Steinberg error diffusion. Nearest palette colors are
+// looked up once per histogram cell, rather than once per pixel, which is much faster, and close enough,
+// since any error is diffused anyway.
+func dither(img *image.RGBA, palette color.Palette) *image.Paletted {
+	centers := make([][4]float64, len(palette))
+	for i, p := range palette {
+		c := p.(color.RGBA)
+		centers[i] = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+	lookup := map[uint32]uint8{}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	paletted := image.NewPaletted(bounds, palette)
+	// Errors for the current & next rows, with a pixel of padding on either side.
+	current, next := make([][4]float64, width+2), make([][4]float64, width+2)
+	for y := range bounds.Dy() {
+		for x := range width {
+			offset := y*img.Stride + x*4
+			var want [4]float64
+			for ch := range 4 {
+				want[ch] = min(max(float64(img.Pix[offset+ch])+current[x+1][ch]*ditherStrength, 0), 255)
+			}
+			key := histogramKey(uint8(want[0]), uint8(want[1]), uint8(want[2]), uint8(want[3]), minHistogramBits)
+			index, ok := lookup[key]
+			if !ok {
+				i, _ := nearest(centers, want)
+				index = uint8(i)
+				lookup[key] = index
+			}
+			paletted.Pix[y*paletted.Stride+x] = index
+			for ch := range 4 {
+				e := want[ch] - centers[index][ch]
+				current[x+2][ch] += e * 7 / 16
+				next[x][ch] += e * 3 / 16
+				next[x+1][ch] += e * 5 / 16
+				next[x+2][ch] += e * 1 / 16
+			}
+		}
+		current, next = next, current
+		clear(next)
+	}
+	return paletted
+}
+
+// paletteQuality maps the mean squared error of replacing each color with its nearest palette color to a
+// score from 0 to 100, via PSNR.
+func paletteQuality(histogram []weightedColor, palette color.Palette) int {
+	centers := make([][4]float64, len(palette))
+	for i, p := range palette {
+		c := p.(color.RGBA)
+		centers[i] = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+	sum, total := 0.0, 0
+	for _, wc := range histogram {
+		_, d := nearest(centers, wc.c)
+		sum += d * float64(wc.count)
+		total += wc.count
+	}
+	if total == 0 || sum == 0 {
+		return 100
+	}
+	mse := sum / float64(total) / 4 // Per channel.
+	psnr := 10 * math.Log10(255*255/mse)
+	return int(math.Round(min(max((psnr-20)/30*100, 0), 100)))
+}
+
+func toRGBA(c [4]float64) color.RGBA {
+	round := func(v float64) uint8 { return uint8(min(max(math.Round(v), 0), 255)) }
+	rgba := color.RGBA{R: round(c[0]), G: round(c[1]), B: round(c[2]), A: round(c[3])}
+	// Premultiplied channels can never exceed alpha.
+	rgba.R, rgba.G, rgba.B = min(rgba.R, rgba.A), min(rgba.G, rgba.A), min(rgba.B, rgba.A)
+	return rgba
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+// gradientTestImage returns an image with many more than 256 colors, like a typical Link Preview.
+func gradientTestImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 0x80, A: 0xff})
+		}
+	}
+	return img
+}
+
+func TestQuantize_FewColorsStayLossless(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 60), G: uint8(y * 60), B: 0x10, A: 0xff})
+		}
+	}
+	paletted, quality := Quantize(img, 256)
+	if quality != 100 {
+		t.Errorf("Quantize() quality = %d, want 100", quality)
+	}
+	if len(paletted.Palette) != 16 {
+		t.Errorf("Quantize() palette has %d colors, want 16", len(paletted.Palette))
+	}
+	for y := range 4 {
+		for x := range 4 {
+			want := color.RGBAModel.Convert(img.At(x, y))
+			if got := color.RGBAModel.Convert(paletted.At(x, y)); got != want {
+				t.Errorf("Quantize() pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestQuantize_ManyColors(t *testing.T) {
+	paletted, quality := Quantize(gradientTestImage(256, 256), 64)
+	if len(paletted.Palette) > 64 {
+		t.Errorf("Quantize() palette has %d colors, want at most 64", len(paletted.Palette))
+	}
+	if quality <= 0 || quality >= 100 {
+		t.Errorf("Quantize() quality = %d, want between 0 & 100", quality)
+	}
+}
+
+func TestCompressPNG_QualityFloor(t *testing.T) {
+	// Rendered cards have gradients & anti-aliased text, like most real Link Previews.
+	input, err := RenderCard(Card{
+		Title:       "Butterfly Social: automated link previews for your site",
+		Description: "Social link preview images, sourced directly from your Web pages",
+	})
+	if err != nil {
+		t.Fatalf("RenderCard() error = %v", err)
+	}
+	t.Cleanup(func() { conf.Config.Compression.MinQuality = 0 })
+
+	conf.Config.Compression.MinQuality = 50
+	lossy, err := CompressPNG(input)
+	if err != nil {
+		t.Fatalf("CompressPNG() error = %v", err)
+	}
+	if _, ok := decodeTestPNG(t, lossy).(*image.Paletted); !ok {
+		t.Errorf("CompressPNG() did not quantize above the quality floor")
+	}
+
+	conf.Config.Compression.MinQuality = 100
+	lossless, err := CompressPNG(input)
+	if err != nil {
+		t.Fatalf("CompressPNG() error = %v", err)
+	}
+	original, compressed := decodeTestPNG(t, input), decodeTestPNG(t, lossless)
+	for y := range original.Bounds().Dy() {
+		for x := range original.Bounds().Dx() {
+			if color.NRGBAModel.Convert(compressed.At(x, y)) != color.NRGBAModel.Convert(original.At(x, y)) {
+				t.Fatalf("CompressPNG() changed pixel (%d, %d) below the quality floor", x, y)
+			}
+		}
+	}
+	if len(lossy) >= len(lossless) {
+		t.Errorf("CompressPNG() quantized = %d bytes, want smaller than lossless (%d bytes)", len(lossy), len(lossless))
+	}
+}
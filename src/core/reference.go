@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	neturl "net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// imageMetaTags are the meta tags from which platforms pick the preview image for a page.
+var imageMetaTags = map[string]bool{
+	"og:image":            true,
+	"og:image:url":        true,
+	"og:image:secure_url": true,
+	"twitter:image":       true,
+	"twitter:image:src":   true,
+}
+
+// FetchImageReferences retrieves a web page, and returns the URLs of all preview images it declares in its
+// OpenGraph (og:image) & Twitter (twitter:image) meta tags, resolved against the page’s own URL.
+func FetchImageReferences(ctx context.Context, url string, opts ...PageOption) ([]string, error) {
+	doc, err := fetchPage(ctx, url, newPageOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	base, _ := neturl.Parse(url)
+
+	var references []string
+	var parse func(*html.Node)
+	parse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "property", "name": // Twitter tags are often declared using either.
+					name = strings.ToLower(attr.Val)
+				case "content":
+					content = strings.TrimSpace(attr.Val)
+				}
+			}
+			if imageMetaTags[name] && content != "" {
+				if ref, err := neturl.Parse(content); err == nil && base != nil {
+					content = base.ResolveReference(ref).String()
+				}
+				references = append(references, content)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			parse(c)
+		}
+	}
+	parse(doc)
+	return references, nil
+}
+
+// FetchCanonicalLink retrieves a web page, and returns the URL in its <link rel="canonical">, resolved
+// against the page’s own URL, or an empty string if it has none.
+func FetchCanonicalLink(ctx context.Context, url string, opts ...PageOption) (string, error) {
+	doc, err := fetchPage(ctx, url, newPageOptions(opts))
+	if err != nil {
+		return "", err
+	}
+	base, _ := neturl.Parse(url)
+
+	var canonical string
+	var parse func(*html.Node)
+	parse = func(n *html.Node) {
+		if canonical != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rel, href string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "href":
+					href = strings.TrimSpace(attr.Val)
+				}
+			}
+			if rel == "canonical" && href != "" {
+				if ref, err := neturl.Parse(href); err == nil && base != nil {
+					href = base.ResolveReference(ref).String()
+				}
+				canonical = href
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			parse(c)
+		}
+	}
+	parse(doc)
+	return canonical, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestFetchImageReferences(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+			<meta property="og:title" content="Not an image">
+			<meta property="og:image" content="https://butterfly.example.com/link-previews/v1?url=example.com">
+			<meta name="twitter:image" content=" /preview.png ">
+			<meta name="twitter:image:src" content="">
+		</head></html>`))
+	}))
+	defer server.Close()
+
+	got, err := FetchImageReferences(context.Background(), server.URL+"/blog/post")
+	if err != nil {
+		t.Fatalf("FetchImageReferences() error = %v", err)
+	}
+	want := []string{
+		"https://butterfly.example.com/link-previews/v1?url=example.com",
+		server.URL + "/preview.png",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("FetchImageReferences() = %q, want %q", got, want)
+	}
+}
+
+func TestFetchImageReferences_HttpError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	if _, err := FetchImageReferences(context.Background(), server.URL); err == nil {
+		t.Error("FetchImageReferences() should fail for a missing page")
+	}
+}
+
+func TestFetchCanonicalLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/relative":
+			w.Write([]byte(`<html><head><link rel="canonical" href="/blog/post"></head></html>`))
+		case "/absolute":
+			w.Write([]byte(`<html><head><link rel="Canonical" href="https://example.com/blog/post"></head></html>`))
+		default:
+			w.Write([]byte(`<html><head><link rel="stylesheet" href="/style.css"></head></html>`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/relative", server.URL + "/blog/post"},
+		{"/absolute", "https://example.com/blog/post"},
+		{"/none", ""},
+	}
+	for _, tt := range tests {
+		got, err := FetchCanonicalLink(context.Background(), server.URL+tt.path)
+		if err != nil {
+			t.Fatalf("FetchCanonicalLink(%q) error = %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("FetchCanonicalLink(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
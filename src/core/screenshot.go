@@ -7,25 +7,209 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"image"
 	"io"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
+	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"butterfly.chimbori.dev/conf"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 	"golang.org/x/net/html"
 )
 
 var ErrMissingSelector = errors.New("selector not found")
 
+// ErrChromeUnavailable indicates that Chrome could not be launched or reached, or that it crashed;
+// i.e., the problem lies with the browser, not with the page being rendered.
+var ErrChromeUnavailable = errors.New("chrome unavailable")
+
+// runChromedp runs actions like [chromedp.Run], but wraps browser failures with [ErrChromeUnavailable].
+func runChromedp(ctx context.Context, actions ...chromedp.Action) error {
+	err := chromedp.Run(ctx, actions...)
+	if err == nil {
+		return nil
+	}
+	var execErr *exec.Error
+	var netErr *net.OpError
+	if errors.As(err, &execErr) || errors.As(err, &netErr) ||
+		errors.Is(err, chromedp.ErrChannelClosed) ||
+		errors.Is(err, chromedp.ErrInvalidWebsocketMessage) ||
+		strings.Contains(err.Error(), "chrome failed to start") ||
+		strings.Contains(err.Error(), "websocket url timeout reached") {
+		return fmt.Errorf("%w: %w", ErrChromeUnavailable, err)
+	}
+	return err
+}
+
 // httpClient is a custom HTTP client with timeout limits.
 var httpClient = &http.Client{Timeout: 10 * time.Second}
 
-func newChromedpContext(ctx context.Context) (context.Context, context.CancelFunc) {
+// PageOption customizes how a page is fetched, both by Chrome and by the Go HTTP client.
+type PageOption func(*pageOptions)
+
+type pageOptions struct {
+	credentials *Credentials
+	emulation   Emulation
+	viewport    [2]int64 // Width & height; zero for the default.
+	diagnostics *Diagnostics
+}
+
+// linkPreviewViewport is the default viewport for Link Previews, as recommended by OpenGraph.
+var linkPreviewViewport = [2]int64{1200, 630}
+
+// Emulation overrides the browser’s defaults, for sites that localize content or support dark mode.
+// Empty fields keep Chrome’s defaults.
+type Emulation struct {
+	// Locale is a BCP 47 language tag, e.g. “de-CH”, used for Intl APIs and the Accept-Language header.
+	Locale string
+	// Timezone is an IANA timezone ID, e.g. “Europe/Zurich”.
+	Timezone string
+	// ColorScheme is emulated for the “prefers-color-scheme” media feature: “light” or “dark”.
+	ColorScheme string
+}
+
+// WithCredentials sends credentials with every request made to the page’s host.
+// Passing nil is permitted, and is equivalent to not passing this option.
+func WithCredentials(creds *Credentials) PageOption {
+	return func(o *pageOptions) {
+		o.credentials = creds
+	}
+}
+
+// WithEmulation renders the page with the given locale, timezone & color scheme.
+func WithEmulation(e Emulation) PageOption {
+	return func(o *pageOptions) {
+		o.emulation = e
+	}
+}
+
+// WithViewport renders the page in a browser window of the given size, instead of 1200×630.
+func WithViewport(width, height int) PageOption {
+	return func(o *pageOptions) {
+		o.viewport = [2]int64{int64(width), int64(height)}
+	}
+}
+
+func newPageOptions(opts []PageOption) *pageOptions {
+	o := &pageOptions{viewport: linkPreviewViewport}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// chromedpActions returns actions that must be run before navigating to pageUrl.
+func (o *pageOptions) chromedpActions(ctx context.Context, pageUrl string) ([]chromedp.Action, error) {
+	actions := append(o.diagnostics.chromedpActions(ctx), o.emulation.chromedpActions()...)
+	if o.credentials.IsEmpty() {
+		return actions, nil
+	}
+	credentialActions, err := o.credentials.chromedpActions(ctx, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	return append(actions, credentialActions...), nil
+}
+
+// chromedpActions returns actions to be run before navigating, to apply these overrides.
+func (e Emulation) chromedpActions() []chromedp.Action {
+	var actions []chromedp.Action
+	if e.Locale != "" {
+		actions = append(actions,
+			emulation.SetLocaleOverride().WithLocale(e.Locale),
+			network.Enable(),
+			network.SetExtraHTTPHeaders(network.Headers{"Accept-Language": e.Locale}),
+		)
+	}
+	if e.Timezone != "" {
+		actions = append(actions, emulation.SetTimezoneOverride(e.Timezone))
+	}
+	if e.ColorScheme != "" {
+		actions = append(actions, emulation.SetEmulatedMedia().WithFeatures([]*emulation.MediaFeature{
+			{Name: "prefers-color-scheme", Value: e.ColorScheme},
+		}))
+	}
+	return actions
+}
+
+// browserSlots is a queue that limits the number of pages rendered simultaneously by Chrome.
+// It is shared by all endpoints, so that one cannot starve the others of memory or CPU.
+var (
+	browserSlots     chan struct{}
+	browserSlotsOnce sync.Once
+)
+
+// acquireBrowserSlot blocks until a slot is available or ctx is done, and returns a func to release it.
+func acquireBrowserSlot(ctx context.Context) (release func(), err error) {
+	browserSlotsOnce.Do(func() {
+		browserSlots = make(chan struct{}, max(conf.Config.Chrome.MaxConcurrency, 1))
+	})
+	select {
+	case browserSlots <- struct{}{}:
+		return func() { <-browserSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for Chrome: %w", ctx.Err())
+	}
+}
+
+func newChromedpContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	release, err := acquireBrowserSlot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allocCtx, cancelAlloc := newAllocator(ctx)
+	var contextOptions []chromedp.ContextOption
+	if conf.Config.Debug {
+		contextOptions = append(contextOptions, chromedp.WithErrorf(log.Printf))
+	}
+
+	if conf.Config.Chrome.RemoteUrl == "" {
+		var cancelCtx context.CancelFunc
+		ctx, cancelCtx = chromedp.NewContext(allocCtx, contextOptions...)
+		return ctx, func() {
+			cancelCtx()
+			cancelAlloc()
+			release()
+		}, nil
+	}
+
+	// A remote Chrome is shared by all requests (and possibly by other apps), so each page is opened in
+	// its own browser context, to keep cookies, credentials, and caches from leaking between requests.
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx, contextOptions...)
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancelBrowser()
+		cancelAlloc()
+		release()
+		return nil, nil, fmt.Errorf("%w: unable to connect to remote Chrome: %w", ErrChromeUnavailable, err)
+	}
+	ctx, cancelCtx := chromedp.NewContext(browserCtx, chromedp.WithNewBrowserContext())
+	return ctx, func() {
+		cancelCtx()
+		cancelBrowser()
+		cancelAlloc()
+		release()
+	}, nil
+}
+
+// newAllocator connects to a remote Chrome if one is configured, otherwise launches a local one.
+func newAllocator(ctx context.Context) (context.Context, context.CancelFunc) {
+	if conf.Config.Chrome.RemoteUrl != "" {
+		// Both “ws://” and “http://” URLs are supported; for the latter, the WebSocket URL is
+		// discovered via the “/json/version” endpoint.
+		return chromedp.NewRemoteAllocator(ctx, conf.Config.Chrome.RemoteUrl)
+	}
+
 	options := []chromedp.ExecAllocatorOption{
 		chromedp.NoFirstRun,
 		chromedp.NoDefaultBrowserCheck,
@@ -34,52 +218,66 @@ func newChromedpContext(ctx context.Context) (context.Context, context.CancelFun
 		chromedp.Headless,
 		chromedp.Flag("disable-setuid-sandbox", true),
 	}
-
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, options...)
-	var cancelCtx context.CancelFunc
-	if conf.Config.Debug {
-		ctx, cancelCtx = chromedp.NewContext(allocCtx, chromedp.WithErrorf(log.Printf))
-	} else {
-		ctx, cancelCtx = chromedp.NewContext(allocCtx)
+	if conf.Config.Chrome.ExecPath != "" {
+		options = append(options, chromedp.ExecPath(conf.Config.Chrome.ExecPath))
+	}
+	for name, value := range parseChromeFlags(conf.Config.Chrome.Flags) {
+		options = append(options, chromedp.Flag(name, value))
 	}
+	return chromedp.NewExecAllocator(ctx, options...)
+}
 
-	return ctx, func() {
-		cancelCtx()
-		cancelAlloc()
+// parseChromeFlags parses command-line flags such as “--disable-dev-shm-usage” or “--lang=en-US”.
+// Flags without a value are enabled; flags with a value of “false” are disabled.
+func parseChromeFlags(flags []string) map[string]any {
+	parsed := make(map[string]any, len(flags))
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(strings.TrimSpace(flag), "-"), "=")
+		if name == "" {
+			continue
+		}
+		switch {
+		case !hasValue:
+			parsed[name] = true
+		case value == "false":
+			parsed[name] = false
+		default:
+			parsed[name] = value
+		}
 	}
+	return parsed
 }
 
 // TakeScreenshot captures a high-resolution PNG screenshot of a specific element on a web page.
 // It navigates to the provided URL, ensures the element specified by the CSS selector is visible,
 // and takes a screenshot.
-func TakeScreenshot(ctx context.Context, url, selector string) (png []byte, err error) {
+func TakeScreenshot(ctx context.Context, url, selector string, opts ...PageOption) (png []byte, err error) {
 	slog.Debug("takeScreenshot", "url", url, "selector", selector)
 
-	var cancel context.CancelFunc
-	ctx, cancel = newChromedpContext(ctx)
+	ctx, cancel, err := newChromedpContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer cancel()
 
 	if selector == "" {
 		return nil, fmt.Errorf("missing selector")
 	}
 
-	// Un-hide the selected element before attempting a screenshot.
-	js := fmt.Sprintf(`(function() {
-		var el = document.querySelector(%s);
-		if (el) {
-			el.style.visibility = '';
-			el.style.display = 'block';
-			return true;
-		}
-		return false;
-	})()`, strconv.Quote(selector))
+	o := newPageOptions(opts)
+	setupActions, err := o.chromedpActions(ctx, url)
+	if err != nil {
+		return nil, err
+	}
 
+	// Un-hide the selected element before attempting a screenshot.
 	var foundSelector bool
 	var buf []byte
-	if err := chromedp.Run(ctx,
-		chromedp.EmulateViewport(1200, 630),
+	if err := runChromedp(ctx,
+		chromedp.Tasks(setupActions),
+		chromedp.EmulateViewport(o.viewport[0], o.viewport[1]),
 		chromedp.Navigate(url),
-		chromedp.Evaluate(js, &foundSelector),
+		chromedp.Evaluate(revealElementJs(selector), &foundSelector),
 	); err != nil {
 		return nil, err
 	}
@@ -87,7 +285,7 @@ func TakeScreenshot(ctx context.Context, url, selector string) (png []byte, err
 		return nil, ErrMissingSelector
 	}
 
-	if err := chromedp.Run(ctx,
+	if err := runChromedp(ctx,
 		chromedp.WaitVisible(selector, chromedp.ByQuery),
 		chromedp.Sleep(time.Second), // Allow fonts to finish downloading.
 		chromedp.Screenshot(selector, &buf),
@@ -98,18 +296,35 @@ func TakeScreenshot(ctx context.Context, url, selector string) (png []byte, err
 	return buf, nil
 }
 
+// revealElementJs returns a script that un-hides the element matching selector, and returns whether
+// such an element was found.
+func revealElementJs(selector string) string {
+	return fmt.Sprintf(`(function() {
+		var el = document.querySelector(%s);
+		if (el) {
+			el.style.visibility = '';
+			el.style.display = 'block';
+			return true;
+		}
+		return false;
+	})()`, strconv.Quote(selector))
+}
+
 // TakeScreenshotWithTemplate renders a provided HTML template with the given title and description,
 // and then takes a screenshot of the result. The template is parsed as a Golang template, with fields
-// `{{.Title}}`, `{{.Description}}`, and `{{.Url}}`.
-func TakeScreenshotWithTemplate(ctx context.Context, templateContent, url, selector, title, description string) ([]byte, error) {
+// `{{.Title}}`, `{{.Description}}`, and `{{.Url}}`. Credentials in opts are ignored, since the template
+// is not fetched from anywhere.
+func TakeScreenshotWithTemplate(ctx context.Context, templateContent, url, selector, title, description string, opts ...PageOption) ([]byte, error) {
 	slog.Debug("takeScreenshotWithTemplate",
 		"url", url,
 		"selector", selector,
 		"title", title,
 		"description", description)
 
-	var cancel context.CancelFunc
-	ctx, cancel = newChromedpContext(ctx)
+	ctx, cancel, err := newChromedpContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer cancel()
 
 	tmpl, err := template.New("screenshot").Parse(templateContent)
@@ -130,9 +345,12 @@ func TakeScreenshotWithTemplate(ctx context.Context, templateContent, url, selec
 		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
+	o := newPageOptions(opts)
 	var screenshotBuf []byte
-	if err := chromedp.Run(ctx,
-		chromedp.EmulateViewport(1200, 630),
+	if err := runChromedp(ctx,
+		chromedp.Tasks(o.diagnostics.chromedpActions(ctx)),
+		chromedp.Tasks(o.emulation.chromedpActions()),
+		chromedp.EmulateViewport(o.viewport[0], o.viewport[1]),
 		chromedp.Navigate("data:text/html;base64,"+base64.StdEncoding.EncodeToString(tmplBuf.Bytes())),
 		chromedp.WaitVisible(selector, chromedp.ByQuery),
 		chromedp.Sleep(time.Second), // Allow fonts to finish downloading.
@@ -144,41 +362,100 @@ func TakeScreenshotWithTemplate(ctx context.Context, templateContent, url, selec
 	return screenshotBuf, nil
 }
 
-// FetchTitleAndDescription retrieves the title and description from a web page.
-// OpenGraph tags are preferred (og:title, og:description), but document title is used as a fallback.
-func FetchTitleAndDescription(ctx context.Context, url string) (title, description string, err error) {
-	var doc *html.Node
+// CaptureMode selects which part of a page is captured by [TakePageScreenshot].
+type CaptureMode string
 
-	// Handle data URIs directly
-	if htmlContent, ok := strings.CutPrefix(url, "data:text/html,"); ok {
-		doc, err = html.Parse(strings.NewReader(htmlContent))
-		if err != nil {
-			return "", "", err
-		}
-	} else {
-		// Handle HTTP(S) URLs
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			return "", "", err
-		}
-		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Butterfly/1.0; +https://butterfly.chimbori.dev)")
+const (
+	// CaptureViewport captures what a user would see above the fold.
+	CaptureViewport CaptureMode = "viewport"
+	// CaptureFullPage captures the entire scrollable page, up to a maximum height.
+	CaptureFullPage CaptureMode = "fullpage"
+	// CaptureClip captures an arbitrary rectangle of the page, in CSS pixels.
+	CaptureClip CaptureMode = "clip"
+)
 
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return "", "", err
-		}
-		defer resp.Body.Close()
+// Capture describes the region of a page to be captured by [TakePageScreenshot].
+type Capture struct {
+	Mode           CaptureMode
+	ViewportWidth  int
+	ViewportHeight int
+	// MaxHeight caps the height of [CaptureFullPage] screenshots.
+	MaxHeight int
+	// Clip is only used for [CaptureClip].
+	Clip image.Rectangle
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return "", "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-		}
+// TakePageScreenshot captures a PNG screenshot of a web page as a whole, instead of a single element.
+func TakePageScreenshot(ctx context.Context, url string, capture Capture, opts ...PageOption) ([]byte, error) {
+	slog.Debug("takePageScreenshot", "url", url, "mode", capture.Mode)
 
-		// Limit response body to 10MB to prevent memory exhaustion
-		limitedReader := io.LimitReader(resp.Body, 10*1024*1024)
-		doc, err = html.Parse(limitedReader)
-		if err != nil {
-			return "", "", err
-		}
+	ctx, cancel, err := newChromedpContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	setupActions, err := newPageOptions(opts).chromedpActions(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	if err := runChromedp(ctx,
+		chromedp.Tasks(setupActions),
+		chromedp.EmulateViewport(int64(capture.ViewportWidth), int64(capture.ViewportHeight)),
+		chromedp.Navigate(url),
+		chromedp.Sleep(time.Second), // Allow fonts to finish downloading.
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			screenshot := page.CaptureScreenshot().
+				WithFormat(page.CaptureScreenshotFormatPng).
+				WithFromSurface(true)
+
+			switch capture.Mode {
+			case CaptureViewport:
+				// No clipping required.
+
+			case CaptureFullPage:
+				_, _, _, _, _, contentSize, err := page.GetLayoutMetrics().Do(ctx)
+				if err != nil {
+					return err
+				}
+				height := min(contentSize.Height, float64(capture.MaxHeight))
+				screenshot = screenshot.
+					WithCaptureBeyondViewport(true).
+					WithClip(&page.Viewport{Width: float64(capture.ViewportWidth), Height: height, Scale: 1})
+
+			case CaptureClip:
+				screenshot = screenshot.
+					WithCaptureBeyondViewport(true).
+					WithClip(&page.Viewport{
+						X:      float64(capture.Clip.Min.X),
+						Y:      float64(capture.Clip.Min.Y),
+						Width:  float64(capture.Clip.Dx()),
+						Height: float64(capture.Clip.Dy()),
+						Scale:  1,
+					})
+
+			default:
+				return fmt.Errorf("unsupported capture mode: %s", capture.Mode)
+			}
+
+			var err error
+			buf, err = screenshot.Do(ctx)
+			return err
+		}),
+	); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// FetchTitleAndDescription retrieves the title and description from a web page.
+// OpenGraph tags are preferred (og:title, og:description), but document title is used as a fallback.
+func FetchTitleAndDescription(ctx context.Context, url string, opts ...PageOption) (title, description string, err error) {
+	doc, err := fetchPage(ctx, url, newPageOptions(opts))
+	if err != nil {
+		return "", "", err
 	}
 
 	var ogTitle, ogDesc, docTitle string
@@ -220,3 +497,50 @@ func FetchTitleAndDescription(ctx context.Context, url string) (title, descripti
 	description = ogDesc
 	return strings.TrimSpace(title), strings.TrimSpace(description), nil
 }
+
+// fetchPage retrieves and parses a web page, or the HTML within a data URI.
+func fetchPage(ctx context.Context, url string, o *pageOptions) (*html.Node, error) {
+	// Handle data URIs directly
+	if htmlContent, ok := strings.CutPrefix(url, "data:text/html,"); ok {
+		return html.Parse(strings.NewReader(htmlContent))
+	}
+
+	// Handle HTTP(S) URLs
+	resp, err := httpGet(ctx, url, o)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Limit response body to 10MB to prevent memory exhaustion
+	return html.Parse(io.LimitReader(resp.Body, 10*1024*1024))
+}
+
+// httpGet fetches a URL using the Go HTTP client, with credentials if any, and fails unless the response
+// is HTTP 200. The caller must close the response body.
+func httpGet(ctx context.Context, url string, o *pageOptions) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Butterfly/1.0; +https://butterfly.chimbori.dev)")
+	if o.emulation.Locale != "" {
+		req.Header.Set("Accept-Language", o.emulation.Locale)
+	}
+
+	client := httpClient
+	if !o.credentials.IsEmpty() {
+		o.credentials.applyToRequest(req)
+		client = o.credentials.scopedClient(httpClient, req.URL.Hostname())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	return resp, nil
+}
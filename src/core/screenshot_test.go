@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -195,6 +197,25 @@ func TestFetchTitleAndDescription(t *testing.T) {
 	}
 }
 
+func TestFetchTitleAndDescription_WithLocale(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Language") == "de-CH" {
+			w.Write([]byte(`<html><head><title>Willkommen</title></head></html>`))
+		} else {
+			w.Write([]byte(`<html><head><title>Welcome</title></head></html>`))
+		}
+	}))
+	defer server.Close()
+
+	title, _, err := FetchTitleAndDescription(context.Background(), server.URL, WithEmulation(Emulation{Locale: "de-CH"}))
+	if err != nil {
+		t.Fatalf("fetchTitleAndDescription failed: %v", err)
+	}
+	if title != "Willkommen" {
+		t.Errorf("expected localized title 'Willkommen', got '%s'", title)
+	}
+}
+
 func TestTakeScreenshotWithTemplate(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -227,3 +248,48 @@ func TestTakeScreenshotWithTemplate(t *testing.T) {
 
 	assertValidPNG(t, png)
 }
+
+func TestAcquireBrowserSlot_WaitsForRelease(t *testing.T) {
+	release, err := acquireBrowserSlot(context.Background())
+	if err != nil {
+		t.Fatalf("Expected first slot to be acquired, got: %v", err)
+	}
+
+	// With the default concurrency of 1, a second request must wait until the first slot is released.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := acquireBrowserSlot(ctx); err == nil {
+		t.Fatal("Expected an error when no slots are available")
+	}
+
+	release()
+	releaseAgain, err := acquireBrowserSlot(context.Background())
+	if err != nil {
+		t.Fatalf("Expected slot to be available after release, got: %v", err)
+	}
+	releaseAgain()
+}
+
+func TestParseChromeFlags(t *testing.T) {
+	got := parseChromeFlags([]string{
+		"--disable-dev-shm-usage",
+		"--lang=en-US",
+		"headless=false",
+		"  --window-size=1280,800  ",
+		"--",
+	})
+	want := map[string]any{
+		"disable-dev-shm-usage": true,
+		"lang":                  "en-US",
+		"headless":              false,
+		"window-size":           "1280,800",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d flags, got %d: %v", len(want), len(got), got)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("Flag %q: expected %v, got %v", name, value, got[name])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package credentials
+
+// Per-domain credentials, stored encrypted in the database, and decrypted only when needed to fetch a page.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// IsEnabled returns true if an encryption key has been configured, which is required for storing credentials.
+func IsEnabled() bool {
+	return conf.Config.Domains.Credentials.EncryptionKey != ""
+}
+
+// Find returns credentials for the most specific authorized domain that matches hostname,
+// taking subdomains into account. Returns nil, nil if no credentials are configured.
+func Find(ctx context.Context, q *db.Queries, hostname string) (*core.Credentials, error) {
+	encrypted, err := q.FindDomainCredentials(ctx, hostname)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decrypt(encrypted)
+}
+
+// Load returns credentials stored for exactly this domain. Returns nil, nil if none are configured.
+func Load(ctx context.Context, q *db.Queries, domain string) (*core.Credentials, error) {
+	encrypted, err := q.GetDomainCredentials(ctx, domain)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decrypt(encrypted)
+}
+
+// Save encrypts and stores credentials for a domain, replacing any existing ones.
+// Saving empty credentials removes them instead.
+func Save(ctx context.Context, q *db.Queries, domain string, creds *core.Credentials) error {
+	if creds.IsEmpty() {
+		return q.DeleteDomainCredentials(ctx, domain)
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	encrypted, err := core.Encrypt(conf.Config.Domains.Credentials.EncryptionKey, plaintext)
+	if err != nil {
+		return err
+	}
+	return q.UpsertDomainCredentials(ctx, db.UpsertDomainCredentialsParams{
+		Domain:    domain,
+		Encrypted: encrypted,
+	})
+}
+
+// EncryptSecret encrypts a secret other than credentials, such as a Wi-Fi password, to be stored in the
+// database using the same key.
+func EncryptSecret(plaintext string) ([]byte, error) {
+	return core.Encrypt(conf.Config.Domains.Credentials.EncryptionKey, []byte(plaintext))
+}
+
+// DecryptSecret decrypts a secret encrypted using [EncryptSecret].
+func DecryptSecret(encrypted []byte) (string, error) {
+	plaintext, err := core.Decrypt(conf.Config.Domains.Credentials.EncryptionKey, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt stored secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func decrypt(encrypted []byte) (*core.Credentials, error) {
+	plaintext, err := core.Decrypt(conf.Config.Domains.Credentials.EncryptionKey, encrypted)
+	if err != nil {
+		// Deliberately vague, so that no part of the secret can end up in logs.
+		return nil, fmt.Errorf("unable to decrypt stored credentials: %w", err)
+	}
+	var creds core.Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("unable to parse stored credentials")
+	}
+	return &creds, nil
+}
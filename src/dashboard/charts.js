@@ -0,0 +1,182 @@
+/**
+ * @fileoverview Charts of request statistics, shared by the Link Previews & QR Codes pages.
+ */
+
+/**
+ * Renders a doughnut chart of total requests by domain.
+ * @param {string} canvasId ID of the canvas to render into.
+ * @param {string} statsUrl Endpoint returning [{Domain, TotalAccesses}].
+ */
+export async function initDomainChart(canvasId, statsUrl) {
+  const canvas = document.getElementById(canvasId);
+  if (!canvas) {
+    return;
+  }
+
+  try {
+    const response = await fetch(statsUrl);
+    if (!response.ok) {
+      console.error('Failed to fetch statistics', statsUrl);
+      return;
+    }
+
+    const stats = await response.json() || [];
+    if (stats.length === 0) {
+      console.log('No data available', statsUrl);
+      return;
+    }
+
+    new Chart(canvas.getContext('2d'), {
+      type: 'doughnut',
+      data: {
+        labels: stats.map(d => d.Domain),
+        datasets: [{
+          data: stats.map(d => d.TotalAccesses),
+          borderWidth: 1
+        }]
+      },
+      options: {
+        responsive: true,
+        plugins: {
+          legend: {
+            position: 'right',
+            labels: {
+              font: {
+                size: 14,
+                family: 'Inter'
+              }
+            }
+          }
+        }
+      }
+    });
+  } catch (error) {
+    console.error('Error loading domain chart:', error);
+  }
+}
+
+/**
+ * Renders a stacked bar chart of requests by day & canonical user agent, for a selectable range of days.
+ * @param {string} canvasId ID of the canvas to render into.
+ * @param {string} rangeId ID of the container of buttons (with data-days) that select the range.
+ * @param {string} statsUrl Endpoint returning [{Day, CanonicalUserAgent, TotalAccesses}], given ?days=.
+ */
+export function initUserAgentChart(canvasId, rangeId, statsUrl) {
+  const canvas = document.getElementById(canvasId);
+  if (!canvas) {
+    return;
+  }
+
+  const rangeContainer = document.getElementById(rangeId);
+  const rangeButtons = rangeContainer ? rangeContainer.querySelectorAll('button[data-days]') : [];
+
+  let chart = null;
+  let currentDays = 7;
+
+  function setActiveButton(days) {
+    rangeButtons.forEach(button => {
+      const isActive = Number(button.dataset.days) === days;
+      button.setAttribute('aria-pressed', isActive ? 'true' : 'false');
+    });
+  }
+
+  function buildDatasets(stats) {
+    const labelSet = new Set();
+    const agentSet = new Set();
+    const totalsByAgent = new Map();
+
+    stats.forEach(row => {
+      labelSet.add(row.Day);
+      agentSet.add(row.CanonicalUserAgent);
+      totalsByAgent.set(
+        row.CanonicalUserAgent,
+        (totalsByAgent.get(row.CanonicalUserAgent) || 0) + row.TotalAccesses
+      );
+    });
+
+    const labels = Array.from(labelSet).sort((a, b) => b.localeCompare(a));
+    const agents = Array.from(agentSet).sort((a, b) => (totalsByAgent.get(b) || 0) - (totalsByAgent.get(a) || 0));
+    const matrix = new Map();
+
+    stats.forEach(row => {
+      const key = `${row.Day}::${row.CanonicalUserAgent}`;
+      matrix.set(key, row.TotalAccesses);
+    });
+
+    const datasets = agents.map(agent => ({
+      label: agent,
+      data: labels.map(day => matrix.get(`${day}::${agent}`) || 0)
+    }));
+
+    return { labels, datasets };
+  }
+
+  async function loadChart(days) {
+    try {
+      const response = await fetch(`${statsUrl}?days=${days}`);
+      if (!response.ok) {
+        console.error('Failed to fetch user agent statistics', statsUrl);
+        return;
+      }
+
+      const stats = await response.json() || [];
+      if (stats.length === 0) {
+        console.log('No user agent data available', statsUrl);
+        return;
+      }
+
+      const { labels, datasets } = buildDatasets(stats);
+
+      if (chart) {
+        chart.data.labels = labels;
+        chart.data.datasets = datasets;
+        chart.update();
+        return;
+      }
+
+      chart = new Chart(canvas.getContext('2d'), {
+        type: 'bar',
+        data: {
+          labels,
+          datasets
+        },
+        options: {
+          indexAxis: 'y',
+          responsive: true,
+          scales: {
+            x: {
+              stacked: true,
+              beginAtZero: true
+            },
+            y: {
+              stacked: true
+            }
+          },
+          plugins: {
+            legend: {
+              position: 'right'
+            }
+          }
+        }
+      });
+    } catch (error) {
+      console.error('Error loading user agent chart:', error);
+    }
+  }
+
+  if (rangeButtons.length > 0) {
+    rangeButtons.forEach(button => {
+      button.addEventListener('click', () => {
+        const days = Number(button.dataset.days || 7);
+        if (!Number.isNaN(days)) {
+          currentDays = days;
+          setActiveButton(days);
+          loadChart(days);
+        }
+      });
+    });
+  }
+
+  setActiveButton(currentDays);
+  loadChart(currentDays);
+}
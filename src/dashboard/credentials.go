@@ -0,0 +1,164 @@
+package dashboard
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"github.com/lmittmann/tint"
+	"golang.org/x/net/http/httpguts"
+)
+
+// GET /dashboard/domains/credentials?domain=example.com - Show which credentials are configured for a domain.
+// Stored values are never sent back to the browser; only a summary of what kinds of credentials exist.
+func domainCredentialsHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+
+	domain := req.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+	renderDomainCredentials(w, req, queries, domain, "")
+}
+
+// PUT /dashboard/domains/credentials - Replace all credentials for a domain.
+func putDomainCredentialsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+	if !credentials.IsEnabled() {
+		renderDomainCredentials(w, req, queries, domain, "")
+		return
+	}
+
+	headers, err := parseHeaders(req.FormValue("headers"))
+	if err != nil {
+		renderDomainCredentials(w, req, queries, domain, err.Error())
+		return
+	}
+	cookies, err := parseCookies(req.FormValue("cookies"))
+	if err != nil {
+		renderDomainCredentials(w, req, queries, domain, err.Error())
+		return
+	}
+	creds := &core.Credentials{
+		Username: strings.TrimSpace(req.FormValue("username")),
+		Password: req.FormValue("password"),
+		Headers:  headers,
+		Cookies:  cookies,
+	}
+
+	// Never log the credentials themselves, only which domain they belong to.
+	if err := credentials.Save(ctx, queries, domain, creds); err != nil {
+		slog.Error("failed to save credentials", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("credentials updated",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"hostname", domain,
+		"status", http.StatusOK)
+	renderDomainCredentials(w, req, queries, domain, "")
+}
+
+// DELETE /dashboard/domains/credentials?domain=example.com - Remove all credentials for a domain.
+func deleteDomainCredentialsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	domain := req.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+	if err := queries.DeleteDomainCredentials(ctx, domain); err != nil {
+		slog.Error("failed to delete credentials", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderDomainCredentials(w, req, queries, domain, "")
+}
+
+func renderDomainCredentials(w http.ResponseWriter, req *http.Request, queries *db.Queries, domain, errorMsg string) {
+	ctx := req.Context()
+	summary := ""
+	if credentials.IsEnabled() {
+		creds, err := credentials.Load(ctx, queries, domain)
+		if err != nil {
+			slog.Error("failed to load credentials", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"hostname", domain,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		summary = creds.Summary()
+	}
+	DomainCredentialsTempl(domain, credentials.IsEnabled(), summary, errorMsg).Render(ctx, w)
+}
+
+// parseHeaders parses one `Name: value` header per line. Blank lines are ignored.
+// Error messages refer to line numbers only, so that secrets are never echoed back.
+func parseHeaders(text string) (map[string]string, error) {
+	headers := map[string]string{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid header on line %d; expected “Name: value”", i+1)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// parseCookies parses one `name=value` cookie per line. Blank lines are ignored.
+// Error messages refer to line numbers only, so that secrets are never echoed back.
+func parseCookies(text string) (map[string]string, error) {
+	cookies := map[string]string{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !httpguts.ValidHeaderFieldName(name) || strings.ContainsAny(value, ";\r\n") {
+			return nil, fmt.Errorf("invalid cookie on line %d; expected “name=value”", i+1)
+		}
+		cookies[name] = value
+	}
+	return cookies, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/validation"
 	"github.com/justinas/alice"
 	"github.com/lmittmann/tint"
 	"golang.org/x/crypto/bcrypt"
@@ -37,21 +38,61 @@ func Init(mux *http.ServeMux) {
 	mux.Handle("GET /dashboard/link-previews/stats", chain.ThenFunc(linkPreviewsStatsHandler))
 	mux.Handle("GET /dashboard/link-previews/user-agents", chain.ThenFunc(linkPreviewsUserAgentsHandler))
 	mux.Handle("DELETE /dashboard/link-previews/url", chain.ThenFunc(deleteLinkPreviewHandler))
+	mux.Handle("GET /dashboard/link-previews/versions", chain.ThenFunc(linkPreviewVersionsHandler))
+	mux.Handle("GET /dashboard/link-previews/versions/image", chain.ThenFunc(linkPreviewVersionImageHandler))
+	mux.Handle("GET /dashboard/link-previews/versions/diff", chain.ThenFunc(linkPreviewVersionsDiffHandler))
+	mux.Handle("PUT /dashboard/link-previews/versions/pin", chain.ThenFunc(pinLinkPreviewVersionHandler))
+	mux.Handle("DELETE /dashboard/link-previews/versions/pin", chain.ThenFunc(unpinLinkPreviewVersionHandler))
 
 	mux.Handle("GET /dashboard/qr-codes", chain.ThenFunc(listQrCodesHandler))
+	mux.Handle("GET /dashboard/qr-codes/list", chain.ThenFunc(qrCodesListHandler))
 	mux.Handle("DELETE /dashboard/qr-codes/url", chain.ThenFunc(deleteQrCodeHandler))
+	mux.Handle("GET /dashboard/qr-codes/stats", chain.ThenFunc(qrCodesStatsHandler))
+	mux.Handle("GET /dashboard/qr-codes/user-agents", chain.ThenFunc(qrCodesUserAgentsHandler))
+	mux.Handle("GET /dashboard/qr-codes/scans", chain.ThenFunc(qrCodeScansHandler))
+	mux.Handle("GET /dashboard/qr-codes/short-links", chain.ThenFunc(shortLinksHandler))
+	mux.Handle("PUT /dashboard/qr-codes/short-links", chain.ThenFunc(putShortLinkHandler))
+	mux.Handle("DELETE /dashboard/qr-codes/short-links", chain.ThenFunc(deleteShortLinkHandler))
+	mux.Handle("GET /dashboard/qr-codes/sheet", chain.ThenFunc(qrCodeSheetPageHandler))
+	mux.Handle("POST /dashboard/qr-codes/sheet", chain.ThenFunc(qrCodeSheetHandler))
+	mux.Handle("GET /dashboard/qr-codes/payloads", chain.ThenFunc(qrPayloadsPageHandler))
+	mux.Handle("POST /dashboard/qr-codes/payloads", chain.ThenFunc(postQrPayloadHandler))
+	mux.Handle("DELETE /dashboard/qr-codes/payloads", chain.ThenFunc(deleteQrPayloadHandler))
+	mux.Handle("GET /dashboard/qr-codes/payloads/fields", chain.ThenFunc(qrPayloadFieldsHandler))
+	mux.Handle("GET /dashboard/qr-codes/payloads/image", chain.ThenFunc(qrPayloadImageHandler))
+
+	mux.Handle("GET /dashboard/pdfs", chain.ThenFunc(listPdfsHandler))
+	mux.Handle("DELETE /dashboard/pdfs/url", chain.ThenFunc(deletePdfHandler))
+
+	mux.Handle("GET /dashboard/templates", chain.ThenFunc(templatesPageHandler))
+	mux.Handle("POST /dashboard/templates/render", chain.ThenFunc(renderTemplateHandler))
+	mux.Handle("PUT /dashboard/templates/template", chain.ThenFunc(putTemplateHandler))
+	mux.Handle("DELETE /dashboard/templates/template", chain.ThenFunc(deleteTemplateHandler))
 
 	mux.Handle("GET /dashboard/domains", chain.ThenFunc(domainsPageHandler))
 	mux.Handle("PUT /dashboard/domains/domain", chain.ThenFunc(putDomainHandler))
 	mux.Handle("DELETE /dashboard/domains/domain", chain.ThenFunc(deleteDomainHandler))
+	mux.Handle("PUT /dashboard/domains/image-pipeline", chain.ThenFunc(putDomainImagePipelineHandler))
+	mux.Handle("PUT /dashboard/domains/size-budget", chain.ThenFunc(putDomainSizeBudgetHandler))
+	mux.Handle("PUT /dashboard/domains/reference-check", chain.ThenFunc(putDomainReferenceCheckHandler))
+	mux.Handle("PUT /dashboard/domains/emulation", chain.ThenFunc(putDomainEmulationHandler))
+	mux.Handle("GET /dashboard/domains/credentials", chain.ThenFunc(domainCredentialsHandler))
+	mux.Handle("PUT /dashboard/domains/credentials", chain.ThenFunc(putDomainCredentialsHandler))
+	mux.Handle("DELETE /dashboard/domains/credentials", chain.ThenFunc(deleteDomainCredentialsHandler))
+	mux.Handle("GET /dashboard/domains/logo", chain.ThenFunc(domainLogoHandler))
+	mux.Handle("GET /dashboard/domains/logo/image", chain.ThenFunc(domainLogoImageHandler))
+	mux.Handle("PUT /dashboard/domains/logo", chain.ThenFunc(putDomainLogoHandler))
+	mux.Handle("DELETE /dashboard/domains/logo", chain.ThenFunc(deleteDomainLogoHandler))
 
 	mux.Handle("GET /dashboard/logs", chain.ThenFunc(logsHandler))
+	mux.Handle("GET /dashboard/debug", chain.ThenFunc(debugPageHandler))
+	mux.Handle("POST /dashboard/debug/render", chain.ThenFunc(debugRenderHandler))
 	mux.Handle("GET /dashboard/logs/data", chain.ThenFunc(logsDataHandler))
 }
 
 // GET /dashboard
 func homeHandler(w http.ResponseWriter, req *http.Request) {
-	HomeTempl(conf.AppName).Render(req.Context(), w)
+	HomeTempl(conf.AppName, core.Background.Stats()).Render(req.Context(), w)
 }
 
 // Checks whether the user is authorized, and either returns an error, or executes the passed [http.Handler].
@@ -108,3 +149,13 @@ func authHandler(next http.Handler) http.Handler {
 func CleanupExpiredSessions() {
 	sessionStore.CleanupExpired()
 }
+
+// urlsToDelete returns a URL as listed on the dashboard, along with its canonical form under the current
+// canonicalization policy, since the URL may have been recorded before the policy was last changed.
+func urlsToDelete(url string) []string {
+	urls := []string{url}
+	if u, err := validation.Canonicalize(url); err == nil && u.String() != url {
+		urls = append(urls, u.String())
+	}
+	return urls
+}
@@ -1,3 +1,7 @@
 import { initLinkPreviewsCharts } from './linkpreviews_chart.js';
+import { initQrCodesCharts } from './qrcodes_chart.js';
+import { initBulkSelection } from './selection.js';
 
 initLinkPreviewsCharts();
+initQrCodesCharts();
+initBulkSelection();
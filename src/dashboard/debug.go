@@ -0,0 +1,50 @@
+package dashboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/linkpreviews"
+	"github.com/lmittmann/tint"
+)
+
+// GET /dashboard/debug?url={url}&sel={selector} - Debug the rendering of a Link Preview
+func debugPageHandler(w http.ResponseWriter, req *http.Request) {
+	DebugPageTempl(req.URL.Query().Get("url"), req.URL.Query().Get("sel")).Render(req.Context(), w)
+}
+
+// POST /dashboard/debug/render - Render a Link Preview, and report each step in detail
+func debugRenderHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqUrl := req.FormValue("url")
+	report := linkpreviews.Debug(req.Context(), reqUrl, req.FormValue("sel"))
+	if err := report.Err(); errors.Is(err, core.ErrQueueFull) {
+		slog.Warn("debug render skipped; background queue full",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl,
+			"status", http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		slog.Info("debug render failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl)
+	}
+	DebugReportTempl(report).Render(req.Context(), w)
+}
+
+func imageDataUri(data []byte) string {
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -1,13 +1,19 @@
 package dashboard
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 
+	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/core"
 	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/linkpreviews"
+	"butterfly.chimbori.dev/validation"
 	"github.com/lmittmann/tint"
 )
 
@@ -15,7 +21,7 @@ import (
 func domainsPageHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	queries := db.New(db.Pool)
-	domains, err := queries.ListDomains(ctx)
+	domains, withCredentials, err := listDomains(ctx, queries)
 	if err != nil {
 		slog.Error("failed to list domains", tint.Err(err),
 			"method", req.Method,
@@ -25,7 +31,7 @@ func domainsPageHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	DomainsPageTempl(domains).Render(ctx, w)
+	DomainsPageTempl(domains, withCredentials).Render(ctx, w)
 }
 
 // PUT /dashboard/domains/domain - Add a new domain, or update existing one if present.
@@ -74,7 +80,7 @@ func putDomainHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Return the updated list
-	domains, err := queries.ListDomains(ctx)
+	domains, withCredentials, err := listDomains(ctx, queries)
 	if err != nil {
 		slog.Error("failed to list domains", tint.Err(err),
 			"method", req.Method,
@@ -84,7 +90,7 @@ func putDomainHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	DomainsTempl(domains).Render(ctx, w)
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
 }
 
 // DELETE /dashboard/link-previews/domain?domain=example.com - Delete a domain
@@ -105,7 +111,71 @@ func deleteDomainHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Return the updated list
-	domains, err := queries.ListDomains(ctx)
+	domains, withCredentials, err := listDomains(ctx, queries)
+	if err != nil {
+		slog.Error("failed to list domains", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
+}
+
+// PUT /dashboard/domains/image-pipeline - Select the image processing pipeline for a domain.
+func putDomainImagePipelineHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+
+	// An empty value selects the default pipeline.
+	var pipeline *string
+	if name := req.FormValue("image_pipeline"); name != "" {
+		if _, ok := conf.Config.ImageProcessing.Pipelines[name]; !ok && name != linkpreviews.NoImagePipeline {
+			err := fmt.Errorf("unknown image processing pipeline: %s", name)
+			slog.Error(err.Error(), tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"hostname", domain,
+				"status", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pipeline = &name
+	}
+
+	err := queries.UpdateDomainImagePipeline(ctx, db.UpdateDomainImagePipelineParams{
+		Domain:        domain,
+		ImagePipeline: pipeline,
+	})
+	if err != nil {
+		slog.Error("failed to update image pipeline", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return the updated list
+	domains, withCredentials, err := listDomains(ctx, queries)
 	if err != nil {
 		slog.Error("failed to list domains", tint.Err(err),
 			"method", req.Method,
@@ -115,7 +185,218 @@ func deleteDomainHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	DomainsTempl(domains).Render(ctx, w)
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
+}
+
+// PUT /dashboard/domains/size-budget - Select the size budget preset for a domain.
+func putDomainSizeBudgetHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+
+	// An empty value selects the default preset.
+	var preset *string
+	if name := req.FormValue("size_budget"); name != "" {
+		if _, ok := conf.Config.SizeBudgets.Presets[name]; !ok && name != linkpreviews.NoSizeBudget {
+			err := fmt.Errorf("unknown size budget preset: %s", name)
+			slog.Error(err.Error(), tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"hostname", domain,
+				"status", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		preset = &name
+	}
+
+	err := queries.UpdateDomainSizeBudget(ctx, db.UpdateDomainSizeBudgetParams{
+		Domain:     domain,
+		SizeBudget: preset,
+	})
+	if err != nil {
+		slog.Error("failed to update size budget", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return the updated list
+	domains, withCredentials, err := listDomains(ctx, queries)
+	if err != nil {
+		slog.Error("failed to list domains", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
+}
+
+// PUT /dashboard/domains/reference-check - Enable or disable reference checks for a domain.
+func putDomainReferenceCheckHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+
+	err := queries.UpdateDomainReferenceCheck(ctx, db.UpdateDomainReferenceCheckParams{
+		Domain:         domain,
+		ReferenceCheck: core.Ptr(req.FormValue("reference_check") == "on"),
+	})
+	if err != nil {
+		slog.Error("failed to update reference check", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return the updated list
+	domains, withCredentials, err := listDomains(ctx, queries)
+	if err != nil {
+		slog.Error("failed to list domains", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
+}
+
+// PUT /dashboard/domains/emulation - Set the default locale, timezone & color scheme for a domain.
+func putDomainEmulationHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Empty values keep Chrome’s defaults.
+	params := db.UpdateDomainEmulationParams{Domain: domain}
+	for _, field := range []struct {
+		value    string
+		validate func(string) (string, error)
+		dest     **string
+	}{
+		{req.FormValue("locale"), validation.ValidateLocale, &params.Locale},
+		{req.FormValue("timezone"), validation.ValidateTimezone, &params.Timezone},
+		{req.FormValue("color_scheme"), validation.ValidateColorScheme, &params.ColorScheme},
+	} {
+		value := strings.TrimSpace(field.value)
+		if value == "" {
+			continue
+		}
+		valid, err := field.validate(value)
+		if err != nil {
+			slog.Error(err.Error(), tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"hostname", domain,
+				"status", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*field.dest = &valid
+	}
+
+	if err := queries.UpdateDomainEmulation(ctx, params); err != nil {
+		slog.Error("failed to update emulation", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return the updated list
+	domains, withCredentials, err := listDomains(ctx, queries)
+	if err != nil {
+		slog.Error("failed to list domains", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	DomainsTempl(domains, withCredentials).Render(ctx, w)
+}
+
+// listDomains returns all domains, along with the set of domains that have credentials configured.
+func listDomains(ctx context.Context, queries *db.Queries) ([]db.Domain, map[string]bool, error) {
+	domains, err := queries.ListDomains(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	credentialed, err := queries.ListDomainsWithCredentials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	withCredentials := make(map[string]bool, len(credentialed))
+	for _, domain := range credentialed {
+		withCredentials[domain] = true
+	}
+	return domains, withCredentials, nil
+}
+
+// imagePipelineNames returns the names of all configured image processing pipelines, sorted.
+func imagePipelineNames() []string {
+	return slices.Sorted(maps.Keys(conf.Config.ImageProcessing.Pipelines))
+}
+
+// sizeBudgetNames returns the names of all configured size budget presets, sorted.
+func sizeBudgetNames() []string {
+	return slices.Sorted(maps.Keys(conf.Config.SizeBudgets.Presets))
 }
 
 func isAuthorized(authorizeAction string) *bool {
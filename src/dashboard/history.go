@@ -0,0 +1,220 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/linkpreviews"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+)
+
+// versionEntry is a version of a Link Preview in its timeline, along with the version it replaced (i.e.
+// the previous version of the same variant), if any.
+type versionEntry struct {
+	db.LinkPreviewVersion
+	Variant    string
+	PreviousID int64
+}
+
+// GET /dashboard/link-previews/versions?url={url} - Timeline of recent versions of a link preview
+func linkPreviewVersionsHandler(w http.ResponseWriter, req *http.Request) {
+	url := req.URL.Query().Get("url")
+	if url == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+		return
+	}
+	renderVersions(w, req, url)
+}
+
+// GET /dashboard/link-previews/versions/image?id={id} - Serves the image of a version as-is
+func linkPreviewVersionImageHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseVersionId(w, req, "id")
+	if !ok {
+		return
+	}
+	_, data, ok := findVersion(w, req, id)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", "public, max-age=31536000") // 1 year cache; versions never change.
+	w.Write(data)
+}
+
+// GET /dashboard/link-previews/versions/diff?a={id}&b={id} - Side-by-side & pixel-diff views of two versions
+func linkPreviewVersionsDiffHandler(w http.ResponseWriter, req *http.Request) {
+	idA, ok := parseVersionId(w, req, "a")
+	if !ok {
+		return
+	}
+	idB, ok := parseVersionId(w, req, "b")
+	if !ok {
+		return
+	}
+	versionA, dataA, ok := findVersion(w, req, idA)
+	if !ok {
+		return
+	}
+	versionB, dataB, ok := findVersion(w, req, idB)
+	if !ok {
+		return
+	}
+
+	// Diffing full-size images is as expensive as compressing them, so share the background workers.
+	type versionDiff struct {
+		image   []byte
+		changed float64
+	}
+	result, err := core.Do(req.Context(), core.Background, "version diff: "+versionA.Url, func(ctx context.Context) (versionDiff, error) {
+		diff, changed, err := core.DiffImages(ctx, dataA, dataB)
+		return versionDiff{diff, changed}, err
+	})
+	if errors.Is(err, core.ErrQueueFull) {
+		slog.Warn("version diff skipped; background queue full",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", versionA.Url,
+			"status", http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		slog.Error("error comparing versions", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", versionA.Url,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	diffUri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(result.image)
+	LinkPreviewVersionsDiffTempl(versionA, versionB, diffUri, result.changed).Render(req.Context(), w)
+}
+
+// PUT /dashboard/link-previews/versions/pin?id={id} - Roll back to a version, and keep serving it
+func pinLinkPreviewVersionHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseVersionId(w, req, "id")
+	if !ok {
+		return
+	}
+	version, err := linkpreviews.PinVersion(req.Context(), db.New(db.Pool), id)
+	if err != nil {
+		status := versionErrorStatus(err)
+		slog.Error("failed to pin version", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", version.Url,
+			"status", status)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if ThumbnailCache != nil {
+		_ = ThumbnailCache.Delete(version.CacheKey) // Thumbnails are only cached for the default variant.
+	}
+	slog.Info("link preview rolled back",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"url", version.Url,
+		"id", id)
+	renderVersions(w, req, version.Url)
+}
+
+// DELETE /dashboard/link-previews/versions/pin?id={id} - Unpin all versions of the same variant
+func unpinLinkPreviewVersionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	id, ok := parseVersionId(w, req, "id")
+	if !ok {
+		return
+	}
+	version, err := queries.GetLinkPreviewVersion(ctx, id)
+	if err == nil {
+		err = linkpreviews.UnpinVersions(ctx, queries, version.CacheKey)
+	}
+	if err != nil {
+		slog.Error("failed to unpin version", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", version.Url,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderVersions(w, req, version.Url)
+}
+
+func renderVersions(w http.ResponseWriter, req *http.Request, url string) {
+	if linkpreviews.Versions == nil {
+		LinkPreviewVersionsTempl(url, nil, false).Render(req.Context(), w)
+		return
+	}
+	versions, err := db.New(db.Pool).ListLinkPreviewVersions(req.Context(), url)
+	if err != nil {
+		slog.Error("failed to list versions", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Versions are listed newest first, so the previous version of each variant is the next one listed.
+	entries := make([]versionEntry, len(versions))
+	for i, v := range versions {
+		entries[i] = versionEntry{LinkPreviewVersion: v, Variant: strings.TrimSpace(strings.TrimPrefix(v.CacheKey, v.Url))}
+		for _, older := range versions[i+1:] {
+			if older.CacheKey == v.CacheKey {
+				entries[i].PreviousID = older.ID
+				break
+			}
+		}
+	}
+	LinkPreviewVersionsTempl(url, entries, true).Render(req.Context(), w)
+}
+
+func parseVersionId(w http.ResponseWriter, req *http.Request, param string) (int64, bool) {
+	id, err := strconv.ParseInt(req.URL.Query().Get(param), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid %s parameter: %w", param, err)
+		slog.Error("invalid version", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func findVersion(w http.ResponseWriter, req *http.Request, id int64) (db.LinkPreviewVersion, []byte, bool) {
+	version, data, err := linkpreviews.FindVersion(req.Context(), db.New(db.Pool), id)
+	if err != nil {
+		status := versionErrorStatus(err)
+		slog.Error("failed to load version", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", version.Url,
+			"status", status)
+		http.Error(w, err.Error(), status)
+		return version, nil, false
+	}
+	return version, data, true
+}
+
+func versionErrorStatus(err error) int {
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, linkpreviews.ErrHistoryDisabled) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -2,13 +2,15 @@ package dashboard
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
+	_ "image/jpeg" // Link previews are cached as JPEGs when needed to fit a size budget.
 	_ "image/png"
 	"log/slog"
 	"net/http"
-	"runtime"
 	"strconv"
 
 	"butterfly.chimbori.dev/conf"
@@ -21,15 +23,8 @@ import (
 	"github.com/lmittmann/tint"
 )
 
-// compressionSem limits the number of concurrent image compression tasks.
-var compressionSem chan struct{}
-
 var ThumbnailCache *core.DiskCache
 
-func init() {
-	compressionSem = make(chan struct{}, runtime.NumCPU()*4)
-}
-
 // GET /dashboard/link-previews - Render the link previews page
 func linkPreviewsPageHandler(w http.ResponseWriter, req *http.Request) {
 	slog.Debug("linkPreviewsPageHandler", "url", req.Method+" "+req.URL.String())
@@ -100,26 +95,37 @@ func deleteLinkPreviewHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Delete the cached file from disk
-	if err := linkpreviews.DeleteCached(url); err != nil {
-		slog.Warn("failed to delete cached file", tint.Err(err),
-			"method", req.Method,
-			"path", req.URL.Path,
-			"url", url,
-			"status", http.StatusInternalServerError)
-		// Continue anyway to remove from the database
-	}
+	for _, url := range urlsToDelete(url) {
+		// Delete the cached file from disk
+		if err := linkpreviews.DeleteCached(ctx, queries, url); err != nil {
+			slog.Warn("failed to delete cached file", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"status", http.StatusInternalServerError)
+			// Continue anyway to remove from the database
+		}
 
-	// Delete the row from the database
-	err := queries.DeleteLinkPreview(ctx, url)
-	if err != nil {
-		slog.Error("failed to delete cached link preview", tint.Err(err),
-			"method", req.Method,
-			"path", req.URL.Path,
-			"url", url,
-			"status", http.StatusInternalServerError)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		// Delete all versions, including any rolled back to, so that every variant is rendered afresh.
+		if err := linkpreviews.DeleteVersions(ctx, queries, url); err != nil {
+			slog.Warn("failed to delete versions", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"status", http.StatusInternalServerError)
+		}
+
+		// Delete the row from the database
+		err := queries.DeleteLinkPreview(ctx, url)
+		if err != nil {
+			slog.Error("failed to delete cached link preview", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Return the updated list (go back to page 1)
@@ -182,8 +188,21 @@ func serveLinkPreviewHandler(w http.ResponseWriter, req *http.Request) {
 
 	url := u.String()
 
+	// Thumbnails are of the default variant, with the defaults of the domain, and are cached under its key.
+	cacheKey, err := linkpreviews.DefaultCacheKey(req.Context(), db.New(db.Pool), url)
+	if err != nil {
+		slog.Error("error loading domain defaults", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", u.Hostname(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if ThumbnailCache != nil {
-		if webp, err := ThumbnailCache.Find(url); err == nil && webp != nil {
+		if webp, err := ThumbnailCache.Find(cacheKey); err == nil && webp != nil {
 			slog.Debug("serving from thumbnail cache", "url", url)
 			w.Header().Set("Content-Type", "image/webp")
 			w.Header().Set("Cache-Control", "public, max-age=31536000") // 1 year cache
@@ -204,7 +223,7 @@ func serveLinkPreviewHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	png, err := linkpreviews.Cache.Find(url)
+	png, err := linkpreviews.Cache.Find(cacheKey)
 	if err != nil {
 		err = fmt.Errorf("url: %s, %w", url, err)
 		slog.Error("error during cache lookup", tint.Err(err),
@@ -228,35 +247,35 @@ func serveLinkPreviewHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Decode the PNG image from the cache & compress it to WebP on the fly.
-	compressionSem <- struct{}{}
-	defer func() { <-compressionSem }()
+	// Decode the PNG image from the cache & compress it to WebP on the fly, sharing the background workers,
+	// so that a page full of thumbnails cannot starve other work.
+	webpData, err := core.Do(req.Context(), core.Background, "thumbnail: "+url, func(ctx context.Context) ([]byte, error) {
+		img, _, err := image.Decode(bytes.NewReader(png))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding link preview image: %w", err)
+		}
 
-	img, _, err := image.Decode(bytes.NewReader(png))
-	if err != nil {
-		slog.Error("Error decoding link preview image", tint.Err(err),
+		// Use NearestNeighbor for simplicity & speed, since we’re only scaling down, never up.
+		resized := imaging.Resize(img, 600, 0, imaging.NearestNeighbor)
+
+		var webpBuf bytes.Buffer
+		if err := nativewebp.Encode(&webpBuf, resized, &nativewebp.Options{}); err != nil {
+			return nil, fmt.Errorf("failed to encode WebP: %w", err)
+		}
+		return webpBuf.Bytes(), nil
+	})
+	if errors.Is(err, core.ErrQueueFull) {
+		slog.Warn("thumbnail skipped; background queue full",
 			"method", req.Method,
 			"path", req.URL.Path,
 			"url", url,
 			"hostname", u.Hostname(),
-			"status", http.StatusInternalServerError)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+			"status", http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
-
-	// Use NearestNeighbor for simplicity & speed, since we’re only scaling down, never up.
-	resized := imaging.Resize(img, 600, 0, imaging.NearestNeighbor)
-
-	slog.Debug("image scaled successfully",
-		"method", req.Method,
-		"path", req.URL.Path,
-		"url", url,
-		"hostname", u.Hostname())
-
-	// Encode as WebP.
-	var webpBuf bytes.Buffer
-	if err := nativewebp.Encode(&webpBuf, resized, &nativewebp.Options{}); err != nil {
-		slog.Error("failed to encode WebP", tint.Err(err),
+	if err != nil {
+		slog.Error("error creating thumbnail", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
 			"url", url,
@@ -265,10 +284,10 @@ func serveLinkPreviewHandler(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	webpData := webpBuf.Bytes()
-
 	if ThumbnailCache != nil {
-		go ThumbnailCache.Write(url, webpData)
+		core.Background.Submit("thumbnail cache: "+url, func() error {
+			return ThumbnailCache.Write(cacheKey, webpData)
+		})
 	}
 
 	w.Header().Set("Content-Type", "image/webp")
@@ -0,0 +1,131 @@
+package dashboard
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/qrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+)
+
+// maxLogoUploadBytes limits the size of logos uploaded for QR Codes.
+const maxLogoUploadBytes = 2 * 1024 * 1024
+
+// GET /dashboard/domains/logo?domain=example.com - Show the logo overlaid on QR Codes for a domain.
+func domainLogoHandler(w http.ResponseWriter, req *http.Request) {
+	domain := req.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+	renderDomainLogo(w, req, db.New(db.Pool), domain, "")
+}
+
+// GET /dashboard/domains/logo/image?domain=example.com - Serve the logo uploaded for a domain.
+func domainLogoImageHandler(w http.ResponseWriter, req *http.Request) {
+	domain := req.URL.Query().Get("domain")
+	image, err := db.New(db.Pool).GetDomainLogo(req.Context(), domain)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(image)
+}
+
+// PUT /dashboard/domains/logo - Upload a logo for a domain, replacing any existing one.
+func putDomainLogoHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxLogoUploadBytes)
+	if err := req.ParseMultipartForm(maxLogoUploadBytes); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain := strings.TrimSpace(req.FormValue("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := req.FormFile("logo")
+	if err != nil {
+		renderDomainLogo(w, req, queries, domain, "Select an image to upload")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err == nil {
+		data, err = qrcode.PrepareLogo(data)
+	}
+	if err != nil {
+		renderDomainLogo(w, req, queries, domain, err.Error())
+		return
+	}
+
+	if err := queries.UpsertDomainLogo(ctx, db.UpsertDomainLogoParams{Domain: domain, Image: data}); err != nil {
+		slog.Error("failed to save logo", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("logo updated",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"hostname", domain,
+		"status", http.StatusOK)
+	renderDomainLogo(w, req, queries, domain, "")
+}
+
+// DELETE /dashboard/domains/logo?domain=example.com - Remove the logo for a domain.
+func deleteDomainLogoHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+
+	domain := req.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "missing domain parameter", http.StatusBadRequest)
+		return
+	}
+	if err := queries.DeleteDomainLogo(req.Context(), domain); err != nil {
+		slog.Error("failed to delete logo", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderDomainLogo(w, req, queries, domain, "")
+}
+
+func renderDomainLogo(w http.ResponseWriter, req *http.Request, queries *db.Queries, domain, errorMsg string) {
+	_, err := queries.GetDomainLogo(req.Context(), domain)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("failed to load logo", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"hostname", domain,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	DomainLogoTempl(domain, err == nil, errorMsg).Render(req.Context(), w)
+}
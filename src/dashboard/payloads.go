@@ -0,0 +1,180 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/qrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+)
+
+// GET /dashboard/qr-codes/payloads - Create QR Codes for Wi-Fi credentials, contacts, events, etc.
+func qrPayloadsPageHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	payloads, err := db.New(db.Pool).ListQrPayloads(ctx)
+	if err != nil {
+		slog.Error("failed to list QR Code payloads", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	QrPayloadsPageTempl(payloads).Render(ctx, w)
+}
+
+// GET /dashboard/qr-codes/payloads/fields?kind={kind} - Get the form fields for a kind of payload
+func qrPayloadFieldsHandler(w http.ResponseWriter, req *http.Request) {
+	QrPayloadFieldsTempl(req.URL.Query().Get("kind")).Render(req.Context(), w)
+}
+
+// POST /dashboard/qr-codes/payloads - Create & save a QR Code for a structured payload
+func postQrPayloadHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	kind := req.FormValue("kind")
+	name := strings.TrimSpace(req.FormValue("name"))
+	payload, err := qrcode.EncodePayload(kind, req.Form)
+	if err == nil && name == "" {
+		err = errors.New("missing name")
+	}
+	var style string
+	if err == nil {
+		style, err = qrcode.ParsePayloadStyle(req.Form)
+	}
+	if err == nil {
+		// Generate it once, to check that the payload fits in a QR Code at the requested error correction level.
+		_, _, err = qrcode.GeneratePayload(payload, style, "png")
+	}
+	var encrypted []byte
+	if err == nil {
+		payload, encrypted, err = qrcode.EncryptPayload(kind, payload)
+	}
+	if err != nil {
+		renderQrPayloadsList(w, req, queries, err.Error())
+		return
+	}
+
+	if _, err := queries.CreateQrPayload(ctx, db.CreateQrPayloadParams{Name: name, Kind: kind, Payload: payload, Style: style, Encrypted: encrypted}); err != nil {
+		slog.Error("failed to save QR Code payload", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("QR Code payload created",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"kind", kind,
+		"status", http.StatusOK)
+	renderQrPayloadsList(w, req, queries, "")
+}
+
+// GET /dashboard/qr-codes/payloads/image?id={id}&format={png|svg|pdf} - Download a saved QR Code
+func qrPayloadImageHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	id, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "png"
+	}
+
+	p, err := db.New(db.Pool).GetQrPayload(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		slog.Error("failed to find QR Code payload", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := qrcode.DecryptPayload(p)
+	if err != nil {
+		slog.Error("failed to decrypt QR Code payload", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, contentType, err := qrcode.GeneratePayload(payload, p.Style, format)
+	if err != nil {
+		slog.Error("failed to generate QR Code payload", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if req.URL.Query().Has("download") {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="qr-code-%s-%d.%s"`, p.Kind, p.ID, format))
+	}
+	w.Write(data)
+}
+
+// DELETE /dashboard/qr-codes/payloads?id={id} - Delete a saved QR Code
+func deleteQrPayloadHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+	id, err := strconv.ParseInt(req.FormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	if err := queries.DeleteQrPayload(req.Context(), id); err != nil {
+		slog.Error("failed to delete QR Code payload", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderQrPayloadsList(w, req, queries, "")
+}
+
+func renderQrPayloadsList(w http.ResponseWriter, req *http.Request, queries *db.Queries, errorMsg string) {
+	payloads, err := queries.ListQrPayloads(req.Context())
+	if err != nil {
+		slog.Error("failed to list QR Code payloads", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	QrPayloadsListTempl(payloads, errorMsg).Render(req.Context(), w)
+}
+
+// payloadKindLabel returns the label of a kind of payload, for display.
+func payloadKindLabel(kind string) string {
+	for _, k := range qrcode.PayloadKinds {
+		if k.Kind == kind {
+			return k.Label
+		}
+	}
+	return kind
+}
@@ -0,0 +1,73 @@
+package dashboard
+
+import (
+	"log/slog"
+	"net/http"
+
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/pdf"
+	"github.com/lmittmann/tint"
+)
+
+// GET /dashboard/pdfs - List all PDFs
+func listPdfsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+	pdfs, err := queries.ListPdfs(ctx)
+	if err != nil {
+		slog.Error("failed to list PDFs", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	PdfsPageTempl(pdfs).Render(ctx, w)
+}
+
+// DELETE /dashboard/pdfs/url?url={url}&options={options} - Delete a cached PDF
+func deletePdfHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	url := req.URL.Query().Get("url")
+	if url == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+		return
+	}
+	options := req.URL.Query().Get("options")
+
+	for _, url := range urlsToDelete(url) {
+		// Delete the cached file from disk
+		if err := pdf.DeleteCached(url, options); err != nil {
+			slog.Warn("failed to delete cached PDF file", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url)
+			// Continue anyway to remove from the database
+		}
+
+		// Delete the row from the database
+		if err := queries.DeletePdf(ctx, db.DeletePdfParams{Url: url, Options: options}); err != nil {
+			slog.Error("failed to delete PDF", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Return the updated list
+	pdfs, err := queries.ListPdfs(ctx)
+	if err != nil {
+		slog.Error("failed to list PDFs", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	PdfsListTempl(pdfs).Render(ctx, w)
+}
@@ -1,70 +1,337 @@
 package dashboard
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/db"
 	"butterfly.chimbori.dev/qrcode"
+	"butterfly.chimbori.dev/validation"
 	"github.com/lmittmann/tint"
 )
 
-// GET /dashboard/qr-codes - List all QR Codes
+// qrCodesFilter selects the page of QR Codes to list, matching a search for a substring of the URL and/or
+// a domain, sorted by “accessed” (the default), “created”, or “count”.
+type qrCodesFilter struct {
+	Search string
+	Domain string
+	Sort   string
+	Page   int
+}
+
+func parseQrCodesFilter(req *http.Request) qrCodesFilter {
+	query := req.URL.Query()
+	filter := qrCodesFilter{
+		Search: strings.TrimSpace(query.Get("search")),
+		Domain: query.Get("domain"),
+		Sort:   query.Get("sort"),
+		Page:   1,
+	}
+	if filter.Sort != "created" && filter.Sort != "count" {
+		filter.Sort = "accessed"
+	}
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		filter.Page = p
+	}
+	return filter
+}
+
+// query returns the filter as query parameters, for the given page.
+func (f qrCodesFilter) query(page int) string {
+	query := url.Values{}
+	if f.Search != "" {
+		query.Set("search", f.Search)
+	}
+	if f.Domain != "" {
+		query.Set("domain", f.Domain)
+	}
+	if f.Sort != "accessed" {
+		query.Set("sort", f.Sort)
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	return query.Encode()
+}
+
+// GET /dashboard/qr-codes?search={search}&domain={domain}&sort={sort}&page={page} - Render the QR Codes page
 func listQrCodesHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	queries := db.New(db.Pool)
-	qrCodes, err := queries.ListQrCodes(ctx)
+	domains, err := queries.GetQrCodesByDomain(ctx)
 	if err != nil {
-		slog.Error("failed to list QR Codes", tint.Err(err),
+		slog.Error("failed to list QR Code domains", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
 			"status", http.StatusInternalServerError)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	QrCodesPageTempl(qrCodes).Render(ctx, w)
+	QrCodesPageTempl(parseQrCodesFilter(req), domains).Render(ctx, w)
+}
+
+// GET /dashboard/qr-codes/list?search={search}&domain={domain}&sort={sort}&page={page} - Get paginated QR Codes list
+func qrCodesListHandler(w http.ResponseWriter, req *http.Request) {
+	renderQrCodesList(w, req, parseQrCodesFilter(req))
 }
 
-// DELETE /dashboard/qr-codes/url?url={url} - Delete a cached QR Code
+// DELETE /dashboard/qr-codes/url?url={url}&url={url}… - Delete one or more cached QR Codes
+// Returns the list of QR Codes, using the same filter parameters as /dashboard/qr-codes/list.
 func deleteQrCodeHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	queries := db.New(db.Pool)
 
-	url := req.URL.Query().Get("url")
-	if url == "" {
+	selected := req.URL.Query()["url"]
+	if len(selected) == 0 {
 		http.Error(w, "missing url parameter", http.StatusBadRequest)
 		return
 	}
 
-	// Delete the cached file from disk
-	if err := qrcode.DeleteCached(url); err != nil {
-		slog.Warn("failed to delete cached QR Code file", tint.Err(err),
+	// QR Codes are recorded under their canonical URLs, along with the cache keys of every variant, including
+	// those encoding other forms of the same URL.
+	var urls []string
+	for _, url := range selected {
+		urls = append(urls, urlsToDelete(url)...)
+	}
+	for _, url := range urls {
+		// Delete the cached file from disk
+		if err := qrcode.DeleteCached(ctx, queries, url); err != nil {
+			slog.Warn("failed to delete cached QR Code file", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url)
+			// Continue anyway to remove from the database
+		}
+
+		// Delete the row from the database
+		if err := queries.DeleteQrCode(ctx, url); err != nil {
+			slog.Error("failed to delete QR Code", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Return the updated list, staying on the same page if it still exists.
+	renderQrCodesList(w, req, parseQrCodesFilter(req))
+}
+
+// renderQrCodesList returns the page of QR Codes matching a filter, or the last page, if there are fewer.
+func renderQrCodesList(w http.ResponseWriter, req *http.Request, filter qrCodesFilter) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	totalCount, err := queries.CountQrCodes(ctx, db.CountQrCodesParams{Search: filter.Search, Domain: filter.Domain})
+	if err != nil {
+		slog.Error("failed to count QR Codes", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter.Page = max(1, min(filter.Page, int(calculateTotalPages(totalCount))))
+
+	qrCodes, err := queries.ListQrCodesPaginated(ctx, db.ListQrCodesPaginatedParams{
+		Search:    filter.Search,
+		Domain:    filter.Domain,
+		Sort:      filter.Sort,
+		RowLimit:  int32(conf.Config.Dashboard.Pagination.Limit),
+		RowOffset: int32((filter.Page - 1) * conf.Config.Dashboard.Pagination.Limit),
+	})
+	if err != nil {
+		slog.Error("failed to list QR Codes", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	QrCodesListTempl(qrCodes, filter, totalCount).Render(ctx, w)
+}
+
+// GET /dashboard/qr-codes/stats - Get QR Code statistics by domain as JSON
+func qrCodesStatsHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+	stats, err := queries.GetQrCodesByDomain(req.Context())
+	if err != nil {
+		slog.Error("failed to get QR Code stats", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
-			"url", url)
-		// Continue anyway to remove from the database
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// Delete the row from the database
-	if err := queries.DeleteQrCode(ctx, url); err != nil {
-		slog.Error("failed to delete QR Code", tint.Err(err),
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GET /dashboard/qr-codes/user-agents?days=7 - Get canonical user agent distribution by day
+func qrCodesUserAgentsHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+	days := parseDaysRange(req.URL.Query().Get("days"))
+
+	stats, err := queries.GetQrCodeUserAgentsByDay(req.Context(), int32(days))
+	if err != nil {
+		slog.Error("failed to get QR Code user agent stats", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
-			"url", url,
+			"url", req.URL.String(),
 			"status", http.StatusInternalServerError)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GET /dashboard/qr-codes/scans?days=28 - Get scans of tracked QR Codes by day & URL as JSON
+func qrCodeScansHandler(w http.ResponseWriter, req *http.Request) {
+	queries := db.New(db.Pool)
+	days := parseDaysRange(req.URL.Query().Get("days"))
 
-	// Return the updated list
-	qrCodes, err := queries.ListQrCodes(ctx)
+	stats, err := queries.GetShortLinkScansByDay(req.Context(), int32(days))
 	if err != nil {
-		slog.Error("failed to list QR Codes", tint.Err(err),
+		slog.Error("failed to get short link scan stats", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GET /dashboard/qr-codes/short-links?page={page} - Get paginated short links list
+func shortLinksHandler(w http.ResponseWriter, req *http.Request) {
+	renderShortLinks(w, req)
+}
+
+// PUT /dashboard/qr-codes/short-links - Change the URL to which a short link redirects
+func putShortLinkHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slug := req.FormValue("slug")
+	if slug == "" {
+		http.Error(w, "missing slug parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Only redirect to authorized domains, so that short links cannot be abused as open redirects.
+	_, targetUrl, hostname, err := validation.ValidateUrl(ctx, queries, strings.TrimSpace(req.FormValue("target_url")))
+	if err != nil {
+		slog.Error("URL validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.FormValue("target_url"),
+			"hostname", hostname,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := queries.RetargetShortLink(ctx, db.RetargetShortLinkParams{Slug: slug, TargetUrl: targetUrl})
+	if err != nil {
+		slog.Error("failed to retarget short link", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", targetUrl,
+			"hostname", hostname,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, "short link not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("short link retargeted",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"url", targetUrl,
+		"hostname", hostname,
+		"status", http.StatusOK)
+
+	renderShortLinks(w, req)
+}
+
+// DELETE /dashboard/qr-codes/short-links?slug={slug} - Delete a short link, along with its scans
+func deleteShortLinkHandler(w http.ResponseWriter, req *http.Request) {
+	slug := req.FormValue("slug")
+	if slug == "" {
+		http.Error(w, "missing slug parameter", http.StatusBadRequest)
+		return
+	}
+	queries := db.New(db.Pool)
+	if err := queries.DeleteShortLink(req.Context(), slug); err != nil {
+		slog.Error("failed to delete short link", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderShortLinks(w, req)
+}
+
+// renderShortLinks returns the requested page of short links, or the last page, if there are fewer.
+func renderShortLinks(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	totalCount, err := queries.CountShortLinks(ctx)
+	if err != nil {
+		slog.Error("failed to count short links", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := 1
+	if p, err := strconv.Atoi(req.FormValue("page")); err == nil && p > 0 {
+		page = p
+	}
+	page = max(1, min(page, int(calculateTotalPages(totalCount))))
+
+	shortLinks, err := queries.ListShortLinksPaginated(ctx, db.ListShortLinksPaginatedParams{
+		RowLimit:  int32(conf.Config.Dashboard.Pagination.Limit),
+		RowOffset: int32((page - 1) * conf.Config.Dashboard.Pagination.Limit),
+	})
+	if err != nil {
+		slog.Error("failed to list short links", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
 			"status", http.StatusInternalServerError)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	QrCodesListTempl(qrCodes).Render(ctx, w)
+	ShortLinksTempl(shortLinks, page, totalCount).Render(ctx, w)
 }
@@ -0,0 +1,135 @@
+/**
+ * @fileoverview Renders charts for QR Code statistics, and scans of tracked QR Codes, on the dashboard.
+ */
+import { initDomainChart, initUserAgentChart } from './charts.js';
+
+export function initQrCodesCharts() {
+  function init() {
+    initDomainChart('qrcodes-domain-chart', '/dashboard/qr-codes/stats');
+    initUserAgentChart('qrcodes-useragents-chart', 'qrcodes-useragents-range', '/dashboard/qr-codes/user-agents');
+    initScansChart();
+  }
+  if (document.readyState === 'loading') {
+    document.addEventListener('DOMContentLoaded', init);
+  } else {
+    init();
+  }
+}
+
+function initScansChart() {
+  const canvas = document.getElementById('qrcodes-scans-chart');
+  if (!canvas) {
+    return;
+  }
+
+  const rangeContainer = document.getElementById('qrcodes-scans-range');
+  const rangeButtons = rangeContainer ? rangeContainer.querySelectorAll('button[data-days]') : [];
+
+  let chart = null;
+  let currentDays = 28;
+
+  function setActiveButton(days) {
+    rangeButtons.forEach(button => {
+      const isActive = Number(button.dataset.days) === days;
+      button.setAttribute('aria-pressed', isActive ? 'true' : 'false');
+    });
+  }
+
+  function buildDatasets(stats) {
+    const labelSet = new Set();
+    const urlSet = new Set();
+    const totalsByUrl = new Map();
+
+    stats.forEach(row => {
+      labelSet.add(row.Day);
+      urlSet.add(row.Url);
+      totalsByUrl.set(row.Url, (totalsByUrl.get(row.Url) || 0) + row.TotalScans);
+    });
+
+    // Oldest first, so that scans read left-to-right over time.
+    const labels = Array.from(labelSet).sort((a, b) => a.localeCompare(b));
+    const urls = Array.from(urlSet).sort((a, b) => (totalsByUrl.get(b) || 0) - (totalsByUrl.get(a) || 0));
+    const matrix = new Map();
+
+    stats.forEach(row => {
+      matrix.set(`${row.Day}::${row.Url}`, row.TotalScans);
+    });
+
+    const datasets = urls.map(url => ({
+      label: url,
+      data: labels.map(day => matrix.get(`${day}::${url}`) || 0)
+    }));
+
+    return { labels, datasets };
+  }
+
+  async function loadChart(days) {
+    try {
+      const response = await fetch(`/dashboard/qr-codes/scans?days=${days}`);
+      if (!response.ok) {
+        console.error('Failed to fetch QR Code scan statistics');
+        return;
+      }
+
+      const stats = await response.json() || [];
+      const { labels, datasets } = buildDatasets(stats);
+
+      if (chart) {
+        chart.data.labels = labels;
+        chart.data.datasets = datasets;
+        chart.update();
+        return;
+      }
+      if (stats.length === 0) {
+        console.log('No QR Code scan data available');
+        return;
+      }
+
+      chart = new Chart(canvas.getContext('2d'), {
+        type: 'bar',
+        data: {
+          labels,
+          datasets
+        },
+        options: {
+          responsive: true,
+          scales: {
+            x: {
+              stacked: true
+            },
+            y: {
+              stacked: true,
+              beginAtZero: true,
+              ticks: {
+                precision: 0
+              }
+            }
+          },
+          plugins: {
+            legend: {
+              position: 'right'
+            }
+          }
+        }
+      });
+    } catch (error) {
+      console.error('Error loading QR Code scans chart:', error);
+    }
+  }
+
+  if (rangeButtons.length > 0) {
+    rangeButtons.forEach(button => {
+      button.addEventListener('click', () => {
+        const days = Number(button.dataset.days || 28);
+        if (!Number.isNaN(days)) {
+          currentDays = days;
+          setActiveButton(days);
+          loadChart(days);
+        }
+      });
+    });
+  }
+
+  setActiveButton(currentDays);
+  loadChart(currentDays);
+}
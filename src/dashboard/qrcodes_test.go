@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseQrCodesFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  qrCodesFilter
+	}{
+		{"defaults", "", qrCodesFilter{Sort: "accessed", Page: 1}},
+		{"search trimmed", "search=%20example%20", qrCodesFilter{Search: "example", Sort: "accessed", Page: 1}},
+		{"domain", "domain=example.com", qrCodesFilter{Domain: "example.com", Sort: "accessed", Page: 1}},
+		{"sort by created", "sort=created", qrCodesFilter{Sort: "created", Page: 1}},
+		{"sort by count", "sort=count", qrCodesFilter{Sort: "count", Page: 1}},
+		{"sort by accessed", "sort=accessed", qrCodesFilter{Sort: "accessed", Page: 1}},
+		{"invalid sort", "sort=url%3BDROP%20TABLE%20qr_codes", qrCodesFilter{Sort: "accessed", Page: 1}},
+		{"page", "page=3", qrCodesFilter{Sort: "accessed", Page: 3}},
+		{"page zero", "page=0", qrCodesFilter{Sort: "accessed", Page: 1}},
+		{"page negative", "page=-2", qrCodesFilter{Sort: "accessed", Page: 1}},
+		{"page not a number", "page=last", qrCodesFilter{Sort: "accessed", Page: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/dashboard/qr-codes/list?"+tt.query, nil)
+			if got := parseQrCodesFilter(req); got != tt.want {
+				t.Errorf("parseQrCodesFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQrCodesFilterQuery(t *testing.T) {
+	tests := []struct {
+		filter qrCodesFilter
+		page   int
+		want   string
+	}{
+		{qrCodesFilter{Sort: "accessed", Page: 1}, 1, ""},
+		{qrCodesFilter{Sort: "accessed", Page: 1}, 2, "page=2"},
+		{qrCodesFilter{Search: "a&b=c", Domain: "example.com", Sort: "count", Page: 4}, 5, "domain=example.com&page=5&search=a%26b%3Dc&sort=count"},
+		{qrCodesFilter{Search: "café", Sort: "created", Page: 2}, 1, "search=caf%C3%A9&sort=created"},
+	}
+	for _, tt := range tests {
+		got := tt.filter.query(tt.page)
+		if got != tt.want {
+			t.Errorf("%+v.query(%d) = %q, want %q", tt.filter, tt.page, got, tt.want)
+		}
+
+		// Links built from the query must restore the same filter, on the requested page.
+		want := tt.filter
+		want.Page = tt.page
+		req := httptest.NewRequest("GET", "/dashboard/qr-codes/list?"+got, nil)
+		if parsed := parseQrCodesFilter(req); parsed != want {
+			t.Errorf("parseQrCodesFilter(%q) = %+v, want %+v", got, parsed, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+/**
+ * @fileoverview Selects or deselects all checkboxes in a list at once, for bulk actions.
+ *
+ * A checkbox with `data-select-all="{name}"` toggles every checkbox named `{name}` within the closest
+ * `[data-selection]` element. Events are delegated from the document, so that lists swapped in by htmx work
+ * without being initialized again.
+ */
+
+export function initBulkSelection() {
+  document.addEventListener('change', event => {
+    const toggle = event.target;
+    if (!(toggle instanceof HTMLInputElement) || !toggle.dataset.selectAll) {
+      return;
+    }
+    const container = toggle.closest('[data-selection]') || document;
+    container.querySelectorAll(`input[type="checkbox"][name="${toggle.dataset.selectAll}"]`).forEach(checkbox => {
+      checkbox.checked = toggle.checked;
+    });
+  });
+}
@@ -0,0 +1,96 @@
+package dashboard
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/qrcode"
+	"butterfly.chimbori.dev/validation"
+	"github.com/lmittmann/tint"
+)
+
+// GET /dashboard/qr-codes/sheet - Lay out QR Codes for many URLs on a printable sheet
+func qrCodeSheetPageHandler(w http.ResponseWriter, req *http.Request) {
+	QrCodeSheetPageTempl().Render(req.Context(), w)
+}
+
+// POST /dashboard/qr-codes/sheet - Generate a printable PDF or SVG sheet of QR Codes
+// Accepts URLs as “urls” (one per line) and/or repeated “url” parameters, along with the options described in
+// [qrcode.ParseSheetOptions], so that it can also be used as an authenticated API.
+func qrCodeSheetHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", req.URL.String(),
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := qrcode.ParseSheetOptions(req.Form)
+	if err != nil {
+		slog.Error("invalid sheet options", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userUrls []string
+	for _, userUrl := range slices.Concat(req.Form["url"], strings.Split(req.FormValue("urls"), "\n")) {
+		if userUrl = strings.TrimSpace(userUrl); userUrl != "" {
+			userUrls = append(userUrls, userUrl)
+		}
+	}
+	if err := opts.CheckCount(len(userUrls)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var urls, failures []string
+	for _, userUrl := range userUrls {
+		_, url, _, err := validation.ValidateUrl(ctx, queries, userUrl)
+		if err != nil {
+			failures = append(failures, userUrl+": "+err.Error())
+			continue
+		}
+		urls = append(urls, url)
+	}
+	if len(failures) > 0 {
+		slog.Error("URL validation failed", "failures", failures,
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, "invalid URLs:\n"+strings.Join(failures, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	sheet, err := qrcode.RenderSheet(req, urls, opts)
+	if err != nil {
+		slog.Error("failed to generate QR Code sheet", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("QR Code sheet generated",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"urls", len(urls),
+		"template", opts.Template.Name,
+		"status", http.StatusOK)
+
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="qr-codes-%s.%s"`, opts.Template.Name, opts.Style.Format))
+	w.Write(sheet)
+}
@@ -0,0 +1,264 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/embedfs"
+	"butterfly.chimbori.dev/linkpreviews"
+	"butterfly.chimbori.dev/validation"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+	"golang.org/x/net/html"
+)
+
+// templatePreset is a common size for Link Preview images, as displayed by various platforms.
+type templatePreset struct {
+	Name   string
+	Label  string
+	Width  int
+	Height int
+}
+
+var templatePresets = []templatePreset{
+	{Name: "opengraph", Label: "OpenGraph (Facebook, LinkedIn, etc.)", Width: 1200, Height: 630},
+	{Name: "twitter", Label: "X / Twitter", Width: 1200, Height: 600},
+	{Name: "square", Label: "Square (WhatsApp, etc.)", Width: 1200, Height: 1200},
+}
+
+// templateRender is the result of rendering a template at one of the [templatePresets].
+type templateRender struct {
+	Preset  templatePreset
+	DataUri string
+	Error   string
+}
+
+// GET /dashboard/templates?name={name} - Edit a new template, or a saved one.
+func templatesPageHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	templates, err := queries.ListTemplates(ctx)
+	if err != nil {
+		slog.Error("failed to list templates", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	current := db.Template{Html: embedfs.DefaultTemplate}
+	if name := req.URL.Query().Get("name"); name != "" {
+		current, err = queries.FindTemplate(ctx, name)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, pgx.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			slog.Error("failed to load template", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"status", status)
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+	TemplatesPageTempl(templates, current).Render(ctx, w)
+}
+
+// POST /dashboard/templates/render - Render the template in the editor at each of the selected presets,
+// filled in with either the sample title & description, or the metadata of a real URL.
+func renderTemplateHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	templateHtml := req.FormValue("html")
+	if err := validateTemplate(templateHtml); err != nil {
+		TemplateRendersTempl(nil, err.Error()).Render(ctx, w)
+		return
+	}
+
+	title, description := req.FormValue("title"), req.FormValue("description")
+	url := "https://example.com"
+	if reqUrl := strings.TrimSpace(req.FormValue("url")); reqUrl != "" {
+		var err error
+		url, title, description, err = fetchSampleMetadata(ctx, queries, reqUrl)
+		if err != nil {
+			slog.Error("failed to fetch metadata for template", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", reqUrl)
+			TemplateRendersTempl(nil, err.Error()).Render(ctx, w)
+			return
+		}
+	}
+
+	var renders []templateRender
+	for _, preset := range templatePresets {
+		if req.Form.Has("preset-" + preset.Name) {
+			renders = append(renders, renderTemplatePreset(ctx, templateHtml, url, title, description, preset))
+		}
+	}
+	if len(renders) == 0 {
+		TemplateRendersTempl(nil, "Select at least one size").Render(ctx, w)
+		return
+	}
+	TemplateRendersTempl(renders, "").Render(ctx, w)
+}
+
+// PUT /dashboard/templates/template - Save the template in the editor, replacing any with the same name.
+func putTemplateHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	if err := req.ParseForm(); err != nil {
+		slog.Error("failed to parse form", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.FormValue("name"))
+	templateHtml := req.FormValue("html")
+	err := validateTemplate(templateHtml)
+	if !linkpreviews.TemplateNameRegex.MatchString(name) {
+		err = fmt.Errorf("template names may only contain letters, digits, “-” and “_”")
+	}
+	if err != nil {
+		renderTemplatesList(w, req, queries, err.Error())
+		return
+	}
+
+	if err := queries.UpsertTemplate(ctx, db.UpsertTemplateParams{Name: name, Html: templateHtml}); err != nil {
+		slog.Error("failed to save template", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderTemplatesList(w, req, queries, "")
+}
+
+// DELETE /dashboard/templates/template?name={name} - Delete a saved template.
+func deleteTemplateHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name parameter", http.StatusBadRequest)
+		return
+	}
+	if err := queries.DeleteTemplate(ctx, name); err != nil {
+		slog.Error("failed to delete template", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderTemplatesList(w, req, queries, "")
+}
+
+func renderTemplatesList(w http.ResponseWriter, req *http.Request, queries *db.Queries, errorMsg string) {
+	templates, err := queries.ListTemplates(req.Context())
+	if err != nil {
+		slog.Error("failed to list templates", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	TemplatesListTempl(templates, errorMsg).Render(req.Context(), w)
+}
+
+// validateTemplate checks that a template can be parsed, and contains a #link-preview element, since
+// rendering would otherwise wait for that element until it times out.
+func validateTemplate(templateHtml string) error {
+	if _, err := template.New("playground").Parse(templateHtml); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	doc, err := html.Parse(strings.NewReader(templateHtml))
+	if err != nil {
+		return fmt.Errorf("invalid HTML: %w", err)
+	}
+	var found bool
+	var parse func(*html.Node)
+	parse = func(n *html.Node) {
+		for _, attr := range n.Attr {
+			if attr.Key == "id" && attr.Val == "link-preview" {
+				found = true
+			}
+		}
+		for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+			parse(c)
+		}
+	}
+	parse(doc)
+	if !found {
+		return errors.New(`template must contain an element with id="link-preview"`)
+	}
+	return nil
+}
+
+// fetchSampleMetadata fetches the title & description of a page on an authorized domain, using its
+// credentials, if any.
+func fetchSampleMetadata(ctx context.Context, queries *db.Queries, reqUrl string) (url, title, description string, err error) {
+	_, url, hostname, err := validation.ValidateUrl(ctx, queries, reqUrl)
+	if err != nil {
+		return "", "", "", err
+	}
+	creds, err := credentials.Find(ctx, queries, hostname)
+	if err != nil {
+		return "", "", "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, conf.Config.LinkPreviews.Screenshot.Timeout)
+	defer cancel()
+	title, description, err = core.FetchTitleAndDescription(ctx, url, core.WithCredentials(creds))
+	return url, title, description, err
+}
+
+// renderTemplatePreset renders a template in a browser window of the preset’s size, and crops the result
+// to exactly that size, the way most platforms would.
+func renderTemplatePreset(ctx context.Context, templateHtml, url, title, description string, preset templatePreset) templateRender {
+	ctx, cancel := context.WithTimeout(ctx, conf.Config.LinkPreviews.Screenshot.Timeout)
+	defer cancel()
+
+	render := templateRender{Preset: preset}
+	screenshot, err := core.TakeScreenshotWithTemplate(ctx, templateHtml, url, "#link-preview", title, description,
+		core.WithViewport(preset.Width, preset.Height))
+	if err == nil {
+		screenshot, err = core.ProcessImage(screenshot, []conf.ImageStep{
+			{Fit: &conf.FitStep{Width: preset.Width, Height: preset.Height}},
+		})
+	}
+	if err != nil {
+		slog.Error("failed to render template", tint.Err(err), "preset", preset.Name)
+		render.Error = err.Error()
+		return render
+	}
+	render.DataUri = "data:image/png;base64," + base64.StdEncoding.EncodeToString(screenshot)
+	return render
+}
@@ -38,6 +38,76 @@ func (q *Queries) DeleteUnauthorizedStaleDomains(ctx context.Context, dollar_1 p
 	return count, err
 }
 
+const findDomainEmulation = `-- name: FindDomainEmulation :one
+SELECT locale, timezone, color_scheme FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND (locale IS NOT NULL OR timezone IS NOT NULL OR color_scheme IS NOT NULL)
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1
+`
+
+type FindDomainEmulationRow struct {
+	Locale      *string
+	Timezone    *string
+	ColorScheme *string
+}
+
+func (q *Queries) FindDomainEmulation(ctx context.Context, domain string) (FindDomainEmulationRow, error) {
+	row := q.db.QueryRow(ctx, findDomainEmulation, domain)
+	var i FindDomainEmulationRow
+	err := row.Scan(&i.Locale, &i.Timezone, &i.ColorScheme)
+	return i, err
+}
+
+const findDomainImagePipeline = `-- name: FindDomainImagePipeline :one
+SELECT image_pipeline FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND image_pipeline IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1
+`
+
+func (q *Queries) FindDomainImagePipeline(ctx context.Context, domain string) (*string, error) {
+	row := q.db.QueryRow(ctx, findDomainImagePipeline, domain)
+	var image_pipeline *string
+	err := row.Scan(&image_pipeline)
+	return image_pipeline, err
+}
+
+const findDomainReferenceCheck = `-- name: FindDomainReferenceCheck :one
+SELECT reference_check FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND reference_check IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1
+`
+
+func (q *Queries) FindDomainReferenceCheck(ctx context.Context, domain string) (*bool, error) {
+	row := q.db.QueryRow(ctx, findDomainReferenceCheck, domain)
+	var reference_check *bool
+	err := row.Scan(&reference_check)
+	return reference_check, err
+}
+
+const findDomainSizeBudget = `-- name: FindDomainSizeBudget :one
+SELECT size_budget FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND size_budget IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1
+`
+
+func (q *Queries) FindDomainSizeBudget(ctx context.Context, domain string) (*string, error) {
+	row := q.db.QueryRow(ctx, findDomainSizeBudget, domain)
+	var size_budget *string
+	err := row.Scan(&size_budget)
+	return size_budget, err
+}
+
 const insertUnauthorizedDomain = `-- name: InsertUnauthorizedDomain :exec
 INSERT INTO domains (domain, include_subdomains, authorized, updated_at)
   VALUES ($1, false, NULL, NOW())
@@ -65,7 +135,7 @@ func (q *Queries) IsAuthorized(ctx context.Context, domain string) (bool, error)
 }
 
 const listDomains = `-- name: ListDomains :many
-SELECT _id, updated_at, domain, include_subdomains, authorized FROM domains
+SELECT _id, updated_at, domain, include_subdomains, authorized, image_pipeline, size_budget, reference_check, locale, timezone, color_scheme FROM domains
   ORDER BY authorized ASC, domain
   LIMIT 10000
 `
@@ -85,6 +155,12 @@ func (q *Queries) ListDomains(ctx context.Context) ([]Domain, error) {
 			&i.Domain,
 			&i.IncludeSubdomains,
 			&i.Authorized,
+			&i.ImagePipeline,
+			&i.SizeBudget,
+			&i.ReferenceCheck,
+			&i.Locale,
+			&i.Timezone,
+			&i.ColorScheme,
 		); err != nil {
 			return nil, err
 		}
@@ -96,6 +172,83 @@ func (q *Queries) ListDomains(ctx context.Context) ([]Domain, error) {
 	return items, nil
 }
 
+const updateDomainEmulation = `-- name: UpdateDomainEmulation :exec
+UPDATE domains
+  SET locale = $2,
+    timezone = $3,
+    color_scheme = $4,
+    updated_at = NOW()
+  WHERE domain = $1
+`
+
+type UpdateDomainEmulationParams struct {
+	Domain      string
+	Locale      *string
+	Timezone    *string
+	ColorScheme *string
+}
+
+func (q *Queries) UpdateDomainEmulation(ctx context.Context, arg UpdateDomainEmulationParams) error {
+	_, err := q.db.Exec(ctx, updateDomainEmulation,
+		arg.Domain,
+		arg.Locale,
+		arg.Timezone,
+		arg.ColorScheme,
+	)
+	return err
+}
+
+const updateDomainImagePipeline = `-- name: UpdateDomainImagePipeline :exec
+UPDATE domains
+  SET image_pipeline = $2,
+    updated_at = NOW()
+  WHERE domain = $1
+`
+
+type UpdateDomainImagePipelineParams struct {
+	Domain        string
+	ImagePipeline *string
+}
+
+func (q *Queries) UpdateDomainImagePipeline(ctx context.Context, arg UpdateDomainImagePipelineParams) error {
+	_, err := q.db.Exec(ctx, updateDomainImagePipeline, arg.Domain, arg.ImagePipeline)
+	return err
+}
+
+const updateDomainReferenceCheck = `-- name: UpdateDomainReferenceCheck :exec
+UPDATE domains
+  SET reference_check = $2,
+    updated_at = NOW()
+  WHERE domain = $1
+`
+
+type UpdateDomainReferenceCheckParams struct {
+	Domain         string
+	ReferenceCheck *bool
+}
+
+func (q *Queries) UpdateDomainReferenceCheck(ctx context.Context, arg UpdateDomainReferenceCheckParams) error {
+	_, err := q.db.Exec(ctx, updateDomainReferenceCheck, arg.Domain, arg.ReferenceCheck)
+	return err
+}
+
+const updateDomainSizeBudget = `-- name: UpdateDomainSizeBudget :exec
+UPDATE domains
+  SET size_budget = $2,
+    updated_at = NOW()
+  WHERE domain = $1
+`
+
+type UpdateDomainSizeBudgetParams struct {
+	Domain     string
+	SizeBudget *string
+}
+
+func (q *Queries) UpdateDomainSizeBudget(ctx context.Context, arg UpdateDomainSizeBudgetParams) error {
+	_, err := q.db.Exec(ctx, updateDomainSizeBudget, arg.Domain, arg.SizeBudget)
+	return err
+}
+
 const upsertDomain = `-- name: UpsertDomain :one
 INSERT INTO domains (domain, include_subdomains, authorized, updated_at)
   VALUES ($1, $2, $3, NOW())
@@ -104,7 +257,7 @@ INSERT INTO domains (domain, include_subdomains, authorized, updated_at)
     include_subdomains = EXCLUDED.include_subdomains,
     authorized = EXCLUDED.authorized,
     updated_at = NOW()
-  RETURNING _id, updated_at, domain, include_subdomains, authorized
+  RETURNING _id, updated_at, domain, include_subdomains, authorized, image_pipeline, size_budget, reference_check, locale, timezone, color_scheme
 `
 
 type UpsertDomainParams struct {
@@ -122,6 +275,12 @@ func (q *Queries) UpsertDomain(ctx context.Context, arg UpsertDomainParams) (Dom
 		&i.Domain,
 		&i.IncludeSubdomains,
 		&i.Authorized,
+		&i.ImagePipeline,
+		&i.SizeBudget,
+		&i.ReferenceCheck,
+		&i.Locale,
+		&i.Timezone,
+		&i.ColorScheme,
 	)
 	return i, err
 }
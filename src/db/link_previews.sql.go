@@ -40,7 +40,7 @@ func (q *Queries) DeleteLinkPreview(ctx context.Context, url string) error {
 }
 
 const getLinkPreview = `-- name: GetLinkPreview :one
-SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent FROM link_previews
+SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent, size_bytes, budget_bytes, original_bytes FROM link_previews
   WHERE url = $1
 `
 
@@ -54,6 +54,9 @@ func (q *Queries) GetLinkPreview(ctx context.Context, url string) (LinkPreview,
 		&i.LastAccessedAt,
 		&i.AccessCount,
 		&i.CanonicalUserAgent,
+		&i.SizeBytes,
+		&i.BudgetBytes,
+		&i.OriginalBytes,
 	)
 	return i, err
 }
@@ -130,7 +133,7 @@ func (q *Queries) GetLinkPreviewsByDomain(ctx context.Context) ([]GetLinkPreview
 }
 
 const listLinkPreviews = `-- name: ListLinkPreviews :many
-SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent FROM link_previews
+SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent, size_bytes, budget_bytes, original_bytes FROM link_previews
   ORDER BY last_accessed_at DESC
 `
 
@@ -150,6 +153,9 @@ func (q *Queries) ListLinkPreviews(ctx context.Context) ([]LinkPreview, error) {
 			&i.LastAccessedAt,
 			&i.AccessCount,
 			&i.CanonicalUserAgent,
+			&i.SizeBytes,
+			&i.BudgetBytes,
+			&i.OriginalBytes,
 		); err != nil {
 			return nil, err
 		}
@@ -162,7 +168,7 @@ func (q *Queries) ListLinkPreviews(ctx context.Context) ([]LinkPreview, error) {
 }
 
 const listLinkPreviewsPaginated = `-- name: ListLinkPreviewsPaginated :many
-SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent FROM link_previews
+SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent, size_bytes, budget_bytes, original_bytes FROM link_previews
   ORDER BY last_accessed_at DESC
   LIMIT $1 OFFSET $2
 `
@@ -188,6 +194,9 @@ func (q *Queries) ListLinkPreviewsPaginated(ctx context.Context, arg ListLinkPre
 			&i.LastAccessedAt,
 			&i.AccessCount,
 			&i.CanonicalUserAgent,
+			&i.SizeBytes,
+			&i.BudgetBytes,
+			&i.OriginalBytes,
 		); err != nil {
 			return nil, err
 		}
@@ -229,7 +238,7 @@ INSERT INTO link_previews (url, canonical_user_agent, generated_at, last_accesse
     last_accessed_at = NOW(),
     access_count = link_previews.access_count + 1,
     canonical_user_agent = $2
-  RETURNING _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent
+  RETURNING _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent, size_bytes, budget_bytes, original_bytes
 `
 
 type RecordLinkPreviewCreatedParams struct {
@@ -241,3 +250,28 @@ func (q *Queries) RecordLinkPreviewCreated(ctx context.Context, arg RecordLinkPr
 	_, err := q.db.Exec(ctx, recordLinkPreviewCreated, arg.Url, arg.CanonicalUserAgent)
 	return err
 }
+
+const recordLinkPreviewSize = `-- name: RecordLinkPreviewSize :exec
+UPDATE link_previews
+  SET size_bytes = $2,
+    budget_bytes = $3,
+    original_bytes = $4
+  WHERE url = $1
+`
+
+type RecordLinkPreviewSizeParams struct {
+	Url           string
+	SizeBytes     *int32
+	BudgetBytes   *int32
+	OriginalBytes *int32
+}
+
+func (q *Queries) RecordLinkPreviewSize(ctx context.Context, arg RecordLinkPreviewSizeParams) error {
+	_, err := q.db.Exec(ctx, recordLinkPreviewSize,
+		arg.Url,
+		arg.SizeBytes,
+		arg.BudgetBytes,
+		arg.OriginalBytes,
+	)
+	return err
+}
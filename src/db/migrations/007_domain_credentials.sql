@@ -0,0 +1,10 @@
+-- +goose Up
+
+CREATE TABLE domain_credentials (
+  _id         BIGSERIAL PRIMARY KEY,
+  domain      TEXT UNIQUE NOT NULL REFERENCES domains(domain) ON DELETE CASCADE,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  -- JSON-encoded credentials, encrypted using AES-GCM; never stored in plaintext.
+  encrypted   BYTEA NOT NULL
+);
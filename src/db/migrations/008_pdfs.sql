@@ -0,0 +1,16 @@
+-- +goose Up
+
+CREATE TABLE pdfs (
+  _id               BIGSERIAL PRIMARY KEY,
+  url               TEXT NOT NULL,
+  options           TEXT NOT NULL DEFAULT '',
+  generated_at      TIMESTAMPTZ DEFAULT NOW(),
+  last_accessed_at  TIMESTAMPTZ DEFAULT NOW(),
+  access_count      INTEGER DEFAULT 1,
+  UNIQUE (url, options)
+);
+
+CREATE INDEX idx_pdfs_url ON pdfs(url);
+CREATE INDEX idx_pdfs_generated_at ON pdfs(generated_at DESC);
+CREATE INDEX idx_pdfs_last_accessed_at ON pdfs(last_accessed_at DESC);
+CREATE INDEX idx_pdfs_access_count ON pdfs(access_count DESC);
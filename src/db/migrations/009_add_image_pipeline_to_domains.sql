@@ -0,0 +1,5 @@
+-- +goose Up
+
+-- Name of the image processing pipeline (from butterfly.yml) to apply to Link Previews for this domain.
+-- NULL uses the default pipeline.
+ALTER TABLE domains ADD COLUMN image_pipeline TEXT DEFAULT NULL;
@@ -0,0 +1,5 @@
+-- +goose Up
+
+-- Name of the size budget preset (from butterfly.yml) to apply to Link Previews for this domain.
+-- NULL uses the default preset.
+ALTER TABLE domains ADD COLUMN size_budget TEXT DEFAULT NULL;
@@ -0,0 +1,5 @@
+-- +goose Up
+
+-- Final size of the most recently generated Link Preview, and the budget it had to fit (NULL if none).
+ALTER TABLE link_previews ADD COLUMN size_bytes INTEGER;
+ALTER TABLE link_previews ADD COLUMN budget_bytes INTEGER;
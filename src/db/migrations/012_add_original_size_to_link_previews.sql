@@ -0,0 +1,4 @@
+-- +goose Up
+
+-- Size of the most recently generated Link Preview before compression, to show the savings.
+ALTER TABLE link_previews ADD COLUMN original_bytes INTEGER;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- Whether to verify, before the first render of a Link Preview for this domain, that the page itself
+-- references that exact Link Preview in its og:image or twitter:image meta tags.
+ALTER TABLE domains ADD COLUMN reference_check BOOLEAN DEFAULT FALSE;
+
+-- Verdicts of reference checks, so that pages are not fetched again for every request.
+CREATE TABLE reference_checks (
+  _id         BIGSERIAL PRIMARY KEY,
+  url         TEXT NOT NULL,
+  params      TEXT NOT NULL DEFAULT '',
+  verified    BOOLEAN NOT NULL,
+  checked_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (url, params)
+);
+
+CREATE INDEX idx_reference_checks_checked_at ON reference_checks(checked_at);
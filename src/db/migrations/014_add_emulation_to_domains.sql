@@ -0,0 +1,7 @@
+-- +goose Up
+
+-- Defaults for Link Previews of this domain, overridden by the lang, tz & scheme query parameters.
+-- NULL keeps Chrome’s defaults.
+ALTER TABLE domains ADD COLUMN locale TEXT DEFAULT NULL;
+ALTER TABLE domains ADD COLUMN timezone TEXT DEFAULT NULL;
+ALTER TABLE domains ADD COLUMN color_scheme TEXT DEFAULT NULL;
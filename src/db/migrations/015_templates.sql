@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Named templates for Link Previews, designed in the Dashboard, and selected using “&template={name}”.
+CREATE TABLE templates (
+  _id         BIGSERIAL PRIMARY KEY,
+  name        TEXT UNIQUE NOT NULL,
+  html        TEXT NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
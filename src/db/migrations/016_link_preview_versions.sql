@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Recent renders of each Link Preview (by cache key, i.e. URL & variant), whose images are stored on
+-- disk by _id, so that a broken design can be compared against, and rolled back to, a previous one.
+-- A pinned version is served instead of re-rendering the preview when it expires from the cache.
+CREATE TABLE link_preview_versions (
+  _id         BIGSERIAL PRIMARY KEY,
+  url         TEXT NOT NULL,
+  cache_key   TEXT NOT NULL,
+  size_bytes  INTEGER NOT NULL,
+  pinned      BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_link_preview_versions_url ON link_preview_versions(url);
+CREATE INDEX idx_link_preview_versions_cache_key ON link_preview_versions(cache_key);
@@ -0,0 +1,4 @@
+-- +goose Up
+
+-- Compact diagnostics captured while rendering, e.g. console errors & failed network requests.
+ALTER TABLE logs ADD COLUMN details TEXT;
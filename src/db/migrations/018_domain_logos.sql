@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Logos to be overlaid on QR Codes for a domain, using “&logo=domain”; stored as PNG.
+CREATE TABLE domain_logos (
+  _id         BIGSERIAL PRIMARY KEY,
+  domain      TEXT UNIQUE NOT NULL REFERENCES domains(domain) ON DELETE CASCADE,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  image       BYTEA NOT NULL
+);
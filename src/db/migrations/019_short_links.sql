@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- Short links encoded in tracked QR Codes (“&track=1”) instead of the URL itself. Each redirects to its
+-- target URL, which can be changed without reprinting the QR Code, and records every scan.
+CREATE TABLE short_links (
+  _id         BIGSERIAL PRIMARY KEY,
+  slug        TEXT UNIQUE NOT NULL,
+  url         TEXT UNIQUE NOT NULL, -- The URL for which the QR Code was requested.
+  target_url  TEXT NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE short_link_scans (
+  _id                   BIGSERIAL PRIMARY KEY,
+  slug                  TEXT NOT NULL REFERENCES short_links(slug) ON DELETE CASCADE,
+  scanned_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  canonical_user_agent  TEXT,
+  referer               TEXT
+);
+
+CREATE INDEX idx_short_link_scans_slug ON short_link_scans(slug);
+CREATE INDEX idx_short_link_scans_scanned_at ON short_link_scans(scanned_at DESC);
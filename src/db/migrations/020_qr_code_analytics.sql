@@ -0,0 +1,18 @@
+-- +goose Up
+
+ALTER TABLE qr_codes ADD COLUMN canonical_user_agent TEXT;
+
+CREATE INDEX idx_qr_codes_canonical_user_agent ON qr_codes(canonical_user_agent);
+
+-- Accesses of each QR Code, rolled up by day & canonical user agent, so that charts count every access,
+-- not just the most recent one.
+CREATE TABLE qr_code_daily_accesses (
+  _id                   BIGSERIAL PRIMARY KEY,
+  url                   TEXT NOT NULL,
+  day                   DATE NOT NULL DEFAULT CURRENT_DATE,
+  canonical_user_agent  TEXT NOT NULL,
+  access_count          INTEGER NOT NULL DEFAULT 1,
+  UNIQUE (url, day, canonical_user_agent)
+);
+
+CREATE INDEX idx_qr_code_daily_accesses_day ON qr_code_daily_accesses(day DESC);
@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- QR Codes for structured payloads other than authorized URLs (Wi-Fi credentials, vCards, calendar events,
+-- geo locations, and plain text), generated from the Dashboard only.
+CREATE TABLE qr_payloads (
+  _id         BIGSERIAL PRIMARY KEY,
+  name        TEXT NOT NULL,
+  kind        TEXT NOT NULL,            -- wifi, vcard, event, geo, or text.
+  payload     TEXT NOT NULL,            -- The text encoded in the QR Code.
+  style       TEXT NOT NULL DEFAULT '', -- Styling parameters, as a canonical query string, e.g. “fg=1a237e&shape=circle”.
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_qr_payloads_created_at ON qr_payloads(created_at DESC);
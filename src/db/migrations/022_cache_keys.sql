@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- Keys of the files in disk caches, by the URL they were rendered for, so that every variant of a URL
+-- (styles, formats, emulation settings, etc.) can be deleted together. Keys are hashed on disk, so they
+-- cannot be listed from the cache itself. Rows are refreshed on every write, and pruned once their files
+-- have expired.
+CREATE TABLE cache_keys (
+  cache       TEXT NOT NULL,            -- link-previews, or qr-codes.
+  cache_key   TEXT NOT NULL,
+  url         TEXT NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (cache, cache_key)
+);
+
+CREATE INDEX idx_cache_keys_url ON cache_keys(cache, url);
@@ -0,0 +1,5 @@
+-- +goose Up
+
+-- Payloads containing secrets (i.e. Wi-Fi passwords) are encrypted using the same key as domain credentials,
+-- leaving “payload” empty.
+ALTER TABLE qr_payloads ADD COLUMN encrypted BYTEA;
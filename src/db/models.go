@@ -6,14 +6,43 @@ package db
 
 import (
 	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type CacheKey struct {
+	Cache     string
+	CacheKey  string
+	Url       string
+	UpdatedAt time.Time
+}
+
 type Domain struct {
 	ID                int64
 	UpdatedAt         time.Time
 	Domain            string
 	IncludeSubdomains *bool
 	Authorized        *bool
+	ImagePipeline     *string
+	SizeBudget        *string
+	ReferenceCheck    *bool
+	Locale            *string
+	Timezone          *string
+	ColorScheme       *string
+}
+
+type DomainCredential struct {
+	ID        int64
+	Domain    string
+	UpdatedAt time.Time
+	Encrypted []byte
+}
+
+type DomainLogo struct {
+	ID        int64
+	Domain    string
+	UpdatedAt time.Time
+	Image     []byte
 }
 
 type LinkPreview struct {
@@ -23,6 +52,18 @@ type LinkPreview struct {
 	LastAccessedAt     *time.Time
 	AccessCount        *int32
 	CanonicalUserAgent *string
+	SizeBytes          *int32
+	BudgetBytes        *int32
+	OriginalBytes      *int32
+}
+
+type LinkPreviewVersion struct {
+	ID        int64
+	Url       string
+	CacheKey  string
+	SizeBytes int32
+	Pinned    bool
+	CreatedAt time.Time
 }
 
 type Log struct {
@@ -36,12 +77,73 @@ type Log struct {
 	Message       *string
 	Err           *string
 	UserAgent     *string
+	Details       *string
 }
 
-type QrCode struct {
+type Pdf struct {
 	ID             int64
 	Url            string
+	Options        string
 	GeneratedAt    *time.Time
 	LastAccessedAt *time.Time
 	AccessCount    *int32
 }
+
+type QrCode struct {
+	ID                 int64
+	Url                string
+	GeneratedAt        *time.Time
+	LastAccessedAt     *time.Time
+	AccessCount        *int32
+	CanonicalUserAgent *string
+}
+
+type QrCodeDailyAccess struct {
+	ID                 int64
+	Url                string
+	Day                pgtype.Date
+	CanonicalUserAgent string
+	AccessCount        int32
+}
+
+type QrPayload struct {
+	ID        int64
+	Name      string
+	Kind      string
+	Payload   string
+	Style     string
+	CreatedAt time.Time
+	Encrypted []byte
+}
+
+type ReferenceCheck struct {
+	ID        int64
+	Url       string
+	Params    string
+	Verified  bool
+	CheckedAt time.Time
+}
+
+type ShortLink struct {
+	ID        int64
+	Slug      string
+	Url       string
+	TargetUrl string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ShortLinkScan struct {
+	ID                 int64
+	Slug               string
+	ScannedAt          time.Time
+	CanonicalUserAgent *string
+	Referer            *string
+}
+
+type Template struct {
+	ID        int64
+	Name      string
+	Html      string
+	UpdatedAt time.Time
+}
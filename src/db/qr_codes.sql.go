@@ -7,9 +7,32 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countQrCodes = `-- name: CountQrCodes :one
+SELECT COUNT(*) FROM qr_codes
+  WHERE ($1::text = '' OR STRPOS(LOWER(url), LOWER($1::text)) > 0)
+    AND ($2::text = '' OR COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) = $2::text)
+`
+
+type CountQrCodesParams struct {
+	Search string
+	Domain string
+}
+
+func (q *Queries) CountQrCodes(ctx context.Context, arg CountQrCodesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countQrCodes, arg.Search, arg.Domain)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const deleteAllQrCodes = `-- name: DeleteAllQrCodes :exec
+WITH deleted_accesses AS (
+  DELETE FROM qr_code_daily_accesses
+)
 DELETE FROM qr_codes
 `
 
@@ -18,18 +41,37 @@ func (q *Queries) DeleteAllQrCodes(ctx context.Context) error {
 	return err
 }
 
+const deleteOldQrCodeDailyAccesses = `-- name: DeleteOldQrCodeDailyAccesses :execrows
+DELETE FROM qr_code_daily_accesses
+  WHERE day < CURRENT_DATE - $1::interval
+`
+
+func (q *Queries) DeleteOldQrCodeDailyAccesses(ctx context.Context, dollar_1 pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldQrCodeDailyAccesses, dollar_1)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteQrCode = `-- name: DeleteQrCode :exec
+WITH deleted_accesses AS (
+  DELETE FROM qr_code_daily_accesses
+    WHERE url = $1
+)
 DELETE FROM qr_codes
   WHERE url = $1
 `
 
+// Also deletes its daily accesses.
+
 func (q *Queries) DeleteQrCode(ctx context.Context, url string) error {
 	_, err := q.db.Exec(ctx, deleteQrCode, url)
 	return err
 }
 
 const getQrCode = `-- name: GetQrCode :one
-SELECT _id, url, generated_at, last_accessed_at, access_count FROM qr_codes
+SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent FROM qr_codes
   WHERE url = $1
 `
 
@@ -42,17 +84,112 @@ func (q *Queries) GetQrCode(ctx context.Context, url string) (QrCode, error) {
 		&i.GeneratedAt,
 		&i.LastAccessedAt,
 		&i.AccessCount,
+		&i.CanonicalUserAgent,
 	)
 	return i, err
 }
 
-const listQrCodes = `-- name: ListQrCodes :many
-SELECT _id, url, generated_at, last_accessed_at, access_count FROM qr_codes
-  ORDER BY last_accessed_at DESC
+const getQrCodeUserAgentsByDay = `-- name: GetQrCodeUserAgentsByDay :many
+SELECT
+    to_char(day, 'YYYY-MM-DD') as day,
+    canonical_user_agent,
+    SUM(access_count)::bigint as total_accesses
+  FROM qr_code_daily_accesses
+  WHERE day > CURRENT_DATE - ($1 * INTERVAL '1 day')
+  GROUP BY qr_code_daily_accesses.day, canonical_user_agent
+  ORDER BY qr_code_daily_accesses.day DESC, total_accesses DESC
+`
+
+type GetQrCodeUserAgentsByDayRow struct {
+	Day                string
+	CanonicalUserAgent string
+	TotalAccesses      int64
+}
+
+func (q *Queries) GetQrCodeUserAgentsByDay(ctx context.Context, dollar_1 interface{}) ([]GetQrCodeUserAgentsByDayRow, error) {
+	rows, err := q.db.Query(ctx, getQrCodeUserAgentsByDay, dollar_1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetQrCodeUserAgentsByDayRow
+	for rows.Next() {
+		var i GetQrCodeUserAgentsByDayRow
+		if err := rows.Scan(&i.Day, &i.CanonicalUserAgent, &i.TotalAccesses); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQrCodesByDomain = `-- name: GetQrCodesByDomain :many
+SELECT
+    COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) as domain,
+    COALESCE(SUM(COALESCE(access_count, 0)), 0)::bigint as total_accesses
+  FROM qr_codes
+  GROUP BY domain
+  ORDER BY total_accesses DESC
+`
+
+type GetQrCodesByDomainRow struct {
+	Domain        string
+	TotalAccesses int64
+}
+
+func (q *Queries) GetQrCodesByDomain(ctx context.Context) ([]GetQrCodesByDomainRow, error) {
+	rows, err := q.db.Query(ctx, getQrCodesByDomain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetQrCodesByDomainRow
+	for rows.Next() {
+		var i GetQrCodesByDomainRow
+		if err := rows.Scan(&i.Domain, &i.TotalAccesses); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listQrCodesPaginated = `-- name: ListQrCodesPaginated :many
+SELECT _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent FROM qr_codes
+  WHERE ($1::text = '' OR STRPOS(LOWER(url), LOWER($1::text)) > 0)
+    AND ($2::text = '' OR COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) = $2::text)
+  ORDER BY
+    CASE WHEN $3::text = 'created' THEN generated_at END DESC NULLS LAST,
+    CASE WHEN $3::text = 'count' THEN access_count END DESC NULLS LAST,
+    last_accessed_at DESC NULLS LAST,
+    url
+  LIMIT $4 OFFSET $5
 `
 
-func (q *Queries) ListQrCodes(ctx context.Context) ([]QrCode, error) {
-	rows, err := q.db.Query(ctx, listQrCodes)
+type ListQrCodesPaginatedParams struct {
+	Search    string
+	Domain    string
+	Sort      string
+	RowLimit  int32
+	RowOffset int32
+}
+
+// Filters by a substring of the URL, and/or its domain (as grouped in GetQrCodesByDomain), if not empty, and
+// sorts by “created”, “count”, or by default, the most recently accessed.
+func (q *Queries) ListQrCodesPaginated(ctx context.Context, arg ListQrCodesPaginatedParams) ([]QrCode, error) {
+	rows, err := q.db.Query(ctx, listQrCodesPaginated,
+		arg.Search,
+		arg.Domain,
+		arg.Sort,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +203,7 @@ func (q *Queries) ListQrCodes(ctx context.Context) ([]QrCode, error) {
 			&i.GeneratedAt,
 			&i.LastAccessedAt,
 			&i.AccessCount,
+			&i.CanonicalUserAgent,
 		); err != nil {
 			return nil, err
 		}
@@ -77,30 +215,63 @@ func (q *Queries) ListQrCodes(ctx context.Context) ([]QrCode, error) {
 	return items, nil
 }
 
-const recordQrCodeAccessed = `-- name: RecordQrCodeAccessed :exec
+const recordQrCodeAccessed = `-- name: RecordQrCodeAccessed :execrows
 UPDATE qr_codes
   SET last_accessed_at = NOW(),
-    access_count = access_count + 1
+    access_count = access_count + 1,
+    canonical_user_agent = $2
   WHERE url = $1
 `
 
-func (q *Queries) RecordQrCodeAccessed(ctx context.Context, url string) error {
-	_, err := q.db.Exec(ctx, recordQrCodeAccessed, url)
-	return err
+type RecordQrCodeAccessedParams struct {
+	Url                string
+	CanonicalUserAgent *string
+}
+
+func (q *Queries) RecordQrCodeAccessed(ctx context.Context, arg RecordQrCodeAccessedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, recordQrCodeAccessed, arg.Url, arg.CanonicalUserAgent)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const recordQrCodeCreated = `-- name: RecordQrCodeCreated :exec
-INSERT INTO qr_codes (url, generated_at, last_accessed_at, access_count)
-  VALUES ($1, NOW(), NOW(), 1)
+INSERT INTO qr_codes (url, canonical_user_agent, generated_at, last_accessed_at, access_count)
+  VALUES ($1, $2, NOW(), NOW(), 1)
   ON CONFLICT(url)
   DO UPDATE SET
     generated_at = NOW(),
     last_accessed_at = NOW(),
-    access_count = qr_codes.access_count + 1
-  RETURNING _id, url, generated_at, last_accessed_at, access_count
+    access_count = qr_codes.access_count + 1,
+    canonical_user_agent = $2
+  RETURNING _id, url, generated_at, last_accessed_at, access_count, canonical_user_agent
+`
+
+type RecordQrCodeCreatedParams struct {
+	Url                string
+	CanonicalUserAgent *string
+}
+
+func (q *Queries) RecordQrCodeCreated(ctx context.Context, arg RecordQrCodeCreatedParams) error {
+	_, err := q.db.Exec(ctx, recordQrCodeCreated, arg.Url, arg.CanonicalUserAgent)
+	return err
+}
+
+const recordQrCodeDailyAccess = `-- name: RecordQrCodeDailyAccess :exec
+INSERT INTO qr_code_daily_accesses (url, day, canonical_user_agent, access_count)
+  VALUES ($1, CURRENT_DATE, $2, 1)
+  ON CONFLICT(url, day, canonical_user_agent)
+  DO UPDATE SET
+    access_count = qr_code_daily_accesses.access_count + 1
 `
 
-func (q *Queries) RecordQrCodeCreated(ctx context.Context, url string) error {
-	_, err := q.db.Exec(ctx, recordQrCodeCreated, url)
+type RecordQrCodeDailyAccessParams struct {
+	Url                string
+	CanonicalUserAgent string
+}
+
+func (q *Queries) RecordQrCodeDailyAccess(ctx context.Context, arg RecordQrCodeDailyAccessParams) error {
+	_, err := q.db.Exec(ctx, recordQrCodeDailyAccess, arg.Url, arg.CanonicalUserAgent)
 	return err
 }
@@ -0,0 +1,18 @@
+-- name: RecordCacheKey :exec
+INSERT INTO cache_keys (cache, cache_key, url)
+  VALUES ($1, $2, $3)
+  ON CONFLICT(cache, cache_key)
+  DO UPDATE SET
+    url = EXCLUDED.url,
+    updated_at = NOW();
+
+-- name: DeleteCacheKeys :many
+DELETE FROM cache_keys
+  WHERE cache = $1
+    AND url = $2
+  RETURNING cache_key;
+
+-- name: DeleteExpiredCacheKeys :execrows
+DELETE FROM cache_keys
+  WHERE cache = $1
+    AND updated_at < NOW() - sqlc.arg(ttl)::interval;
@@ -0,0 +1,30 @@
+-- name: ListDomainsWithCredentials :many
+SELECT domain FROM domain_credentials
+  ORDER BY domain;
+
+-- name: GetDomainCredentials :one
+SELECT encrypted FROM domain_credentials
+  WHERE domain = $1;
+
+-- name: FindDomainCredentials :one
+-- Compares domains exactly (ignoring case), instead of using ILIKE like other domain lookups, so that “%” or
+-- “_” in a hostname can never match the credentials of another domain.
+SELECT domain_credentials.encrypted FROM domain_credentials
+  JOIN domains ON domains.domain = domain_credentials.domain
+  WHERE (lower(domains.domain) = lower(sqlc.arg(domain)::text)
+    OR (domains.include_subdomains = true AND right(lower(sqlc.arg(domain)::text), length(domains.domain) + 1) = '.' || lower(domains.domain)))
+  AND domains.authorized IS TRUE
+  ORDER BY LENGTH(domains.domain) DESC
+  LIMIT 1;
+
+-- name: UpsertDomainCredentials :exec
+INSERT INTO domain_credentials (domain, encrypted, updated_at)
+  VALUES ($1, $2, NOW())
+  ON CONFLICT(domain)
+  DO UPDATE SET
+    encrypted = EXCLUDED.encrypted,
+    updated_at = NOW();
+
+-- name: DeleteDomainCredentials :exec
+DELETE FROM domain_credentials
+  WHERE domain = $1;
@@ -0,0 +1,31 @@
+-- name: GetDomainLogo :one
+SELECT image FROM domain_logos
+  WHERE domain = $1;
+
+-- name: FindDomainLogo :one
+SELECT domain_logos.image FROM domain_logos
+  JOIN domains ON domains.domain = domain_logos.domain
+  WHERE (domains.domain ILIKE $1 OR (domains.include_subdomains = true AND $1 ILIKE '%.' || domains.domain))
+  AND domains.authorized IS TRUE
+  ORDER BY LENGTH(domains.domain) DESC
+  LIMIT 1;
+
+-- name: FindDomainLogoUpdatedAt :one
+SELECT domain_logos.updated_at FROM domain_logos
+  JOIN domains ON domains.domain = domain_logos.domain
+  WHERE (domains.domain ILIKE $1 OR (domains.include_subdomains = true AND $1 ILIKE '%.' || domains.domain))
+  AND domains.authorized IS TRUE
+  ORDER BY LENGTH(domains.domain) DESC
+  LIMIT 1;
+
+-- name: UpsertDomainLogo :exec
+INSERT INTO domain_logos (domain, image, updated_at)
+  VALUES ($1, $2, NOW())
+  ON CONFLICT(domain)
+  DO UPDATE SET
+    image = EXCLUDED.image,
+    updated_at = NOW();
+
+-- name: DeleteDomainLogo :exec
+DELETE FROM domain_logos
+  WHERE domain = $1;
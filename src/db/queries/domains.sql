@@ -37,3 +37,61 @@ SELECT EXISTS (
   WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
   AND authorized IS TRUE
 );
+
+-- name: UpdateDomainImagePipeline :exec
+UPDATE domains
+  SET image_pipeline = $2,
+    updated_at = NOW()
+  WHERE domain = $1;
+
+-- name: FindDomainImagePipeline :one
+SELECT image_pipeline FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND image_pipeline IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1;
+
+-- name: UpdateDomainSizeBudget :exec
+UPDATE domains
+  SET size_budget = $2,
+    updated_at = NOW()
+  WHERE domain = $1;
+
+-- name: FindDomainSizeBudget :one
+SELECT size_budget FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND size_budget IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1;
+
+-- name: UpdateDomainReferenceCheck :exec
+UPDATE domains
+  SET reference_check = $2,
+    updated_at = NOW()
+  WHERE domain = $1;
+
+-- name: FindDomainReferenceCheck :one
+SELECT reference_check FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND reference_check IS NOT NULL
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1;
+
+-- name: UpdateDomainEmulation :exec
+UPDATE domains
+  SET locale = $2,
+    timezone = $3,
+    color_scheme = $4,
+    updated_at = NOW()
+  WHERE domain = $1;
+
+-- name: FindDomainEmulation :one
+SELECT locale, timezone, color_scheme FROM domains
+  WHERE (domain ILIKE $1 OR (include_subdomains = true AND $1 ILIKE '%.' || domain))
+  AND authorized IS TRUE
+  AND (locale IS NOT NULL OR timezone IS NOT NULL OR color_scheme IS NOT NULL)
+  ORDER BY LENGTH(domain) DESC
+  LIMIT 1;
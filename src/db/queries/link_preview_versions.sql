@@ -0,0 +1,50 @@
+-- name: CreateLinkPreviewVersion :one
+INSERT INTO link_preview_versions (url, cache_key, size_bytes)
+  VALUES ($1, $2, $3)
+  RETURNING _id;
+
+-- name: ListLinkPreviewVersions :many
+SELECT * FROM link_preview_versions
+  WHERE url = $1
+  ORDER BY _id DESC;
+
+-- name: GetLinkPreviewVersion :one
+SELECT * FROM link_preview_versions
+  WHERE _id = $1;
+
+-- name: FindPinnedLinkPreviewVersion :one
+SELECT * FROM link_preview_versions
+  WHERE cache_key = $1
+    AND pinned IS TRUE
+  LIMIT 1;
+
+-- name: PinLinkPreviewVersion :exec
+UPDATE link_preview_versions
+  SET pinned = (_id = $1)
+  WHERE cache_key = (SELECT cache_key FROM link_preview_versions WHERE _id = $1);
+
+-- name: UnpinLinkPreviewVersions :exec
+UPDATE link_preview_versions
+  SET pinned = FALSE
+  WHERE cache_key = $1;
+
+-- name: DeleteLinkPreviewVersion :exec
+DELETE FROM link_preview_versions
+  WHERE _id = $1;
+
+-- name: DeleteLinkPreviewVersions :many
+DELETE FROM link_preview_versions
+  WHERE url = $1
+  RETURNING _id;
+
+-- name: DeleteOldLinkPreviewVersions :many
+DELETE FROM link_preview_versions
+  WHERE cache_key = $1
+    AND pinned IS FALSE
+    AND _id NOT IN (
+      SELECT _id FROM link_preview_versions
+        WHERE cache_key = $1
+        ORDER BY _id DESC
+        LIMIT $2
+    )
+  RETURNING _id;
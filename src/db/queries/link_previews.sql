@@ -39,6 +39,13 @@ UPDATE link_previews
     canonical_user_agent = $2
   WHERE url = $1;
 
+-- name: RecordLinkPreviewSize :exec
+UPDATE link_previews
+  SET size_bytes = $2,
+    budget_bytes = $3,
+    original_bytes = $4
+  WHERE url = $1;
+
 -- name: GetLinkPreviewsByDomain :many
 SELECT
     COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) as domain,
@@ -2,8 +2,8 @@
 INSERT INTO logs (
   request_method, request_path, http_status,
   url, hostname, user_agent,
-  message, err
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+  message, err, details
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
 
 -- name: GetRecentLogs :many
 SELECT * FROM logs
@@ -0,0 +1,22 @@
+-- name: ListPdfs :many
+SELECT * FROM pdfs
+  ORDER BY last_accessed_at DESC;
+
+-- name: DeletePdf :exec
+DELETE FROM pdfs
+  WHERE url = $1 AND options = $2;
+
+-- name: RecordPdfCreated :exec
+INSERT INTO pdfs (url, options, generated_at, last_accessed_at, access_count)
+  VALUES ($1, $2, NOW(), NOW(), 1)
+  ON CONFLICT(url, options)
+  DO UPDATE SET
+    generated_at = NOW(),
+    last_accessed_at = NOW(),
+    access_count = pdfs.access_count + 1;
+
+-- name: RecordPdfAccessed :execrows
+UPDATE pdfs
+  SET last_accessed_at = NOW(),
+    access_count = access_count + 1
+  WHERE url = $1 AND options = $2;
@@ -1,30 +1,83 @@
--- name: ListQrCodes :many
+-- name: ListQrCodesPaginated :many
+-- Filters by a substring of the URL, and/or its domain (as grouped in GetQrCodesByDomain), if not empty, and
+-- sorts by “created”, “count”, or by default, the most recently accessed.
 SELECT * FROM qr_codes
-  ORDER BY last_accessed_at DESC;
+  WHERE (sqlc.arg(search)::text = '' OR STRPOS(LOWER(url), LOWER(sqlc.arg(search)::text)) > 0)
+    AND (sqlc.arg(domain)::text = '' OR COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) = sqlc.arg(domain)::text)
+  ORDER BY
+    CASE WHEN sqlc.arg(sort)::text = 'created' THEN generated_at END DESC NULLS LAST,
+    CASE WHEN sqlc.arg(sort)::text = 'count' THEN access_count END DESC NULLS LAST,
+    last_accessed_at DESC NULLS LAST,
+    url
+  LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: CountQrCodes :one
+SELECT COUNT(*) FROM qr_codes
+  WHERE (sqlc.arg(search)::text = '' OR STRPOS(LOWER(url), LOWER(sqlc.arg(search)::text)) > 0)
+    AND (sqlc.arg(domain)::text = '' OR COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) = sqlc.arg(domain)::text);
 
 -- name: GetQrCode :one
 SELECT * FROM qr_codes
   WHERE url = $1;
 
 -- name: DeleteQrCode :exec
+-- Also deletes its daily accesses.
+WITH deleted_accesses AS (
+  DELETE FROM qr_code_daily_accesses
+    WHERE url = $1
+)
 DELETE FROM qr_codes
   WHERE url = $1;
 
 -- name: DeleteAllQrCodes :exec
+WITH deleted_accesses AS (
+  DELETE FROM qr_code_daily_accesses
+)
 DELETE FROM qr_codes;
 
 -- name: RecordQrCodeCreated :exec
-INSERT INTO qr_codes (url, generated_at, last_accessed_at, access_count)
-  VALUES ($1, NOW(), NOW(), 1)
+INSERT INTO qr_codes (url, canonical_user_agent, generated_at, last_accessed_at, access_count)
+  VALUES ($1, $2, NOW(), NOW(), 1)
   ON CONFLICT(url)
   DO UPDATE SET
     generated_at = NOW(),
     last_accessed_at = NOW(),
-    access_count = qr_codes.access_count + 1
+    access_count = qr_codes.access_count + 1,
+    canonical_user_agent = $2
   RETURNING *;
 
--- name: RecordQrCodeAccessed :exec
+-- name: RecordQrCodeAccessed :execrows
 UPDATE qr_codes
   SET last_accessed_at = NOW(),
-    access_count = access_count + 1
+    access_count = access_count + 1,
+    canonical_user_agent = $2
   WHERE url = $1;
+
+-- name: RecordQrCodeDailyAccess :exec
+INSERT INTO qr_code_daily_accesses (url, day, canonical_user_agent, access_count)
+  VALUES ($1, CURRENT_DATE, $2, 1)
+  ON CONFLICT(url, day, canonical_user_agent)
+  DO UPDATE SET
+    access_count = qr_code_daily_accesses.access_count + 1;
+
+-- name: DeleteOldQrCodeDailyAccesses :execrows
+DELETE FROM qr_code_daily_accesses
+  WHERE day < CURRENT_DATE - $1::interval;
+
+-- name: GetQrCodesByDomain :many
+SELECT
+    COALESCE(SUBSTRING(url FROM 'https?://(?:www\.)?([^/]+)'), url) as domain,
+    COALESCE(SUM(COALESCE(access_count, 0)), 0)::bigint as total_accesses
+  FROM qr_codes
+  GROUP BY domain
+  ORDER BY total_accesses DESC;
+
+-- name: GetQrCodeUserAgentsByDay :many
+SELECT
+    to_char(day, 'YYYY-MM-DD') as day,
+    canonical_user_agent,
+    SUM(access_count)::bigint as total_accesses
+  FROM qr_code_daily_accesses
+  WHERE day > CURRENT_DATE - ($1 * INTERVAL '1 day')
+  GROUP BY qr_code_daily_accesses.day, canonical_user_agent
+  ORDER BY qr_code_daily_accesses.day DESC, total_accesses DESC;
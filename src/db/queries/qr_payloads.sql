@@ -0,0 +1,28 @@
+-- name: ListQrPayloads :many
+SELECT * FROM qr_payloads
+  ORDER BY created_at DESC;
+
+-- name: GetQrPayload :one
+SELECT * FROM qr_payloads
+  WHERE _id = $1;
+
+-- name: CreateQrPayload :one
+INSERT INTO qr_payloads (name, kind, payload, style, encrypted)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING *;
+
+-- name: DeleteQrPayload :exec
+DELETE FROM qr_payloads
+  WHERE _id = $1;
+
+-- name: ListPlaintextQrPayloads :many
+-- Lists payloads of a kind saved before they were encrypted.
+SELECT * FROM qr_payloads
+  WHERE kind = $1
+    AND encrypted IS NULL;
+
+-- name: EncryptQrPayload :exec
+UPDATE qr_payloads
+  SET payload = '',
+    encrypted = $2
+  WHERE _id = $1;
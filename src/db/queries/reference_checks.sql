@@ -0,0 +1,20 @@
+-- name: FindReferenceCheck :one
+SELECT verified FROM reference_checks
+  WHERE url = $1 AND params = $2
+  AND checked_at > NOW() - sqlc.arg(ttl)::interval;
+
+-- name: RecordReferenceCheck :exec
+INSERT INTO reference_checks (url, params, verified, checked_at)
+  VALUES ($1, $2, $3, NOW())
+  ON CONFLICT(url, params)
+  DO UPDATE SET
+    verified = EXCLUDED.verified,
+    checked_at = NOW();
+
+-- name: DeleteExpiredReferenceChecks :one
+WITH deleted AS (
+  DELETE FROM reference_checks
+    WHERE checked_at < NOW() - sqlc.arg(ttl)::interval
+    RETURNING _id
+  )
+  SELECT COUNT(*) from deleted;
@@ -0,0 +1,56 @@
+-- name: ListShortLinksPaginated :many
+-- Scans are counted only for the short links on this page, and only those within the log retention period.
+SELECT
+    short_links.*,
+    (SELECT COUNT(*) FROM short_link_scans WHERE short_link_scans.slug = short_links.slug)::bigint as scan_count
+  FROM short_links
+  ORDER BY short_links.created_at DESC
+  LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: CountShortLinks :one
+SELECT COUNT(*) FROM short_links;
+
+-- name: GetShortLink :one
+SELECT * FROM short_links
+  WHERE slug = $1;
+
+-- name: GetShortLinkByUrl :one
+SELECT * FROM short_links
+  WHERE url = $1;
+
+-- name: CreateShortLink :one
+INSERT INTO short_links (slug, url, target_url)
+  VALUES ($1, $2, $2)
+  ON CONFLICT(url)
+  DO UPDATE SET
+    url = EXCLUDED.url
+  RETURNING *;
+
+-- name: RetargetShortLink :execrows
+UPDATE short_links
+  SET target_url = $2,
+    updated_at = NOW()
+  WHERE slug = $1;
+
+-- name: DeleteShortLink :exec
+DELETE FROM short_links
+  WHERE slug = $1;
+
+-- name: RecordShortLinkScan :exec
+INSERT INTO short_link_scans (slug, canonical_user_agent, referer)
+  VALUES ($1, $2, $3);
+
+-- name: DeleteOldShortLinkScans :execrows
+DELETE FROM short_link_scans
+  WHERE scanned_at < NOW() - $1::interval;
+
+-- name: GetShortLinkScansByDay :many
+SELECT
+    to_char(date_trunc('day', short_link_scans.scanned_at), 'YYYY-MM-DD') as day,
+    short_links.url,
+    COUNT(*)::bigint as total_scans
+  FROM short_link_scans
+  JOIN short_links ON short_links.slug = short_link_scans.slug
+  WHERE short_link_scans.scanned_at >= NOW() - ($1 * INTERVAL '1 day')
+  GROUP BY day, short_links.url
+  ORDER BY day DESC, total_scans DESC;
@@ -0,0 +1,23 @@
+-- name: ListTemplates :many
+SELECT * FROM templates
+  ORDER BY name;
+
+-- name: FindTemplate :one
+SELECT * FROM templates
+  WHERE name = $1;
+
+-- name: FindTemplateUpdatedAt :one
+SELECT updated_at FROM templates
+  WHERE name = $1;
+
+-- name: UpsertTemplate :exec
+INSERT INTO templates (name, html, updated_at)
+  VALUES ($1, $2, NOW())
+  ON CONFLICT(name)
+  DO UPDATE SET
+    html = EXCLUDED.html,
+    updated_at = NOW();
+
+-- name: DeleteTemplate :exec
+DELETE FROM templates
+  WHERE name = $1;
@@ -1 +1,288 @@
-(()=>{function h(){function s(){b(),k()}document.readyState==="loading"?document.addEventListener("DOMContentLoaded",s):s()}async function b(){let s=document.getElementById("linkpreviews-domain-chart");if(s)try{let r=await fetch("/dashboard/link-previews/stats");if(!r.ok){console.error("Failed to fetch link preview statistics");return}let o=await r.json();if(o.length===0){console.log("No link preview data available");return}new Chart(s.getContext("2d"),{type:"doughnut",data:{labels:o.map(a=>a.Domain),datasets:[{data:o.map(a=>a.TotalAccesses),borderWidth:1}]},options:{responsive:!0,plugins:{legend:{position:"right",labels:{font:{size:14,family:"Inter"}}}}}})}catch(r){console.error("Error loading link preview chart:",r)}}function k(){let s=document.getElementById("linkpreviews-useragents-chart");if(!s)return;let r=document.getElementById("linkpreviews-useragents-range"),o=r?r.querySelectorAll("button[data-days]"):[],a=null,u=7;function g(n){o.forEach(t=>{let i=Number(t.dataset.days)===n;t.setAttribute("aria-pressed",i?"true":"false")})}function y(n){let t=new Set,i=new Set,c=new Map;n.forEach(e=>{t.add(e.Day),i.add(e.CanonicalUserAgent),c.set(e.CanonicalUserAgent,(c.get(e.CanonicalUserAgent)||0)+e.TotalAccesses)});let d=Array.from(t).sort((e,l)=>l.localeCompare(e)),m=Array.from(i).sort((e,l)=>(c.get(l)||0)-(c.get(e)||0)),f=new Map;n.forEach(e=>{let l=`${e.Day}::${e.CanonicalUserAgent}`;f.set(l,e.TotalAccesses)});let v=m.map(e=>({label:e,data:d.map(l=>f.get(`${l}::${e}`)||0)}));return{labels:d,datasets:v}}async function p(n){try{let t=await fetch(`/dashboard/link-previews/user-agents?days=${n}`);if(!t.ok){console.error("Failed to fetch link preview user agent statistics");return}let i=await t.json();if(i.length===0){console.log("No user agent data available");return}let{labels:c,datasets:d}=y(i);if(a){a.data.labels=c,a.data.datasets=d,a.update();return}a=new Chart(s.getContext("2d"),{type:"bar",data:{labels:c,datasets:d},options:{indexAxis:"y",responsive:!0,scales:{x:{stacked:!0,beginAtZero:!0},y:{stacked:!0}},plugins:{legend:{position:"right"}}}})}catch(t){console.error("Error loading user agent chart:",t)}}o.length>0&&o.forEach(n=>{n.addEventListener("click",()=>{let t=Number(n.dataset.days||7);Number.isNaN(t)||(u=t,g(t),p(t))})}),g(u),p(u)}h();})();
+(()=>{
+async function initDomainChart(canvasId, statsUrl) {
+const canvas = document.getElementById(canvasId);
+if (!canvas) {
+return;
+}
+try {
+const response = await fetch(statsUrl);
+if (!response.ok) {
+console.error('Failed to fetch statistics', statsUrl);
+return;
+}
+const stats = await response.json() || [];
+if (stats.length === 0) {
+console.log('No data available', statsUrl);
+return;
+}
+new Chart(canvas.getContext('2d'), {
+type: 'doughnut',
+data: {
+labels: stats.map(d => d.Domain),
+datasets: [{
+data: stats.map(d => d.TotalAccesses),
+borderWidth: 1
+}]
+},
+options: {
+responsive: true,
+plugins: {
+legend: {
+position: 'right',
+labels: {
+font: {
+size: 14,
+family: 'Inter'
+}
+}
+}
+}
+}
+});
+} catch (error) {
+console.error('Error loading domain chart:', error);
+}
+}
+function initUserAgentChart(canvasId, rangeId, statsUrl) {
+const canvas = document.getElementById(canvasId);
+if (!canvas) {
+return;
+}
+const rangeContainer = document.getElementById(rangeId);
+const rangeButtons = rangeContainer ? rangeContainer.querySelectorAll('button[data-days]') : [];
+let chart = null;
+let currentDays = 7;
+function setActiveButton(days) {
+rangeButtons.forEach(button => {
+const isActive = Number(button.dataset.days) === days;
+button.setAttribute('aria-pressed', isActive ? 'true' : 'false');
+});
+}
+function buildDatasets(stats) {
+const labelSet = new Set();
+const agentSet = new Set();
+const totalsByAgent = new Map();
+stats.forEach(row => {
+labelSet.add(row.Day);
+agentSet.add(row.CanonicalUserAgent);
+totalsByAgent.set(
+row.CanonicalUserAgent,
+(totalsByAgent.get(row.CanonicalUserAgent) || 0) + row.TotalAccesses
+);
+});
+const labels = Array.from(labelSet).sort((a, b) => b.localeCompare(a));
+const agents = Array.from(agentSet).sort((a, b) => (totalsByAgent.get(b) || 0) - (totalsByAgent.get(a) || 0));
+const matrix = new Map();
+stats.forEach(row => {
+const key = `${row.Day}::${row.CanonicalUserAgent}`;
+matrix.set(key, row.TotalAccesses);
+});
+const datasets = agents.map(agent => ({
+label: agent,
+data: labels.map(day => matrix.get(`${day}::${agent}`) || 0)
+}));
+return { labels, datasets };
+}
+async function loadChart(days) {
+try {
+const response = await fetch(`${statsUrl}?days=${days}`);
+if (!response.ok) {
+console.error('Failed to fetch user agent statistics', statsUrl);
+return;
+}
+const stats = await response.json() || [];
+if (stats.length === 0) {
+console.log('No user agent data available', statsUrl);
+return;
+}
+const { labels, datasets } = buildDatasets(stats);
+if (chart) {
+chart.data.labels = labels;
+chart.data.datasets = datasets;
+chart.update();
+return;
+}
+chart = new Chart(canvas.getContext('2d'), {
+type: 'bar',
+data: {
+labels,
+datasets
+},
+options: {
+indexAxis: 'y',
+responsive: true,
+scales: {
+x: {
+stacked: true,
+beginAtZero: true
+},
+y: {
+stacked: true
+}
+},
+plugins: {
+legend: {
+position: 'right'
+}
+}
+}
+});
+} catch (error) {
+console.error('Error loading user agent chart:', error);
+}
+}
+if (rangeButtons.length > 0) {
+rangeButtons.forEach(button => {
+button.addEventListener('click', () => {
+const days = Number(button.dataset.days || 7);
+if (!Number.isNaN(days)) {
+currentDays = days;
+setActiveButton(days);
+loadChart(days);
+}
+});
+});
+}
+setActiveButton(currentDays);
+loadChart(currentDays);
+}
+function initLinkPreviewsCharts() {
+function init() {
+initDomainChart('linkpreviews-domain-chart', '/dashboard/link-previews/stats');
+initUserAgentChart('linkpreviews-useragents-chart', 'linkpreviews-useragents-range', '/dashboard/link-previews/user-agents');
+}
+if (document.readyState === 'loading') {
+document.addEventListener('DOMContentLoaded', init);
+} else {
+init();
+}
+}
+function initQrCodesCharts() {
+function init() {
+initDomainChart('qrcodes-domain-chart', '/dashboard/qr-codes/stats');
+initUserAgentChart('qrcodes-useragents-chart', 'qrcodes-useragents-range', '/dashboard/qr-codes/user-agents');
+initScansChart();
+}
+if (document.readyState === 'loading') {
+document.addEventListener('DOMContentLoaded', init);
+} else {
+init();
+}
+}
+function initScansChart() {
+const canvas = document.getElementById('qrcodes-scans-chart');
+if (!canvas) {
+return;
+}
+const rangeContainer = document.getElementById('qrcodes-scans-range');
+const rangeButtons = rangeContainer ? rangeContainer.querySelectorAll('button[data-days]') : [];
+let chart = null;
+let currentDays = 28;
+function setActiveButton(days) {
+rangeButtons.forEach(button => {
+const isActive = Number(button.dataset.days) === days;
+button.setAttribute('aria-pressed', isActive ? 'true' : 'false');
+});
+}
+function buildDatasets(stats) {
+const labelSet = new Set();
+const urlSet = new Set();
+const totalsByUrl = new Map();
+stats.forEach(row => {
+labelSet.add(row.Day);
+urlSet.add(row.Url);
+totalsByUrl.set(row.Url, (totalsByUrl.get(row.Url) || 0) + row.TotalScans);
+});
+const labels = Array.from(labelSet).sort((a, b) => a.localeCompare(b));
+const urls = Array.from(urlSet).sort((a, b) => (totalsByUrl.get(b) || 0) - (totalsByUrl.get(a) || 0));
+const matrix = new Map();
+stats.forEach(row => {
+matrix.set(`${row.Day}::${row.Url}`, row.TotalScans);
+});
+const datasets = urls.map(url => ({
+label: url,
+data: labels.map(day => matrix.get(`${day}::${url}`) || 0)
+}));
+return { labels, datasets };
+}
+async function loadChart(days) {
+try {
+const response = await fetch(`/dashboard/qr-codes/scans?days=${days}`);
+if (!response.ok) {
+console.error('Failed to fetch QR Code scan statistics');
+return;
+}
+const stats = await response.json() || [];
+const { labels, datasets } = buildDatasets(stats);
+if (chart) {
+chart.data.labels = labels;
+chart.data.datasets = datasets;
+chart.update();
+return;
+}
+if (stats.length === 0) {
+console.log('No QR Code scan data available');
+return;
+}
+chart = new Chart(canvas.getContext('2d'), {
+type: 'bar',
+data: {
+labels,
+datasets
+},
+options: {
+responsive: true,
+scales: {
+x: {
+stacked: true
+},
+y: {
+stacked: true,
+beginAtZero: true,
+ticks: {
+precision: 0
+}
+}
+},
+plugins: {
+legend: {
+position: 'right'
+}
+}
+}
+});
+} catch (error) {
+console.error('Error loading QR Code scans chart:', error);
+}
+}
+if (rangeButtons.length > 0) {
+rangeButtons.forEach(button => {
+button.addEventListener('click', () => {
+const days = Number(button.dataset.days || 28);
+if (!Number.isNaN(days)) {
+currentDays = days;
+setActiveButton(days);
+loadChart(days);
+}
+});
+});
+}
+setActiveButton(currentDays);
+loadChart(currentDays);
+}
+function initBulkSelection() {
+document.addEventListener('change', event => {
+const toggle = event.target;
+if (!(toggle instanceof HTMLInputElement) || !toggle.dataset.selectAll) {
+return;
+}
+const container = toggle.closest('[data-selection]') || document;
+container.querySelectorAll(`input[type="checkbox"][name="${toggle.dataset.selectAll}"]`).forEach(checkbox => {
+checkbox.checked = toggle.checked;
+});
+});
+}
+initLinkPreviewsCharts();
+initQrCodesCharts();
+initBulkSelection();
+})();
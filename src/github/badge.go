@@ -0,0 +1,163 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// badgeOptions style a shields.io-style badge: a label on the left, and a value on the right.
+type badgeOptions struct {
+	Label      string
+	Color      string
+	LabelColor string
+	Style      string // “flat” (default) or “plastic”
+}
+
+// badgeColors are the named colors supported by shields.io.
+var badgeColors = map[string]string{
+	"brightgreen":   "#4c1",
+	"green":         "#97ca00",
+	"yellowgreen":   "#a4a61d",
+	"yellow":        "#dfb317",
+	"orange":        "#fe7d37",
+	"red":           "#e05d44",
+	"blue":          "#007ec6",
+	"grey":          "#555",
+	"gray":          "#555",
+	"lightgrey":     "#9f9f9f",
+	"lightgray":     "#9f9f9f",
+	"success":       "#4c1",
+	"important":     "#fe7d37",
+	"critical":      "#e05d44",
+	"informational": "#007ec6",
+	"inactive":      "#9f9f9f",
+}
+
+var hexColorRegex = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// parseBadgeOptions validates “label” (defaults to the name of the field; may be empty to show only the
+// value), “color” & “label_color” (named colors, or hex codes with or without “#”), and “style”.
+func parseBadgeOptions(query url.Values, name string) (badgeOptions, error) {
+	opts := badgeOptions{Label: name, Style: query.Get("style")}
+	if query.Has("label") {
+		opts.Label = query.Get("label")
+	}
+
+	var err error
+	if opts.Color, err = parseColor(query.Get("color"), "blue"); err != nil {
+		return opts, err
+	}
+	if opts.LabelColor, err = parseColor(query.Get("label_color"), "grey"); err != nil {
+		return opts, err
+	}
+
+	switch opts.Style {
+	case "":
+		opts.Style = "flat"
+	case "flat", "plastic":
+	default:
+		return opts, fmt.Errorf("invalid style: %s (must be flat or plastic)", opts.Style)
+	}
+	return opts, nil
+}
+
+// parseColor returns a color as a hex code, for use in SVG.
+func parseColor(color, fallback string) (string, error) {
+	if color == "" {
+		color = fallback
+	}
+	if hex, ok := badgeColors[strings.ToLower(color)]; ok {
+		return hex, nil
+	}
+	if hexColorRegex.MatchString(color) {
+		return "#" + strings.TrimPrefix(color, "#"), nil
+	}
+	return "", fmt.Errorf("invalid color: %s", color)
+}
+
+// renderBadge draws a badge for a value, with text measured as Verdana, the same as shields.io, so that
+// badges from both can be shown side by side.
+func renderBadge(opts badgeOptions, value string) []byte {
+	if value == "" {
+		value = "none"
+	}
+	height, radius, textY := 20, 3, 14
+	gradient := `<stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/>`
+	if opts.Style == "plastic" {
+		height, radius, textY = 18, 4, 13
+		gradient = `<stop offset="0" stop-color="#fff" stop-opacity=".7"/><stop offset=".1" stop-color="#aaa" stop-opacity=".1"/>` +
+			`<stop offset=".9" stop-opacity=".3"/><stop offset="1" stop-opacity=".5"/>`
+	}
+
+	var labelWidth int
+	if opts.Label != "" {
+		labelWidth = badgeSectionWidth(opts.Label)
+	}
+	valueWidth := badgeSectionWidth(value)
+	width := labelWidth + valueWidth
+
+	title := html.EscapeString(value)
+	if opts.Label != "" {
+		title = html.EscapeString(opts.Label) + ": " + title
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="%s">`, width, height, title)
+	fmt.Fprintf(&b, `<title>%s</title>`, title)
+	fmt.Fprintf(&b, `<linearGradient id="s" x2="0" y2="100%%">%s</linearGradient>`, gradient)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="%d" rx="%d" fill="#fff"/></clipPath>`, width, height, radius)
+	b.WriteString(`<g clip-path="url(#r)">`)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, labelWidth, height, opts.LabelColor)
+	fmt.Fprintf(&b, `<rect x="%d" width="%d" height="%d" fill="%s"/>`, labelWidth, valueWidth, height, opts.Color)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="url(#s)"/>`, width, height)
+	b.WriteString(`</g>`)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="11">`)
+	if opts.Label != "" {
+		writeBadgeText(&b, opts.Label, float64(labelWidth)/2, textY)
+	}
+	writeBadgeText(&b, value, float64(labelWidth)+float64(valueWidth)/2, textY)
+	b.WriteString(`</g></svg>`)
+	return b.Bytes()
+}
+
+// writeBadgeText writes text centered at x, along with its shadow. Its length is fixed, so that it fits
+// within its section, even if Verdana is not available.
+func writeBadgeText(b *bytes.Buffer, text string, x float64, y int) {
+	escaped := html.EscapeString(text)
+	length := badgeTextWidth(text)
+	fmt.Fprintf(b, `<text x="%.1f" y="%d" fill="#010101" fill-opacity=".3" textLength="%.1f">%s</text>`, x, y+1, length, escaped)
+	fmt.Fprintf(b, `<text x="%.1f" y="%d" textLength="%.1f">%s</text>`, x, y, length, escaped)
+}
+
+// badgeSectionWidth returns the width of a section of a badge, including padding on both sides.
+func badgeSectionWidth(text string) int {
+	return int(math.Round(badgeTextWidth(text))) + 10
+}
+
+// badgeTextWidth returns the width of text in 11px Verdana.
+func badgeTextWidth(text string) float64 {
+	var width int
+	for _, r := range text {
+		if r >= ' ' && r <= '~' {
+			width += verdanaWidths[r-' ']
+		} else {
+			width += 636 // Typical of accented letters.
+		}
+	}
+	return float64(width) * 11 / 1000
+}
+
+// verdanaWidths are the widths of printable ASCII characters in Verdana, in thousandths of an em.
+var verdanaWidths = [...]int{
+	352, 395, 458, 838, 636, 1082, 716, 266, 509, 509, 636, 838, 364, 427, 364, 509, // ␠ to /
+	636, 636, 636, 636, 636, 636, 636, 636, 636, 636, 437, 437, 838, 838, 838, 537, // 0 to ?
+	1000, 684, 686, 698, 770, 632, 575, 775, 752, 421, 450, 692, 564, 843, 748, 802, // @ to O
+	617, 802, 697, 685, 619, 736, 684, 991, 685, 617, 685, 509, 509, 509, 838, 636, // P to _
+	636, 602, 616, 525, 616, 585, 352, 616, 633, 275, 339, 579, 275, 976, 633, 596, // ` to o
+	616, 616, 427, 520, 394, 633, 579, 818, 579, 579, 523, 635, 509, 635, 838, // p to ~
+}
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseBadgeOptions(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    badgeOptions
+		wantErr bool
+	}{
+		{"", badgeOptions{Label: "stars", Color: "#007ec6", LabelColor: "#555", Style: "flat"}, false},
+		{"label=Stargazers&color=brightgreen&label_color=GREY", badgeOptions{Label: "Stargazers", Color: "#4c1", LabelColor: "#555", Style: "flat"}, false},
+		{"label=&color=ff0&style=plastic", badgeOptions{Label: "", Color: "#ff0", LabelColor: "#555", Style: "plastic"}, false},
+		{"color=%231a237e", badgeOptions{Label: "stars", Color: "#1a237e", LabelColor: "#555", Style: "flat"}, false},
+		{"color=purple", badgeOptions{}, true},
+		{"label_color=12345", badgeOptions{}, true},
+		{"style=for-the-badge", badgeOptions{}, true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := parseBadgeOptions(query, "stars")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBadgeOptions(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseBadgeOptions(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBadge(t *testing.T) {
+	opts := badgeOptions{Label: "stars", Color: "#4c1", LabelColor: "#555", Style: "flat"}
+	svg := string(renderBadge(opts, "1.2k"))
+	// Widths are measured the same way as shields.io: text width in Verdana, rounded, plus 10px of padding.
+	labelWidth, valueWidth := badgeSectionWidth("stars"), badgeSectionWidth("1.2k")
+	if labelWidth != 37 || valueWidth != 34 {
+		t.Errorf("section widths = %d & %d, want 37 & 34", labelWidth, valueWidth)
+	}
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="71" height="20" role="img" aria-label="stars: 1.2k">`,
+		`<title>stars: 1.2k</title>`,
+		`<rect width="71" height="20" rx="3" fill="#fff"/>`,
+		`<rect width="37" height="20" fill="#555"/>`,
+		`<rect x="37" width="34" height="20" fill="#4c1"/>`,
+		`<text x="18.5" y="14" textLength="27.1">stars</text>`,
+		`<text x="54.0" y="14" textLength="24.4">1.2k</text>`,
+		`<text x="54.0" y="15" fill="#010101" fill-opacity=".3" textLength="24.4">1.2k</text>`, // Its shadow.
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("renderBadge() does not contain %q:\n%s", want, svg)
+		}
+	}
+	if !strings.HasSuffix(svg, "</svg>") {
+		t.Error("renderBadge() is not terminated")
+	}
+
+	opts.Style = "plastic"
+	if svg := string(renderBadge(opts, "1.2k")); !strings.Contains(svg, `height="18"`) || !strings.Contains(svg, `rx="4"`) {
+		t.Error("plastic badges should be 18px high, with a 4px radius")
+	}
+}
+
+func TestRenderBadge_ValueOnly(t *testing.T) {
+	svg := string(renderBadge(badgeOptions{Color: "#007ec6", LabelColor: "#555", Style: "flat"}, ""))
+	if !strings.Contains(svg, `aria-label="none"`) || !strings.Contains(svg, `>none</text>`) {
+		t.Errorf("empty values should be shown as “none”:\n%s", svg)
+	}
+	if !strings.Contains(svg, `<rect width="0" height="20" fill="#555"/>`) {
+		t.Errorf("badges without a label should not have a label section:\n%s", svg)
+	}
+}
+
+func TestRenderBadge_Escapes(t *testing.T) {
+	svg := string(renderBadge(badgeOptions{Label: `<b>"x"</b>`, Color: "#007ec6", LabelColor: "#555", Style: "flat"}, "a & b"))
+	if strings.Contains(svg, "<b>") || strings.Contains(svg, ` & `) {
+		t.Errorf("renderBadge() does not escape text:\n%s", svg)
+	}
+	if !strings.Contains(svg, "&lt;b&gt;&#34;x&#34;&lt;/b&gt;: a &amp; b") {
+		t.Errorf("renderBadge() title is not escaped as expected:\n%s", svg)
+	}
+}
+
+func TestBadgeTextWidth(t *testing.T) {
+	if got := badgeTextWidth(""); got != 0 {
+		t.Errorf("badgeTextWidth(\"\") = %v, want 0", got)
+	}
+	if got := badgeTextWidth("0"); got != 6.996 {
+		t.Errorf("badgeTextWidth(\"0\") = %v, want 6.996", got)
+	}
+	if badgeTextWidth("é") != badgeTextWidth("0") {
+		t.Error("non-ASCII characters should be as wide as digits")
+	}
+	if len(verdanaWidths) != '~'-' '+1 {
+		t.Errorf("verdanaWidths has %d entries, want one for each printable ASCII character", len(verdanaWidths))
+	}
+}
@@ -1,13 +1,18 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"butterfly.chimbori.dev/conf"
@@ -17,16 +22,9 @@ import (
 
 var Cache *core.DiskCache
 
-var repoPathParamRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+var errFieldNotFound = errors.New("not found")
 
-var githubFieldMap = map[string]string{
-	"name":        "name",
-	"description": "description",
-	"stars":       "stargazers_count",
-	"forks":       "forks_count",
-	"issues":      "open_issues_count",
-	"watchers":    "subscribers_count",
-}
+var repoPathParamRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 
 // setCORSHeaders configures permissive CORS headers so this endpoint can be called from any origin.
 func setCORSHeaders(w http.ResponseWriter) {
@@ -75,9 +73,33 @@ func handleGithubV1(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	field, ok := githubFieldMap[reqType]
-	if !ok {
-		err := fmt.Errorf("unsupported type: %s", reqType)
+	// Multiple types, separated by commas, can be requested together as JSON.
+	query := req.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "text"
+	}
+	names := strings.Split(reqType, ",")
+	var err error
+	switch {
+	case format != "text" && format != "json" && format != "svg":
+		err = fmt.Errorf("unsupported format: %s (must be text, json, or svg)", format)
+	case len(names) > 1 && format != "json":
+		err = fmt.Errorf("multiple types are only supported as json")
+	}
+	fields := make(map[string]conf.GithubField, len(names))
+	for _, name := range names {
+		field, ok := conf.Config.Github.Fields[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("unsupported type: %s", name)
+		}
+		fields[name] = field
+	}
+	var badge badgeOptions
+	if err == nil && format == "svg" {
+		badge, err = parseBadgeOptions(query, reqType)
+	}
+	if err != nil {
 		slog.Error("Invalid request", tint.Err(err),
 			"method", req.Method,
 			"path", req.URL.Path,
@@ -85,86 +107,209 @@ func handleGithubV1(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	humanize, _ := strconv.ParseBool(query.Get("humanize"))
 
-	key := fmt.Sprintf("repos/%s/%s", user, repo)
-	var data []byte
-	var err error
-
-	if Cache != nil {
-		data, err = Cache.Find(key)
-		if err != nil {
-			slog.Error("Error checking GitHub cache", tint.Err(err),
+	values := make(map[string]any, len(names))
+	responses := map[string]any{}
+	for _, name := range names {
+		val, status, err := resolve(user, repo, name, fields[name], responses, time.Now())
+		missing := errors.Is(err, errFieldNotFound) || (status == http.StatusNotFound && fields[name].Source == "release")
+		if missing && format == "json" {
+			values[name] = nil // E.g. the latest release of a repository without any releases.
+			continue
+		} else if err != nil {
+			slog.Error("Error resolving GitHub field", tint.Err(err),
 				"method", req.Method,
 				"path", req.URL.Path,
-				"url", req.URL)
+				"url", req.URL,
+				"status", status)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if humanize {
+			switch val.(type) {
+			case json.Number, int:
+				val = humanizeNumber(text(val))
+			}
 		}
+		values[name] = val
 	}
 
-	if data == nil {
-		gitHubApiUrl := fmt.Sprintf("https://api.github.com/repos/%s/%s", user, repo)
-		resp, err := http.Get(gitHubApiUrl)
+	var body []byte
+	switch format {
+	case "json":
+		body, err = json.Marshal(values)
 		if err != nil {
-			slog.Error("Error fetching from GitHub", tint.Err(err),
+			slog.Error("Error marshaling JSON", tint.Err(err),
 				"method", req.Method,
 				"path", req.URL.Path,
 				"url", req.URL)
-			http.Error(w, "Error fetching from GitHub", http.StatusBadGateway)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer resp.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+	case "svg":
+		body = renderBadge(badge, text(values[reqType]))
+		w.Header().Set("Content-Type", "image/svg+xml")
+	default:
+		body = []byte(text(values[reqType]))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			err := fmt.Errorf("GitHub API error: %d", resp.StatusCode)
-			slog.Error("GitHub API error", tint.Err(err),
-				"method", req.Method,
-				"path", req.URL.Path,
-				"url", req.URL,
-				"status", resp.Status)
-			http.Error(w, err.Error(), http.StatusBadGateway)
-			return
+	// Responses from GitHub are cached for as long, so badges on static sites need not be fetched again; after
+	// that, unchanged values are revalidated using the ETag, and served as “304 Not Modified”.
+	w.Header().Set("Cache-Control", "public, max-age=3600") // 1 hour
+	w.Header().Set("ETag", `"`+core.SHA256(string(body))+`"`)
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(body))
+}
+
+// resolve returns the value of a field, after fetching & decoding the response it is found in, unless it has
+// already been decoded into responses. On failure, also returns the HTTP status to respond with.
+func resolve(user, repo, name string, field conf.GithubField, responses map[string]any, now time.Time) (any, int, error) {
+	key := fmt.Sprintf("repos/%s/%s", user, repo)
+	if field.Source == "release" {
+		key += "/releases/latest"
+	}
+	result, ok := responses[key]
+	if !ok {
+		data, status, err := fetch(key)
+		if err != nil {
+			return nil, status, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber() // So that large counts are not formatted in scientific notation.
+		if err := decoder.Decode(&result); err != nil {
+			return nil, http.StatusBadGateway, fmt.Errorf("invalid JSON from GitHub: %w", err)
 		}
+		responses[key] = result
+	}
+
+	val, ok := lookup(result, field.Path)
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("Field '%s' %w", name, errFieldNotFound)
+	}
+	val, err := derive(val, field.Format, now)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return val, http.StatusOK, nil
+}
 
-		data, err = io.ReadAll(resp.Body)
+// fetch returns a response from the GitHub API, e.g. for “repos/{user}/{repo}”, from the cache if possible.
+// On failure, also returns the HTTP status to respond with.
+func fetch(key string) ([]byte, int, error) {
+	if Cache != nil {
+		data, err := Cache.Find(key)
 		if err != nil {
-			slog.Error("Error reading GitHub response body", tint.Err(err),
-				"method", req.Method,
-				"path", req.URL.Path,
-				"url", req.URL)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			slog.Error("Error checking GitHub cache", tint.Err(err), "key", key)
+		} else if data != nil {
+			return data, http.StatusOK, nil
+		}
+	}
+
+	resp, err := http.Get("https://api.github.com/" + key)
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("Error fetching from GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound: // Also returned for repositories without any releases.
+		return nil, http.StatusNotFound, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
+	default:
+		return nil, http.StatusBadGateway, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if Cache != nil {
+		if err := Cache.Write(key, data); err != nil {
+			slog.Error("Error writing to GitHub cache", tint.Err(err), "key", key)
 		}
+	}
+	return data, http.StatusOK, nil
+}
 
-		if Cache != nil {
-			if err := Cache.Write(key, data); err != nil {
-				slog.Error("Error writing to GitHub cache", tint.Err(err),
-					"method", req.Method,
-					"path", req.URL.Path,
-					"url", req.URL)
+// lookup returns the value at a dot-separated path into decoded JSON, where each element is either the key
+// of an object, or the index of an array.
+func lookup(val any, path string) (any, bool) {
+	for key := range strings.SplitSeq(path, ".") {
+		switch v := val.(type) {
+		case map[string]any:
+			var ok bool
+			if val, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
 			}
+			val = v[i]
+		default:
+			return nil, false
 		}
 	}
+	return val, true
+}
 
-	var result map[string]any
-	if err := json.Unmarshal(data, &result); err != nil {
-		slog.Error("Error unmarshaling GitHub JSON", tint.Err(err),
-			"method", req.Method,
-			"path", req.URL.Path,
-			"url", req.URL)
-		http.Error(w, "invalid JSON from GitHub", http.StatusBadGateway)
-		return
+// derive returns a date, or the number of days since then, from a timestamp, as of now. Other values are
+// returned as-is.
+func derive(val any, format string, now time.Time) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	switch format {
+	case "date", "days_since":
+		s, _ := val.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("not a timestamp: %v", val)
+		}
+		if format == "date" {
+			return t.Format("2006-01-02"), nil
+		}
+		return int(now.Sub(t).Hours() / 24), nil
 	}
+	return val, nil
+}
 
-	val, ok := result[field]
-	if !ok {
-		err := fmt.Errorf("Field '%s' not found", reqType)
-		slog.Error(err.Error(), tint.Err(err),
-			"method", req.Method,
-			"path", req.URL.Path,
-			"url", req.URL)
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
+// text returns a value as plain text. Nulls are empty, and arrays are joined with “, ”.
+func text(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = text(item)
+		}
+		return strings.Join(items, ", ")
 	}
+	return fmt.Sprint(val)
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprintf(w, "%v", val)
+// humanizeNumber abbreviates large numbers, e.g. “1234” as “1.2k”, and “5678901” as “5.7M”. Anything else,
+// including smaller numbers, is returned as-is.
+func humanizeNumber(s string) string {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.Abs(n) < 1000 {
+		return s
+	}
+	units := []string{"k", "M", "B", "T"}
+	for i, unit := range units {
+		n /= 1000
+		if math.Abs(n) >= 999.5 && i < len(units)-1 {
+			continue
+		}
+		if math.Abs(n) < 9.95 {
+			return strings.TrimSuffix(strconv.FormatFloat(n, 'f', 1, 64), ".0") + unit
+		}
+		return strconv.FormatFloat(math.Round(n), 'f', 0, 64) + unit
+	}
+	return s
 }
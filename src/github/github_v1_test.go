@@ -0,0 +1,311 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+// decode parses JSON the same way as responses from GitHub.
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var val any
+	decoder := json.NewDecoder(bytes.NewReader([]byte(s)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&val); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return val
+}
+
+const testRepo = `{
+	"name": "butterfly",
+	"stargazers_count": 1234,
+	"description": null,
+	"license": {"spdx_id": "Apache-2.0", "name": "Apache License 2.0"},
+	"topics": ["go", "qr-codes", "link-previews"],
+	"owner": {"login": "chimbori", "teams": [{"name": "core", "members": [{"login": "a"}, {"login": "b"}]}]},
+	"pushed_at": "2026-10-15T18:30:00Z",
+	"archived": false
+}`
+
+func TestLookup(t *testing.T) {
+	repo := decode(t, testRepo)
+	tests := []struct {
+		path   string
+		want   any
+		wantOk bool
+	}{
+		{"name", "butterfly", true},
+		{"stargazers_count", json.Number("1234"), true},
+		{"description", nil, true}, // Present, but null.
+		{"archived", false, true},
+		{"license.spdx_id", "Apache-2.0", true},
+		{"topics", []any{"go", "qr-codes", "link-previews"}, true},
+		{"topics.0", "go", true},
+		{"topics.2", "link-previews", true},
+		{"owner.teams.0.members.1.login", "b", true},
+		{"missing", nil, false},
+		{"license.missing", nil, false},
+		{"topics.3", nil, false},
+		{"topics.-1", nil, false},
+		{"topics.first", nil, false},
+		{"name.length", nil, false}, // Strings have no fields.
+		{"archived.0", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookup(repo, tt.path)
+		if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookup(%q) = %#v, %v; want %#v, %v", tt.path, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		val     any
+		format  string
+		want    any
+		wantErr bool
+	}{
+		{"as-is", json.Number("42"), "", json.Number("42"), false},
+		{"null", nil, "date", nil, false},
+		{"date", "2026-10-15T18:30:00Z", "date", "2026-10-15", false},
+		{"date with offset", "2026-10-15T23:30:00-07:00", "date", "2026-10-15", false},
+		{"days since", "2026-10-15T18:30:00Z", "days_since", 2, false},
+		{"days since, under a day", "2026-10-18T00:00:00Z", "days_since", 0, false},
+		{"days since, exactly", "2025-10-18T12:00:00Z", "days_since", 365, false},
+		{"not a timestamp", "yesterday", "date", nil, true},
+		{"not a string", json.Number("1760553000"), "days_since", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := derive(tt.val, tt.format, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("derive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("derive() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		val  any
+		want string
+	}{
+		{nil, ""},
+		{"butterfly", "butterfly"},
+		{json.Number("1234"), "1234"},
+		{false, "false"},
+		{[]any{"go", "qr-codes"}, "go, qr-codes"},
+		{[]any{}, ""},
+		{[]any{"a", nil, json.Number("1")}, "a, , 1"},
+	}
+	for _, tt := range tests {
+		if got := text(tt.val); got != tt.want {
+			t.Errorf("text(%#v) = %q, want %q", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	// Responses that have already been decoded are not fetched again.
+	responses := map[string]any{
+		"repos/chimbori/butterfly":                 decode(t, testRepo),
+		"repos/chimbori/butterfly/releases/latest": decode(t, `{"tag_name": "v1.2.3", "published_at": "2026-09-01T00:00:00Z"}`),
+	}
+	tests := []struct {
+		name       string
+		field      conf.GithubField
+		want       any
+		wantStatus int
+	}{
+		{"stars", conf.GithubField{Path: "stargazers_count"}, json.Number("1234"), http.StatusOK},
+		{"license", conf.GithubField{Path: "license.spdx_id", Source: "repo"}, "Apache-2.0", http.StatusOK},
+		{"release", conf.GithubField{Path: "tag_name", Source: "release"}, "v1.2.3", http.StatusOK},
+		{"release_date", conf.GithubField{Path: "published_at", Source: "release", Format: "date"}, "2026-09-01", http.StatusOK},
+		{"days_since_push", conf.GithubField{Path: "pushed_at", Format: "days_since"}, 2, http.StatusOK},
+		{"missing", conf.GithubField{Path: "license.url"}, nil, http.StatusNotFound},
+		{"invalid date", conf.GithubField{Path: "name", Format: "date"}, nil, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status, err := resolve("chimbori", "butterfly", tt.name, tt.field, responses, now)
+			if status != tt.wantStatus {
+				t.Errorf("resolve() status = %d (%v), want %d", status, err, tt.wantStatus)
+			}
+			if (err != nil) != (tt.wantStatus != http.StatusOK) {
+				t.Errorf("resolve() error = %v", err)
+			}
+			if tt.wantStatus == http.StatusNotFound && !errors.Is(err, errFieldNotFound) {
+				t.Errorf("resolve() error = %v, want errFieldNotFound", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolve() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanizeNumber(t *testing.T) {
+	tests := map[string]string{
+		"0":                "0",
+		"999":              "999",
+		"-999":             "-999",
+		"1000":             "1k",
+		"1049":             "1k",
+		"1234":             "1.2k",
+		"-1234":            "-1.2k",
+		"9949":             "9.9k",
+		"9950":             "10k", // No decimals from 10 on.
+		"99999":            "100k",
+		"999499":           "999k",
+		"999500":           "1M", // Not “1000k”.
+		"999999":           "1M",
+		"5678901":          "5.7M",
+		"999500000":        "1B",
+		"1000000000000":    "1T",
+		"1000000000000000": "1000T", // There is no larger unit.
+		"1.5":              "1.5",
+		"":                 "",
+		"v1.2.3":           "v1.2.3",
+	}
+	for input, want := range tests {
+		if got := humanizeNumber(input); got != want {
+			t.Errorf("humanizeNumber(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// roundTripFunc fakes responses from GitHub.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func serveTestRequest(t *testing.T, target string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /github/v1/{user}/{repo}/{type}", handleGithubV1)
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func setupTestGithub(t *testing.T) {
+	t.Helper()
+	fields, transport := conf.Config.Github.Fields, http.DefaultTransport
+	t.Cleanup(func() { conf.Config.Github.Fields, http.DefaultTransport = fields, transport })
+	conf.Config.Github.Fields = conf.DefaultGithubFields
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, status := testRepo, http.StatusOK
+		if req.URL.Path != "/repos/chimbori/butterfly" {
+			body, status = `{"message": "Not Found"}`, http.StatusNotFound
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestHandleGithubV1_Formats(t *testing.T) {
+	setupTestGithub(t)
+	tests := []struct {
+		target      string
+		wantStatus  int
+		contentType string
+		want        string
+	}{
+		{"/github/v1/chimbori/butterfly/stars", http.StatusOK, "text/plain; charset=utf-8", "1234"},
+		{"/github/v1/chimbori/butterfly/stars?humanize=1", http.StatusOK, "text/plain; charset=utf-8", "1.2k"},
+		{"/github/v1/chimbori/butterfly/topics", http.StatusOK, "text/plain; charset=utf-8", "go, qr-codes, link-previews"},
+		{"/github/v1/chimbori/butterfly/stars,license,release?format=json", http.StatusOK, "application/json", `{"license":"Apache-2.0","release":null,"stars":1234}`},
+		{"/github/v1/chimbori/butterfly/stars,license", http.StatusBadRequest, "", ""},
+		{"/github/v1/chimbori/butterfly/stars?format=xml", http.StatusBadRequest, "", ""},
+		{"/github/v1/chimbori/butterfly/secrets", http.StatusBadRequest, "", ""},
+		{"/github/v1/chimbori/butterfly/release", http.StatusNotFound, "", ""},
+		{"/github/v1/chimbori/missing/stars?format=json", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serveTestRequest(t, tt.target, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleGithubV1_NotModified(t *testing.T) {
+	setupTestGithub(t)
+	target := "/github/v1/chimbori/butterfly/stars?format=svg&label=stars"
+
+	w := serveTestRequest(t, target, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) || len(etag) < 3 {
+		t.Fatalf("ETag = %q, want a quoted entity tag", etag)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", got)
+	}
+
+	w = serveTestRequest(t, target, map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status with If-None-Match = %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 response has a body: %q", w.Body)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag of 304 response = %q, want %q", got, etag)
+	}
+
+	w = serveTestRequest(t, target, map[string]string{"If-None-Match": `"stale"`})
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("status with a stale ETag = %d, want 200 with a body", w.Code)
+	}
+
+	// A different representation of the same value has a different ETag.
+	if other := serveTestRequest(t, "/github/v1/chimbori/butterfly/stars", nil).Header().Get("ETag"); other == etag {
+		t.Errorf("ETag of text = %q, same as of SVG", other)
+	}
+}
@@ -0,0 +1,93 @@
+package linkpreviews
+
+import (
+	"context"
+	"fmt"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/validation"
+)
+
+// Debug renders the Link Preview for a page the way [handleLinkPreview] would, using the domain’s
+// credentials, emulation defaults, image pipeline & size budget, but without falling back to the default
+// template, and without reading or writing the cache. Each step is reported in detail.
+func Debug(ctx context.Context, reqUrl, selector string) *core.RenderReport {
+	r := &core.RenderReport{}
+	queries := db.New(db.Pool)
+
+	var url, hostname string
+	if !r.Step("Validate URL", func() (err error) {
+		_, url, hostname, err = validation.ValidateUrl(ctx, queries, reqUrl)
+		return err
+	}) {
+		return r
+	}
+
+	if selector == "" {
+		selector = "#link-preview"
+	}
+	if !r.Step("Validate selector", func() error {
+		if !selectorRegex.MatchString(selector) {
+			return fmt.Errorf("invalid selector: %s", selector)
+		}
+		return nil
+	}) {
+		return r
+	}
+
+	var creds *core.Credentials
+	if !r.Step("Load credentials", func() (err error) {
+		creds, err = credentials.Find(ctx, queries, hostname)
+		return err
+	}) {
+		return r
+	}
+
+	var emulation core.Emulation
+	if !r.Step("Load emulation defaults", func() (err error) {
+		emulation, err = findEmulation(ctx, queries, hostname, core.Emulation{})
+		return err
+	}) {
+		return r
+	}
+
+	renderCtx, cancel := context.WithTimeout(ctx, conf.Config.LinkPreviews.Screenshot.Timeout)
+	defer cancel()
+	core.DebugScreenshot(renderCtx, r, url, selector, core.WithCredentials(creds), core.WithEmulation(emulation))
+	if r.Screenshot == nil {
+		return r
+	}
+
+	r.Step("Apply image pipeline", func() error {
+		pipeline, steps, err := findImagePipeline(ctx, queries, hostname)
+		if err == nil {
+			r.Screenshot, err = core.ProcessImage(r.Screenshot, steps)
+		}
+		if err != nil {
+			return fmt.Errorf("pipeline: %s, %w", pipeline, err)
+		}
+		return nil
+	})
+	r.Step("Fit size budget", func() error {
+		preset, budget, err := findSizeBudget(ctx, queries, hostname, "")
+		if err == nil && budget > 0 {
+			var fitted fittedImage
+			input := r.Screenshot
+			fitted, err = core.Do(ctx, core.Background, "size budget: "+url, func(ctx context.Context) (fittedImage, error) {
+				data, fits, err := core.FitToBudget(ctx, input, budget)
+				return fittedImage{data, fits}, err
+			})
+			if err == nil {
+				r.Screenshot = fitted.data
+			}
+			if err == nil && !fitted.fits {
+				err = fmt.Errorf("size budget not met: %s (%d bytes), got %d bytes", preset, budget, len(r.Screenshot))
+			}
+		}
+		return err
+	})
+	return r
+}
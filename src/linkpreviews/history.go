@@ -0,0 +1,145 @@
+package linkpreviews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+)
+
+// Versions stores the images of recent renders of each Link Preview, keyed by their IDs in the
+// link_preview_versions table. Unlike [Cache], it is never pruned by age or size; instead, only the last
+// conf.Config.LinkPreviews.History.Versions renders of each preview are kept.
+var Versions *core.DiskCache
+
+// ErrHistoryDisabled is returned when looking up versions while history is disabled.
+var ErrHistoryDisabled = errors.New("history of link previews is disabled")
+
+func initHistory() {
+	if Cache != nil && *conf.Config.LinkPreviews.History.Versions > 0 {
+		Versions = core.NewDiskCache(filepath.Join(conf.Config.DataDir, "link-preview-versions"))
+	} // else history will be nil
+}
+
+func versionKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// recordVersion stores a newly-rendered Link Preview as its latest version, and deletes older versions
+// beyond the configured limit. Pinned versions are never deleted.
+func recordVersion(ctx context.Context, url, cacheKey string, data []byte) error {
+	queries := db.New(db.Pool)
+	id, err := queries.CreateLinkPreviewVersion(ctx, db.CreateLinkPreviewVersionParams{
+		Url:       url,
+		CacheKey:  cacheKey,
+		SizeBytes: int32(len(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("error recording version: %w", err)
+	}
+	// The image is keyed by the ID of its row, so the row has to be inserted first; if the image cannot be
+	// written, delete the row again, so that the history never lists a version that cannot be shown.
+	if err := Versions.Write(versionKey(id), data); err != nil {
+		if err := queries.DeleteLinkPreviewVersion(ctx, id); err != nil {
+			slog.Error("failed to delete unwritten version", tint.Err(err), "url", url, "id", id)
+		}
+		return fmt.Errorf("error writing version: %w", err)
+	}
+
+	// The version has been recorded, so don’t fail (and retry) if older ones could not be deleted; they
+	// will be deleted along with the next version.
+	deleted, err := queries.DeleteOldLinkPreviewVersions(ctx, db.DeleteOldLinkPreviewVersionsParams{
+		CacheKey: cacheKey,
+		Limit:    int32(*conf.Config.LinkPreviews.History.Versions),
+	})
+	if err != nil {
+		slog.Warn("failed to delete old versions", tint.Err(err), "url", url)
+		return nil
+	}
+	for _, id := range deleted {
+		if err := Versions.Delete(versionKey(id)); err != nil {
+			slog.Warn("failed to delete old version", tint.Err(err), "url", url, "id", id)
+		}
+	}
+	return nil
+}
+
+// findPinnedVersion returns the image of the version of a Link Preview that was pinned by rolling back
+// to it, and restores it to the cache, or returns nil if no version is pinned.
+func findPinnedVersion(ctx context.Context, q *db.Queries, cacheKey string) ([]byte, error) {
+	version, err := q.FindPinnedLinkPreviewVersion(ctx, cacheKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := Versions.Find(versionKey(version.ID))
+	if err != nil || data == nil {
+		return nil, fmt.Errorf("pinned version %d unavailable: %w", version.ID, err)
+	}
+	core.Background.Submit("link preview (pinned): "+version.Url, func() error {
+		return WriteCached(context.Background(), version.Url, cacheKey, data)
+	})
+	return data, nil
+}
+
+// FindVersion returns a version of a Link Preview, along with its image.
+func FindVersion(ctx context.Context, q *db.Queries, id int64) (db.LinkPreviewVersion, []byte, error) {
+	if Versions == nil {
+		return db.LinkPreviewVersion{}, nil, ErrHistoryDisabled
+	}
+	version, err := q.GetLinkPreviewVersion(ctx, id)
+	if err != nil {
+		return version, nil, err
+	}
+	data, err := Versions.Find(versionKey(id))
+	if err == nil && data == nil {
+		err = fmt.Errorf("version %d not found on disk", id)
+	}
+	return version, data, err
+}
+
+// PinVersion rolls a Link Preview back to a previous version: it replaces the cached image, and keeps
+// serving that version until unpinned, even after the cached image expires.
+func PinVersion(ctx context.Context, q *db.Queries, id int64) (db.LinkPreviewVersion, error) {
+	version, data, err := FindVersion(ctx, q, id)
+	if err != nil {
+		return version, err
+	}
+	if err := q.PinLinkPreviewVersion(ctx, id); err != nil {
+		return version, err
+	}
+	return version, WriteCached(ctx, version.Url, version.CacheKey, data)
+}
+
+// UnpinVersions lets a variant of a Link Preview be rendered again once its cached image expires, or is
+// deleted.
+func UnpinVersions(ctx context.Context, q *db.Queries, cacheKey string) error {
+	return q.UnpinLinkPreviewVersions(ctx, cacheKey)
+}
+
+// DeleteVersions deletes every version of every variant of a Link Preview, including pinned ones, along
+// with their images.
+func DeleteVersions(ctx context.Context, q *db.Queries, url string) error {
+	deleted, err := q.DeleteLinkPreviewVersions(ctx, url)
+	if err != nil || Versions == nil {
+		return err
+	}
+	var errs []error
+	for _, id := range deleted {
+		if err := Versions.Delete(versionKey(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
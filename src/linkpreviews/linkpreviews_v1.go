@@ -4,16 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"time"
 
 	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
 	"butterfly.chimbori.dev/db"
 	"butterfly.chimbori.dev/embedfs"
 	"butterfly.chimbori.dev/validation"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/lmittmann/tint"
 )
 
@@ -21,6 +28,9 @@ var Cache *core.DiskCache
 
 var selectorRegex = regexp.MustCompile(`^[#.][a-zA-Z0-9_-]+$`)
 
+// TemplateNameRegex limits the names of saved templates, so that they can be used in URLs as-is.
+var TemplateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
 func Init(mux *http.ServeMux) {
 	if *conf.Config.LinkPreviews.Cache.Enabled {
 		Cache = core.NewDiskCache(
@@ -29,11 +39,12 @@ func Init(mux *http.ServeMux) {
 			core.WithMaxSize(conf.Config.LinkPreviews.Cache.MaxSizeBytes),
 		)
 	} // else cache will be nil
+	initHistory()
 
 	mux.HandleFunc("GET /link-previews/v1", handleLinkPreview)
 }
 
-// GET /link-previews/v1?url={url}&sel={selector}
+// GET /link-previews/v1?url={url}&sel={selector}&renderer={chrome|native}&budget={preset}&template={name}&lang={locale}&tz={timezone}&scheme={light|dark}
 // Validates the URL, checks if it’s cached, generates screenshots, and serves them.
 func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 	slog.Debug("handleLinkPreview", "url", req.Method+" "+req.URL.String())
@@ -43,7 +54,7 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 	canonicalUserAgent := core.GetCanonicalUserAgent(userAgent)
 	queries := db.New(db.Pool)
 
-	url, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
+	url, fetchUrl, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
 	if err != nil {
 		slog.Error("URL validation failed", tint.Err(err),
 			"method", req.Method,
@@ -72,12 +83,131 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Non-default variants are cached separately from the default one.
+	variant := neturl.Values{}
+	renderer := req.URL.Query().Get("renderer")
+	switch renderer {
+	case "", rendererChrome:
+		renderer = rendererChrome
+	case rendererNative:
+		variant.Set("renderer", rendererNative)
+	default:
+		err := fmt.Errorf("invalid renderer")
+		slog.Error("renderer validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusBadRequest)
+		http.Error(w, "invalid renderer", http.StatusBadRequest)
+		return
+	}
+
+	templateName := req.URL.Query().Get("template")
+	if templateName != "" {
+		var err error
+		if !TemplateNameRegex.MatchString(templateName) {
+			err = fmt.Errorf("invalid template")
+		} else if renderer == rendererNative {
+			err = fmt.Errorf("templates cannot be drawn by the native renderer")
+		}
+		if err != nil {
+			slog.Error("template validation failed", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", reqUrl,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		variant.Set("template", templateName)
+		if version := templateVersion(req.Context(), queries, templateName); version != "" {
+			variant.Set("template_version", version)
+		}
+	}
+
+	budgetPreset := req.URL.Query().Get("budget")
+	if budgetPreset != "" {
+		if _, ok := conf.Config.SizeBudgets.Presets[budgetPreset]; !ok && budgetPreset != NoSizeBudget {
+			err := fmt.Errorf("unknown size budget preset: %s", budgetPreset)
+			slog.Error("size budget validation failed", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", reqUrl,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	emulation, err := parseEmulation(req.URL.Query())
+	if err != nil {
+		slog.Error("emulation validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Defaults of the domain are resolved before looking up the cache, and are cached separately, so that
+	// changing them in the Dashboard takes effect immediately, instead of once cached previews expire.
+	emulation, err = findEmulation(req.Context(), queries, hostname, emulation)
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error loading emulation defaults", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pipeline, steps, err := findImagePipeline(req.Context(), queries, hostname)
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error loading image processing pipeline", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	preset, budget, err := findSizeBudget(req.Context(), queries, hostname, budgetPreset)
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error loading size budget", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	addEffectiveSettings(variant, emulation, pipeline, steps, preset, budget)
+	cacheKey := CacheKey(url, variant)
+
 	var cached []byte
 
 	// Only check cache if enabled
 	if *conf.Config.LinkPreviews.Cache.Enabled {
 		var err error
-		cached, err = Cache.Find(url)
+		cached, err = Cache.Find(cacheKey)
 		if err != nil {
 			err = fmt.Errorf("url: %s, %w", url, err)
 			slog.Error("error during cache lookup", tint.Err(err),
@@ -92,6 +222,20 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// A version pinned by rolling back to it is served instead of re-rendering the preview.
+	if cached == nil && Versions != nil {
+		cached, err = findPinnedVersion(req.Context(), queries, cacheKey)
+		if err != nil {
+			// Render the preview anyway, instead of failing the request.
+			slog.Error("error loading pinned version", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent)
+		}
+	}
+
 	if cached != nil {
 		slog.Info("cached screenshot served",
 			"method", req.Method,
@@ -100,54 +244,193 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 			"hostname", hostname,
 			"user-agent", userAgent,
 			"status", http.StatusOK)
-		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Type", http.DetectContentType(cached)) // JPEG if needed to fit a size budget.
 		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
 		w.Write(cached)
 		recordLinkPreviewAccessed(url, canonicalUserAgent)
 
 	} else {
+		creds, err := credentials.Find(req.Context(), queries, hostname)
+		if err != nil {
+			err = fmt.Errorf("url: %s, %w", url, err)
+			slog.Error("error loading credentials", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(req.Context(), conf.Config.LinkPreviews.Screenshot.Timeout)
 		defer cancel()
-		screenshot, err := core.TakeScreenshot(ctx, url, selector)
+
+		// Console errors & failed requests are logged with render errors, to help explain them.
+		diagnostics := &core.Diagnostics{}
+		pageOptions := []core.PageOption{core.WithCredentials(creds), core.WithEmulation(emulation), core.WithDiagnostics(diagnostics)}
+
+		// Only render previews that the page itself asks for, if the domain has opted in.
+		verified, err := checkReference(ctx, queries, req, url, fetchUrl, hostname, pageOptions...)
 		if err != nil {
-			if !errors.Is(err, core.ErrMissingSelector) {
-				err = fmt.Errorf("url: %s, %w", url, err)
-				slog.Error("error taking screenshot", tint.Err(err),
+			err = fmt.Errorf("url: %s, %w", url, err)
+			slog.Error("error checking references", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			err := fmt.Errorf("page does not reference this Link Preview in its og:image or twitter:image")
+			slog.Error("reference check failed", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusForbidden)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// A saved template is used instead of the page’s own element, filled in with the page’s metadata.
+		var savedTemplate string
+		if templateName != "" {
+			t, err := queries.FindTemplate(req.Context(), templateName)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, pgx.ErrNoRows) {
+					err, status = fmt.Errorf("template not found: %s", templateName), http.StatusNotFound
+				}
+				slog.Error("error loading template", tint.Err(err),
 					"method", req.Method,
 					"path", req.URL.Path,
 					"url", url,
 					"hostname", hostname,
 					"user-agent", userAgent,
-					"status", http.StatusInternalServerError)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+					"status", status)
+				http.Error(w, err.Error(), status)
 				return
 			}
+			savedTemplate = t.Html
+		}
 
-			slog.Info("attempting with default template",
+		var screenshot []byte
+		chromeUnavailable := false
+		if renderer == rendererNative {
+			screenshot, err = renderNativeCard(ctx, fetchUrl, hostname, pageOptions...)
+		} else {
+			if savedTemplate != "" {
+				screenshot, err = renderTemplate(ctx, savedTemplate, fetchUrl, pageOptions...)
+			} else {
+				screenshot, err = core.TakeScreenshot(ctx, fetchUrl, selector, pageOptions...)
+			}
+			if errors.Is(err, core.ErrMissingSelector) {
+				slog.Info("attempting with default template",
+					"method", req.Method,
+					"path", req.URL.Path,
+					"url", url,
+					"hostname", hostname,
+					"user-agent", userAgent,
+					"details", diagnostics.Summary(),
+					"status", http.StatusOK)
+				screenshot, err = renderTemplate(ctx, embedfs.DefaultTemplate, fetchUrl, pageOptions...)
+			}
+			if errors.Is(err, core.ErrChromeUnavailable) {
+				slog.Warn("Chrome unavailable; falling back to native renderer", tint.Err(err),
+					"method", req.Method,
+					"path", req.URL.Path,
+					"url", url,
+					"hostname", hostname,
+					"user-agent", userAgent)
+				chromeUnavailable = true
+				screenshot, err = renderNativeCard(ctx, fetchUrl, hostname, pageOptions...)
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("url: %s, %w", url, err)
+			slog.Error("error taking screenshot", tint.Err(err),
 				"method", req.Method,
 				"path", req.URL.Path,
 				"url", url,
 				"hostname", hostname,
 				"user-agent", userAgent,
-				"status", http.StatusOK)
-			title, description, fetchErr := core.FetchTitleAndDescription(ctx, url)
-			if fetchErr != nil {
-				err = fmt.Errorf("fetchTitleAndDescription failed: %w", fetchErr)
-			} else {
-				screenshot, err = core.TakeScreenshotWithTemplate(ctx, embedfs.DefaultTemplate, url, "#link-preview", title, description)
-			}
-			if err != nil {
-				err = fmt.Errorf("url: %s, %w", url, err)
-				slog.Error("error using default template", tint.Err(err),
+				"details", diagnostics.Summary(),
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Brand the screenshot before serving or caching it, so that every copy looks the same.
+		screenshot, err = core.ProcessImage(screenshot, steps)
+		if err != nil {
+			err = fmt.Errorf("url: %s, pipeline: %s, %w", url, pipeline, err)
+			slog.Error("error processing screenshot", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		originalSize := len(screenshot)
+
+		// Fitting a size budget has to happen before serving, since platforms reject oversized images. Trying
+		// each encoding in turn is expensive, so share the background workers, so that a burst of cache misses
+		// cannot starve the CPU.
+		if budget > 0 {
+			var fitted fittedImage
+			input := screenshot
+			fitted, err = core.Do(req.Context(), core.Background, "size budget: "+url, func(ctx context.Context) (fittedImage, error) {
+				data, fits, err := core.FitToBudget(ctx, input, budget)
+				return fittedImage{data, fits}, err
+			})
+			if errors.Is(err, core.ErrQueueFull) {
+				slog.Warn("size budget skipped; background queue full",
 					"method", req.Method,
 					"path", req.URL.Path,
 					"url", url,
 					"hostname", hostname,
 					"user-agent", userAgent,
-					"status", http.StatusInternalServerError)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+					"status", http.StatusServiceUnavailable)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
 				return
 			}
+			if err == nil {
+				screenshot = fitted.data
+			}
+			if err == nil && !fitted.fits {
+				slog.Warn("size budget not met; serving the smallest encoding",
+					"method", req.Method,
+					"path", req.URL.Path,
+					"url", url,
+					"hostname", hostname,
+					"user-agent", userAgent,
+					"preset", preset,
+					"budget", budget,
+					"from", originalSize,
+					"to", len(screenshot))
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("url: %s, size budget: %s, %w", url, preset, err)
+			slog.Error("error fitting screenshot to size budget", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		// Serve the screenshot immediately after generation, without waiting for compression.
@@ -158,24 +441,38 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 			"hostname", hostname,
 			"user-agent", userAgent,
 			"status", http.StatusOK)
-		w.Header().Set("Content-Type", "image/png")
-		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+		w.Header().Set("Content-Type", http.DetectContentType(screenshot))
+		if chromeUnavailable {
+			// Fallback previews are only a stopgap, so let clients retry soon, once Chrome is back.
+			w.Header().Set("Cache-Control", "max-age=300") // 5 minutes
+		} else {
+			w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+		}
 		w.Write(screenshot)
 		recordLinkPreviewCreated(url, canonicalUserAgent)
 
 		// If cache is enabled, compress the generated screenshot and cache it, but without holding up the HTTP request
-		go func() {
-			if *conf.Config.LinkPreviews.Cache.Enabled {
-				dataToWrite := screenshot
-				compressed, err := core.CompressPNG(screenshot)
-				if err == nil {
-					dataToWrite = compressed
-					slog.Info("PNG compressed", "from", len(screenshot), "to", len(compressed), "%", (len(compressed) * 100 / len(screenshot)))
-				} else {
-					slog.Error("PNG compression failed", tint.Err(err), "url", url)
+		var dataToWrite []byte
+		var versionRecorded bool
+		core.Background.Submit("link preview: "+url, func() error {
+			// Compress only once, even if writing to the cache has to be retried.
+			if dataToWrite == nil {
+				dataToWrite = screenshot
+				// Screenshots fitted to a budget have already been compressed as much as needed.
+				if *conf.Config.LinkPreviews.Cache.Enabled && budget == 0 {
+					compressed, err := core.CompressPNG(screenshot)
+					if err == nil {
+						dataToWrite = compressed
+						slog.Info("PNG compressed", "from", len(screenshot), "to", len(compressed), "%", (len(compressed) * 100 / len(screenshot)))
+					} else {
+						slog.Error("PNG compression failed", tint.Err(err), "url", url)
+					}
 				}
+				recordLinkPreviewSize(url, originalSize, len(dataToWrite), budget)
+			}
 
-				if err := Cache.Write(url, dataToWrite); err != nil {
+			if *conf.Config.LinkPreviews.Cache.Enabled && !chromeUnavailable {
+				if err := WriteCached(context.Background(), url, cacheKey, dataToWrite); err != nil {
 					err = fmt.Errorf("error writing to cache: %s, %w", url, err)
 					slog.Error("error writing to cache", tint.Err(err),
 						"method", req.Method,
@@ -184,12 +481,48 @@ func handleLinkPreview(w http.ResponseWriter, req *http.Request) {
 						"hostname", hostname,
 						"user-agent", userAgent,
 						"status", http.StatusInternalServerError)
+					return err
 				}
 			}
-		}()
+
+			if Versions != nil && !chromeUnavailable && !versionRecorded {
+				if err := recordVersion(context.Background(), url, cacheKey, dataToWrite); err != nil {
+					slog.Error("error recording version", tint.Err(err), "url", url)
+					return err
+				}
+				versionRecorded = true
+			}
+			return nil
+		})
 	}
 }
 
+const (
+	// rendererChrome screenshots the page itself, or the default template if the page has no selector.
+	rendererChrome = "chrome"
+	// rendererNative draws the default template in Go instead of Chrome; see [core.RenderCard].
+	rendererNative = "native"
+)
+
+// renderTemplate takes a screenshot of a template (such as the default one), filled in with the page’s
+// metadata.
+func renderTemplate(ctx context.Context, template, url string, opts ...core.PageOption) ([]byte, error) {
+	title, description, err := core.FetchTitleAndDescription(ctx, url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("fetchTitleAndDescription failed: %w", err)
+	}
+	return core.TakeScreenshotWithTemplate(ctx, template, url, "#link-preview", title, description, opts...)
+}
+
+// renderNativeCard draws the default template without Chrome, filled in with the page’s metadata.
+func renderNativeCard(ctx context.Context, url, hostname string, opts ...core.PageOption) ([]byte, error) {
+	title, description, err := core.FetchTitleAndDescription(ctx, url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("fetchTitleAndDescription failed: %w", err)
+	}
+	return core.RenderCard(core.Card{Title: title, Description: description, SiteName: hostname})
+}
+
 // Record when a link preview is created (for the first time)
 func recordLinkPreviewCreated(url string, canonicalUserAgent string) {
 	queries := db.New(db.Pool)
@@ -219,7 +552,286 @@ func recordLinkPreviewAccessed(url string, canonicalUserAgent string) {
 	// Don’t return an error to the caller; fulfill the request anyway.
 }
 
-// DeleteCached removes a cached screenshot file from disk.
-func DeleteCached(url string) error {
-	return Cache.Delete(url)
+// Record the size of a newly-generated link preview before & after compression, and the budget it had to
+// fit, if any.
+func recordLinkPreviewSize(url string, originalSize, size int, budget int64) {
+	queries := db.New(db.Pool)
+	params := db.RecordLinkPreviewSizeParams{
+		Url:           url,
+		SizeBytes:     core.Ptr(int32(size)),
+		OriginalBytes: core.Ptr(int32(originalSize)),
+	}
+	if budget > 0 {
+		params.BudgetBytes = core.Ptr(int32(budget))
+	}
+	if err := queries.RecordLinkPreviewSize(context.Background(), params); err != nil {
+		slog.Error("failed to log link preview size", tint.Err(err))
+	}
+	// Don’t return an error to the caller; fulfill the request anyway.
+}
+
+// checkReference returns true if the page at url references this exact Link Preview (i.e. the same
+// Butterfly host, path & parameters as req) in its og:image or twitter:image meta tags, or if the most
+// specific authorized domain that matches hostname has not enabled reference checks. Verdicts are
+// cached for conf.Config.LinkPreviews.ReferenceCheck.TTL, so that pages are not fetched for every request.
+func checkReference(ctx context.Context, q *db.Queries, req *http.Request, url, fetchUrl, hostname string, opts ...core.PageOption) (bool, error) {
+	enabled, err := q.FindDomainReferenceCheck(ctx, hostname)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (enabled == nil || !*enabled)) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	params := req.URL.Query()
+	otherParams := neturl.Values{}
+	for key, values := range params {
+		if key != "url" {
+			otherParams[key] = values
+		}
+	}
+	ttl := pgtype.Interval{
+		Microseconds: int64(conf.Config.LinkPreviews.ReferenceCheck.TTL / time.Microsecond),
+		Valid:        true,
+	}
+	verified, err := q.FindReferenceCheck(ctx, db.FindReferenceCheckParams{
+		Url:    url,
+		Params: otherParams.Encode(),
+		Ttl:    ttl,
+	})
+	if err == nil {
+		return verified, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	references, err := core.FetchImageReferences(ctx, fetchUrl, opts...)
+	if err != nil {
+		return false, fmt.Errorf("fetchImageReferences failed: %w", err)
+	}
+	verified = validation.MatchesReference(references, referenceHost(req), req.URL.Path, params)
+
+	err = q.RecordReferenceCheck(ctx, db.RecordReferenceCheckParams{
+		Url:      url,
+		Params:   otherParams.Encode(),
+		Verified: verified,
+	})
+	if err != nil {
+		slog.Error("failed to record reference check", tint.Err(err), "url", url)
+	}
+	// Don’t fail the request if the verdict could not be cached; it will be checked again next time.
+	return verified, nil
+}
+
+// templateVersion identifies the saved version of a template, so that Link Previews cached with a previous
+// version are not served after it has been changed (or deleted & re-created). Returns an empty string if
+// no such template has been saved.
+func templateVersion(ctx context.Context, q *db.Queries, name string) string {
+	updatedAt, err := q.FindTemplateUpdatedAt(ctx, name)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(updatedAt.UnixMilli(), 10)
+}
+
+// fittedImage is an image fitted to a size budget by [core.FitToBudget], along with whether it fits.
+type fittedImage struct {
+	data []byte
+	fits bool
+}
+
+// referenceHost returns the public host of this server, which pages must reference: from
+// conf.Config.LinkPreviews.ReferenceCheck.BaseUrl if configured, else the Host of req. Headers such as
+// X-Forwarded-Host are not trusted, since any caller can set them.
+func referenceHost(req *http.Request) string {
+	if baseUrl := conf.Config.LinkPreviews.ReferenceCheck.BaseUrl; baseUrl != "" {
+		if u, err := neturl.Parse(baseUrl); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	return req.Host
+}
+
+// NoImagePipeline can be selected for a domain to skip the default image processing pipeline.
+const NoImagePipeline = "none"
+
+// findImagePipeline returns the name & steps of the image processing pipeline for the most specific
+// authorized domain that matches hostname, falling back to the default pipeline from the config.
+func findImagePipeline(ctx context.Context, q *db.Queries, hostname string) (string, []conf.ImageStep, error) {
+	name := conf.Config.ImageProcessing.Default
+	selected, err := q.FindDomainImagePipeline(ctx, hostname)
+	if err == nil && selected != nil {
+		name = *selected
+	} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return name, nil, err
+	}
+	if name == "" || name == NoImagePipeline {
+		return name, nil, nil
+	}
+	steps, ok := conf.Config.ImageProcessing.Pipelines[name]
+	if !ok {
+		// Not fatal: the pipeline may have been removed from the config after being selected.
+		slog.Warn("image processing pipeline not found", "pipeline", name, "hostname", hostname)
+		return name, nil, nil
+	}
+	return name, steps, nil
+}
+
+// NoSizeBudget can be selected for a domain (or a request) to skip the default size budget.
+const NoSizeBudget = "none"
+
+// findSizeBudget returns the name & byte budget of the size budget preset requested, or else the one for
+// the most specific authorized domain that matches hostname, falling back to the default preset from the
+// config. A budget of 0 means that no budget applies.
+func findSizeBudget(ctx context.Context, q *db.Queries, hostname, requested string) (string, int64, error) {
+	name := requested
+	if name == "" {
+		name = conf.Config.SizeBudgets.Default
+		selected, err := q.FindDomainSizeBudget(ctx, hostname)
+		if err == nil && selected != nil {
+			name = *selected
+		} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return name, 0, err
+		}
+	}
+	if name == "" || name == NoSizeBudget {
+		return name, 0, nil
+	}
+	budget, ok := conf.Config.SizeBudgets.Presets[name]
+	if !ok {
+		// Not fatal: the preset may have been removed from the config after being selected.
+		slog.Warn("size budget preset not found", "preset", name, "hostname", hostname)
+		return name, 0, nil
+	}
+	return name, budget, nil
+}
+
+// parseEmulation validates the lang, tz & scheme parameters.
+func parseEmulation(query neturl.Values) (core.Emulation, error) {
+	var e core.Emulation
+	var err error
+	if lang := query.Get("lang"); lang != "" {
+		if e.Locale, err = validation.ValidateLocale(lang); err != nil {
+			return e, err
+		}
+	}
+	if tz := query.Get("tz"); tz != "" {
+		if e.Timezone, err = validation.ValidateTimezone(tz); err != nil {
+			return e, err
+		}
+	}
+	if scheme := query.Get("scheme"); scheme != "" {
+		if e.ColorScheme, err = validation.ValidateColorScheme(scheme); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// findEmulation fills in any settings not requested explicitly with the defaults of the most specific
+// authorized domain that matches hostname.
+func findEmulation(ctx context.Context, q *db.Queries, hostname string, requested core.Emulation) (core.Emulation, error) {
+	defaults, err := q.FindDomainEmulation(ctx, hostname)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return requested, nil
+	} else if err != nil {
+		return requested, err
+	}
+	if requested.Locale == "" && defaults.Locale != nil {
+		requested.Locale = *defaults.Locale
+	}
+	if requested.Timezone == "" && defaults.Timezone != nil {
+		requested.Timezone = *defaults.Timezone
+	}
+	if requested.ColorScheme == "" && defaults.ColorScheme != nil {
+		requested.ColorScheme = *defaults.ColorScheme
+	}
+	return requested, nil
+}
+
+// addEffectiveSettings adds the settings that a preview is rendered with, whether requested or defaults of
+// its domain, to variant, so that each combination is cached separately.
+func addEffectiveSettings(variant neturl.Values, e core.Emulation, pipeline string, steps []conf.ImageStep, preset string, budget int64) {
+	for name, value := range map[string]string{"lang": e.Locale, "tz": e.Timezone, "scheme": e.ColorScheme} {
+		if value != "" {
+			variant.Set(name, value)
+		}
+	}
+	if len(steps) > 0 {
+		variant.Set("pipeline", pipeline)
+	}
+	if budget > 0 {
+		variant.Set("budget", preset)
+	}
+}
+
+// DefaultCacheKey returns the key under which the preview of a URL is cached when it is requested without any
+// parameters, i.e. with the defaults of its domain.
+func DefaultCacheKey(ctx context.Context, q *db.Queries, url string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return url, err
+	}
+	emulation, err := findEmulation(ctx, q, u.Hostname(), core.Emulation{})
+	if err != nil {
+		return url, err
+	}
+	pipeline, steps, err := findImagePipeline(ctx, q, u.Hostname())
+	if err != nil {
+		return url, err
+	}
+	preset, budget, err := findSizeBudget(ctx, q, u.Hostname(), "")
+	if err != nil {
+		return url, err
+	}
+	variant := neturl.Values{}
+	addEffectiveSettings(variant, emulation, pipeline, steps, preset, budget)
+	return CacheKey(url, variant), nil
+}
+
+// CacheKey returns the key under which a rendered variant of a URL is stored in [Cache]. All variants
+// (screenshot modes, etc.) share one cache, so that size limits & pruning apply to all of them uniformly.
+// The default variant is keyed by the URL alone.
+func CacheKey(url string, variant neturl.Values) string {
+	if len(variant) == 0 {
+		return url
+	}
+	return url + " " + variant.Encode() // A URL can never contain an unescaped space.
+}
+
+// cacheName identifies [Cache] in the cache_keys table.
+const cacheName = "link-previews"
+
+// WriteCached stores a variant of a URL in [Cache], and records its key, so that it can be deleted along with
+// every other variant of the same URL.
+func WriteCached(ctx context.Context, url, cacheKey string, data []byte) error {
+	if err := Cache.Write(cacheKey, data); err != nil {
+		return err
+	}
+	return db.New(db.Pool).RecordCacheKey(ctx, db.RecordCacheKeyParams{Cache: cacheName, CacheKey: cacheKey, Url: url})
+}
+
+// DeleteCached removes every cached variant of a URL from disk, including screenshots.
+func DeleteCached(ctx context.Context, q *db.Queries, url string) error {
+	keys, err := q.DeleteCacheKeys(ctx, db.DeleteCacheKeysParams{Cache: cacheName, Url: url})
+	if err != nil || Cache == nil {
+		return err
+	}
+	var errs []error
+	for _, key := range append(keys, url) { // Also the default variant, in case it was cached before its key was recorded.
+		if err := Cache.Delete(key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteExpiredCacheKeys forgets the keys of variants that have expired from [Cache].
+func DeleteExpiredCacheKeys(ctx context.Context, q *db.Queries) (int64, error) {
+	if Cache == nil || Cache.TTL == 0 {
+		return 0, nil
+	}
+	return q.DeleteExpiredCacheKeys(ctx, db.DeleteExpiredCacheKeysParams{
+		Cache: cacheName,
+		Ttl:   pgtype.Interval{Microseconds: int64(Cache.TTL / time.Microsecond), Valid: true},
+	})
 }
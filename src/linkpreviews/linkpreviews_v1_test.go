@@ -0,0 +1,35 @@
+package linkpreviews
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+func TestReferenceHost(t *testing.T) {
+	baseUrl := conf.Config.LinkPreviews.ReferenceCheck.BaseUrl
+	t.Cleanup(func() { conf.Config.LinkPreviews.ReferenceCheck.BaseUrl = baseUrl })
+
+	tests := []struct {
+		name    string
+		baseUrl string
+		want    string
+	}{
+		{"request host", "", "butterfly.internal:9999"},
+		{"configured base url", "https://butterfly.example.com/", "butterfly.example.com"},
+		{"invalid base url", "butterfly.example.com", "butterfly.internal:9999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf.Config.LinkPreviews.ReferenceCheck.BaseUrl = tt.baseUrl
+			req := httptest.NewRequest("GET", "/link-previews/v1?url=example.com", nil)
+			req.Host = "butterfly.internal:9999"
+			// Spoofed by the caller; must be ignored.
+			req.Header.Set("X-Forwarded-Host", "evil.example.com")
+			if got := referenceHost(req); got != tt.want {
+				t.Errorf("referenceHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
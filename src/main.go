@@ -23,7 +23,9 @@ import (
 	"butterfly.chimbori.dev/embedfs"
 	"butterfly.chimbori.dev/github"
 	"butterfly.chimbori.dev/linkpreviews"
+	"butterfly.chimbori.dev/pdf"
 	"butterfly.chimbori.dev/qrcode"
+	"butterfly.chimbori.dev/screenshots"
 	"butterfly.chimbori.dev/slogdb"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lmittmann/tint"
@@ -94,12 +96,22 @@ func main() {
 	slog.SetDefault(slog.New(slogdb.NewDBHandler(tintHandler, db.Pool)))
 	slog.Info("Database error logging enabled")
 
+	core.Background = core.NewPipeline(conf.Config.Background.Workers, conf.Config.Background.QueueSize,
+		core.WithRetries(*conf.Config.Background.Retries, conf.Config.Background.RetryDelay))
+
 	// Set up a graceful cleanup for when the process is terminated.
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		<-signalCh
 		fmt.Println()
+		// Let pending cache writes finish, so that their work is not wasted.
+		slog.Info("Draining background tasks", "queued", core.Background.Stats().QueueDepth)
+		ctx, cancel := context.WithTimeout(context.Background(), conf.Config.Background.DrainTimeout)
+		defer cancel()
+		if err := core.Background.Shutdown(ctx); err != nil {
+			slog.Warn("Background tasks did not finish in time", tint.Err(err))
+		}
 		slog.Info("Shutdown successfully!")
 		os.Exit(0)
 	}()
@@ -113,6 +125,8 @@ func main() {
 		IndexTempl().Render(req.Context(), w)
 	})
 	linkpreviews.Init(mux)
+	screenshots.Init(mux)
+	pdf.Init(mux)
 	qrcode.Init(mux)
 	github.Init(mux)
 	dashboard.Init(mux)
@@ -11,6 +11,7 @@ import (
 	"butterfly.chimbori.dev/db"
 	"butterfly.chimbori.dev/github"
 	"butterfly.chimbori.dev/linkpreviews"
+	"butterfly.chimbori.dev/pdf"
 	"butterfly.chimbori.dev/qrcode"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/lmittmann/tint"
@@ -46,6 +47,54 @@ func performMaintenance() {
 		slog.Info(fmt.Sprintf("%d logs deleted", deletedLogs))
 	}
 
+	// Daily accesses of QR Codes are kept as long as logs, which cover the same requests.
+	deletedQrCodeAccesses, err := queries.DeleteOldQrCodeDailyAccesses(ctx, logRetentionInterval)
+	if err != nil {
+		slog.Error("failed to delete old QR Code daily accesses", tint.Err(err))
+	} else {
+		slog.Info(fmt.Sprintf("%d QR Code daily accesses deleted", deletedQrCodeAccesses))
+	}
+
+	// Scans of short links are kept as long as logs, which cover the same requests.
+	deletedShortLinkScans, err := queries.DeleteOldShortLinkScans(ctx, logRetentionInterval)
+	if err != nil {
+		slog.Error("failed to delete old short link scans", tint.Err(err))
+	} else {
+		slog.Info(fmt.Sprintf("%d short link scans deleted", deletedShortLinkScans))
+	}
+
+	referenceCheckInterval := pgtype.Interval{
+		Microseconds: int64(conf.Config.LinkPreviews.ReferenceCheck.TTL / time.Microsecond),
+		Valid:        true,
+	}
+	deletedReferenceChecks, err := queries.DeleteExpiredReferenceChecks(ctx, referenceCheckInterval)
+	if err != nil {
+		slog.Error("failed to delete expired reference checks", tint.Err(err))
+	} else {
+		slog.Info(fmt.Sprintf("%d reference checks deleted", deletedReferenceChecks))
+	}
+
+	deletedCacheKeys, err := linkpreviews.DeleteExpiredCacheKeys(ctx, queries)
+	if err != nil {
+		slog.Error("failed to delete expired link preview cache keys", tint.Err(err))
+	} else {
+		slog.Info(fmt.Sprintf("%d link preview cache keys deleted", deletedCacheKeys))
+	}
+
+	deletedQrCodeCacheKeys, err := qrcode.DeleteExpiredCacheKeys(ctx, queries)
+	if err != nil {
+		slog.Error("failed to delete expired QR Code cache keys", tint.Err(err))
+	} else {
+		slog.Info(fmt.Sprintf("%d QR Code cache keys deleted", deletedQrCodeCacheKeys))
+	}
+
+	encryptedPayloads, err := qrcode.EncryptStoredPayloads(ctx, queries)
+	if err != nil {
+		slog.Error("failed to encrypt stored QR Code payloads", tint.Err(err))
+	} else if encryptedPayloads > 0 {
+		slog.Info(fmt.Sprintf("%d QR Code payloads encrypted", encryptedPayloads))
+	}
+
 	// Prune caches
 	if linkpreviews.Cache != nil {
 		if err := linkpreviews.Cache.Prune(); err != nil {
@@ -62,6 +111,11 @@ func performMaintenance() {
 			slog.Error("failed to prune qrcode cache", tint.Err(err))
 		}
 	}
+	if pdf.Cache != nil {
+		if err := pdf.Cache.Prune(); err != nil {
+			slog.Error("failed to prune pdf cache", tint.Err(err))
+		}
+	}
 	if github.Cache != nil {
 		if err := github.Cache.Prune(); err != nil {
 			slog.Error("failed to prune github cache", tint.Err(err))
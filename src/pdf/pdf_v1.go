@@ -0,0 +1,318 @@
+package pdf
+
+// PDFs of authorized pages (invoices, printable docs, etc.), rendered with print media styles by the
+// same headless Chrome used for Link Previews, and therefore subject to the same queue & timeouts.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	neturl "net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/validation"
+	"github.com/lmittmann/tint"
+)
+
+var Cache *core.DiskCache
+
+const (
+	defaultPaper  = "a4"
+	defaultMargin = 0.4 // inches; same as Chrome’s default.
+	maxMargin     = 2   // inches
+	maxLength     = 200 // inches; larger lengths are never meaningful, and would overflow when rounded.
+)
+
+func Init(mux *http.ServeMux) {
+	if *conf.Config.Pdfs.Cache.Enabled {
+		Cache = core.NewDiskCache(
+			filepath.Join(conf.Config.DataDir, "cache", "pdfs"),
+			core.WithTTL(conf.Config.Pdfs.Cache.TTL),
+			core.WithMaxSize(conf.Config.Pdfs.Cache.MaxSizeBytes),
+		)
+	} // else cache will be nil
+
+	mux.HandleFunc("GET /pdf/v1", handlePdf)
+}
+
+// GET /pdf/v1?url={url}&paper={a4|letter|…}&margin={0.4in}&landscape={true|false}&print-background={true|false}
+// Validates the URL, checks if it’s cached, renders the page as a PDF, and serves it.
+func handlePdf(w http.ResponseWriter, req *http.Request) {
+	slog.Debug("handlePdf", "url", req.Method+" "+req.URL.String())
+
+	reqUrl := req.URL.Query().Get("url")
+	userAgent := req.Header.Get("User-Agent")
+	queries := db.New(db.Pool)
+
+	url, fetchUrl, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
+	if err != nil {
+		slog.Error("URL validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pdfOptions, options, err := parseOptions(req.URL.Query())
+	if err != nil {
+		slog.Error("PDF options validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := cacheKey(url, options)
+
+	var cached []byte
+
+	// Only check cache if enabled
+	if *conf.Config.Pdfs.Cache.Enabled {
+		cached, err = Cache.Find(key)
+		if err != nil {
+			err = fmt.Errorf("url: %s, %w", url, err)
+			slog.Error("error during cache lookup", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if cached != nil {
+		slog.Info("cached PDF served",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusOK)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+		w.Write(cached)
+		recordPdfAccessed(url, options)
+		return
+	}
+
+	creds, err := credentials.Find(req.Context(), queries, hostname)
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error loading credentials", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), conf.Config.Pdfs.Render.Timeout)
+	defer cancel()
+	pdf, err := core.RenderPdf(ctx, fetchUrl, pdfOptions, core.WithCredentials(creds))
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error rendering PDF", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("new PDF generated",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"url", url,
+		"hostname", hostname,
+		"user-agent", userAgent,
+		"status", http.StatusOK)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+	w.Write(pdf)
+	recordPdfCreated(url, options)
+
+	// If cache is enabled, cache the generated PDF, but without holding up the HTTP request
+	if *conf.Config.Pdfs.Cache.Enabled {
+		core.Background.Submit("PDF: "+url, func() error {
+			if err := Cache.Write(key, pdf); err != nil {
+				err = fmt.Errorf("error writing to cache: %s, %w", url, err)
+				slog.Error("error writing to cache", tint.Err(err),
+					"method", req.Method,
+					"path", req.URL.Path,
+					"url", url,
+					"hostname", hostname,
+					"user-agent", userAgent,
+					"status", http.StatusInternalServerError)
+				return err
+			}
+			return nil
+		})
+	}
+}
+
+// parseOptions validates PDF layout parameters, and returns the [core.PdfOptions] to render with, along
+// with a normalized query string that identifies this variant (empty if all defaults are used).
+// Margins accept “in”, “cm”, or “mm” units, and default to inches.
+func parseOptions(query neturl.Values) (core.PdfOptions, string, error) {
+	var pdfOptions core.PdfOptions
+	normalized := neturl.Values{}
+
+	paper := strings.ToLower(query.Get("paper"))
+	if paper == "" {
+		paper = defaultPaper
+	}
+	size, ok := core.PaperSizes[paper]
+	if !ok {
+		return pdfOptions, "", fmt.Errorf("unsupported paper size: %s", paper)
+	}
+	if paper != defaultPaper {
+		normalized.Set("paper", paper)
+	}
+	pdfOptions.PaperWidth, pdfOptions.PaperHeight = size[0], size[1]
+
+	for _, flag := range []struct {
+		name  string
+		value *bool
+	}{
+		{"landscape", &pdfOptions.Landscape},
+		{"print-background", &pdfOptions.PrintBackground},
+	} {
+		if v := query.Get(flag.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return pdfOptions, "", fmt.Errorf("invalid %s: %s", flag.name, v)
+			}
+			*flag.value = b
+			if b {
+				normalized.Set(flag.name, "true")
+			}
+		}
+	}
+
+	margin := defaultMargin
+	if v := query.Get("margin"); v != "" {
+		var err error
+		if margin, err = parseLength(v); err != nil {
+			return pdfOptions, "", fmt.Errorf("invalid margin: %w", err)
+		}
+	}
+	for _, side := range []struct {
+		name  string
+		value *float64
+	}{
+		{"margin-top", &pdfOptions.MarginTop},
+		{"margin-right", &pdfOptions.MarginRight},
+		{"margin-bottom", &pdfOptions.MarginBottom},
+		{"margin-left", &pdfOptions.MarginLeft},
+	} {
+		*side.value = margin
+		if v := query.Get(side.name); v != "" {
+			length, err := parseLength(v)
+			if err != nil {
+				return pdfOptions, "", fmt.Errorf("invalid %s: %w", side.name, err)
+			}
+			*side.value = length
+		}
+		if *side.value > maxMargin {
+			return pdfOptions, "", fmt.Errorf("%s must not exceed %din", side.name, maxMargin)
+		}
+		if *side.value != defaultMargin {
+			normalized.Set(side.name, strconv.FormatFloat(*side.value, 'f', -1, 64)+"in")
+		}
+	}
+
+	return pdfOptions, normalized.Encode(), nil
+}
+
+// parseLength converts a length such as “0.5in”, “1cm”, “10mm”, or “0.5” (inches) to inches.
+func parseLength(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{
+		{"in", 1},
+		{"cm", 1 / 2.54},
+		{"mm", 1 / 25.4},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, factor = strings.TrimSuffix(s, unit.suffix), unit.factor
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("expected a non-negative length in in, cm, or mm")
+	}
+	if v*factor > maxLength {
+		return 0, fmt.Errorf("length must not exceed %din", maxLength)
+	}
+	// Round to 1/1000th of an inch, so that equivalent lengths map to the same cache entry.
+	return float64(int(v*factor*1000+0.5)) / 1000, nil
+}
+
+// cacheKey returns the key under which a PDF variant is stored in [Cache].
+// The default variant is keyed by the URL alone.
+func cacheKey(url, options string) string {
+	if options == "" {
+		return url
+	}
+	return url + " " + options // A URL can never contain an unescaped space.
+}
+
+// recordPdfCreated records when a PDF is created (for the first time)
+func recordPdfCreated(url, options string) {
+	queries := db.New(db.Pool)
+	err := queries.RecordPdfCreated(context.Background(), db.RecordPdfCreatedParams{
+		Url:     url,
+		Options: options,
+	})
+	if err != nil {
+		slog.Error("failed to log PDF created", tint.Err(err))
+	}
+}
+
+// recordPdfAccessed records when a PDF is accessed from the cache
+func recordPdfAccessed(url, options string) {
+	queries := db.New(db.Pool)
+	rowsUpdated, err := queries.RecordPdfAccessed(context.Background(), db.RecordPdfAccessedParams{
+		Url:     url,
+		Options: options,
+	})
+	if err != nil {
+		slog.Error("failed to log PDF accessed", tint.Err(err))
+	}
+	if rowsUpdated == 0 { // If not already in the database, add it now.
+		recordPdfCreated(url, options)
+	}
+}
+
+// DeleteCached removes a cached PDF file from disk.
+func DeleteCached(url, options string) error {
+	return Cache.Delete(cacheKey(url, options))
+}
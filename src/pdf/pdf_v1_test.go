@@ -0,0 +1,40 @@
+package pdf
+
+import "testing"
+
+func TestParseLength(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{"0.5", 0.5, false},
+		{"0.5in", 0.5, false},
+		{" 2.54CM ", 1, false},
+		{"10mm", 0.394, false},
+		{"0", 0, false},
+		{"200in", 200, false},
+		{"508cm", 200, false},
+		{"", 0, true},
+		{"-1", 0, true},
+		{"1pt", 0, true},
+		{"201in", 0, true},
+		{"1e300", 0, true},
+		{"inf", 0, true},
+		{"+Inf", 0, true},
+		{"Infinity", 0, true},
+		{"-inf", 0, true},
+		{"NaN", 0, true},
+		{"nanmm", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLength(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLength(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLength(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
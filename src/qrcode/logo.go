@@ -0,0 +1,160 @@
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"github.com/disintegration/imaging"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// Sources of logos overlaid on QR Codes, selected using “&logo={source}”.
+const (
+	// LogoDomain is the logo uploaded for the domain in the Dashboard, or its favicon if none was uploaded.
+	LogoDomain = "domain"
+	// LogoFavicon is the favicon of the page itself.
+	LogoFavicon = "favicon"
+)
+
+const (
+	// logoSizeDivisor limits logos to a quarter of the width of a QR Code, i.e. about 6% of its area, well
+	// within the 30% that can be restored using the highest level of error correction.
+	logoSizeDivisor = 4
+
+	// maxLogoSize is the size to which uploaded logos are scaled down before being stored.
+	maxLogoSize = 512
+
+	faviconTimeout = 10 * time.Second
+)
+
+// PrepareLogo validates an uploaded logo, scales it down if needed, and returns it as a PNG to be stored.
+func PrepareLogo(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() > maxLogoSize || b.Dy() > maxLogoSize {
+		img = imaging.Fit(img, maxLogoSize, maxLogoSize, imaging.Lanczos)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// logoVersion identifies the logo uploaded for a domain, so that QR Codes cached with a previous logo are
+// not served after a new one has been uploaded. Returns an empty string if no logo was uploaded.
+func logoVersion(ctx context.Context, q *db.Queries, hostname string) string {
+	updatedAt, err := q.FindDomainLogoUpdatedAt(ctx, hostname)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(updatedAt.Unix(), 10)
+}
+
+// findLogo returns the logo from the given source for a URL.
+func findLogo(ctx context.Context, q *db.Queries, source, url, hostname string) (image.Image, error) {
+	if source == LogoDomain {
+		data, err := q.FindDomainLogo(ctx, hostname)
+		if err == nil {
+			img, _, err := image.Decode(bytes.NewReader(data))
+			return img, err
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		} // else fall back to the favicon.
+	}
+
+	creds, err := credentials.Find(ctx, q, hostname)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, faviconTimeout)
+	defer cancel()
+	return core.FetchFavicon(ctx, url, core.WithCredentials(creds))
+}
+
+// generateQrCodeWithLogo creates a QR Code encoding text (the URL itself, or a short link to it), overlaid
+// with the requested logo for the URL, if any. If the logo is unavailable, or obscures too much of the QR
+// Code, the QR Code is generated without it instead, and withLogo is false, so that the fallback is not
+// cached in place of the QR Code with the logo.
+func generateQrCodeWithLogo(ctx context.Context, q *db.Queries, text, url, hostname string, style Style) (data []byte, withLogo bool, err error) {
+	logo := verifiedLogo(ctx, q, text, url, hostname, style)
+	data, err = generateQrCode(text, style, logo)
+	return data, style.Logo == "" || logo != nil, err
+}
+
+// verifiedLogo returns the requested logo for a URL, or nil if none was requested, if it is unavailable, or
+// if the QR Code encoding text cannot be scanned with it.
+func verifiedLogo(ctx context.Context, q *db.Queries, text, url, hostname string, style Style) image.Image {
+	if style.Logo == "" {
+		return nil
+	}
+
+	logo, err := findLogo(ctx, q, style.Logo, url, hostname)
+	return scannableLogo(text, url, hostname, style, logo, err)
+}
+
+// scannableLogo returns a logo found for a URL, unless it could not be found, or the QR Code encoding text
+// cannot be scanned with it.
+func scannableLogo(text, url, hostname string, style Style, logo image.Image, err error) image.Image {
+	if err != nil {
+		slog.Warn("logo unavailable; generating QR Code without it", tint.Err(err),
+			"url", url,
+			"hostname", hostname,
+			"logo", style.Logo)
+		return nil
+	}
+
+	// Vector formats cannot be decoded directly, so verify the equivalent PNG instead; both are laid out
+	// identically. Likewise, circular finder patterns are not located by the decoder, so verify square
+	// modules, which occupy the same positions.
+	raster := style
+	raster.Format = "png"
+	raster.Shape = "square"
+	png, err := generateQrCode(text, raster, logo)
+	if err == nil {
+		err = verifyQrCode(png, text)
+	}
+	if err != nil {
+		slog.Warn("QR Code with logo cannot be scanned; generating it without the logo", tint.Err(err),
+			"url", url,
+			"hostname", hostname,
+			"logo", style.Logo)
+		return nil
+	}
+	return logo
+}
+
+// verifyQrCode decodes a generated QR Code, and checks that it contains the expected text.
+func verifyQrCode(data []byte, text string) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return err
+	}
+	result, err := zxingqrcode.NewQRCodeReader().Decode(bmp, nil)
+	if err != nil {
+		return err
+	}
+	if decoded := result.GetText(); decoded != text {
+		return fmt.Errorf("QR Code decoded to %q instead of %q", decoded, text)
+	}
+	return nil
+}
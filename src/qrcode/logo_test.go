@@ -0,0 +1,127 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+// solidImage returns a square image filled with a single color, to stand in for a logo.
+func solidImage(size int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+	return img
+}
+
+// obscure covers the central part of a QR Code, from lo to hi (as fractions of its width & height).
+func obscure(t *testing.T, data []byte, lo, hi float64) []byte {
+	t.Helper()
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	b := src.Bounds()
+	img := image.NewRGBA(b)
+	draw.Draw(img, b, src, b.Min, draw.Src)
+	rect := image.Rect(int(float64(b.Dx())*lo), int(float64(b.Dy())*lo), int(float64(b.Dx())*hi), int(float64(b.Dy())*hi))
+	draw.Draw(img, rect, &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyQrCode(t *testing.T) {
+	const text = "https://example.com/some/page"
+	logo := solidImage(maxLogoSize, color.RGBA{R: 0xe9, G: 0x1e, B: 0x63, A: 0xff})
+
+	withStyle := func(f func(*Style)) Style {
+		s := defaultStyle
+		f(&s)
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		style   Style
+		logo    image.Image
+		damage  [2]float64
+		verify  string
+		wantErr bool
+	}{
+		{name: "default", style: defaultStyle},
+		{name: "styled", style: withStyle(func(s *Style) {
+			s.ModuleWidth = 8
+			s.Foreground = color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}
+			s.Background = color.RGBA{R: 0xff, G: 0xf8, B: 0xe1, A: 0xff}
+			s.QuietZone = 0
+		})},
+		{name: "transparent", style: withStyle(func(s *Style) { s.Transparent = true })},
+		{name: "logo", style: withStyle(func(s *Style) { s.ErrorCorrection = "H" }), logo: logo},
+		{name: "wrong text", style: defaultStyle, verify: "https://example.com/other/page", wantErr: true},
+		{
+			name:    "too damaged",
+			style:   withStyle(func(s *Style) { s.ErrorCorrection = "L" }),
+			damage:  [2]float64{0.25, 0.75},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := generateQrCode(text, tt.style, tt.logo)
+			if err != nil {
+				t.Fatalf("generateQrCode() error = %v", err)
+			}
+			if tt.damage != [2]float64{} {
+				data = obscure(t, data, tt.damage[0], tt.damage[1])
+			}
+			verify := text
+			if tt.verify != "" {
+				verify = tt.verify
+			}
+			if err := verifyQrCode(data, verify); (err != nil) != tt.wantErr {
+				t.Errorf("verifyQrCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyQrCode_Blank(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solidImage(400, color.White)); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	if err := verifyQrCode(buf.Bytes(), "https://example.com/"); err == nil {
+		t.Error("verifyQrCode() succeeded on a blank image")
+	}
+}
+
+func TestScannableLogo(t *testing.T) {
+	logo := solidImage(maxLogoSize, color.RGBA{R: 0xe9, G: 0x1e, B: 0x63, A: 0xff})
+	tests := []struct {
+		format string
+		shape  string
+	}{
+		{"png", "square"},
+		{"png", "circle"},
+		{"svg", "square"},
+		{"pdf", "circle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.shape, func(t *testing.T) {
+			style := defaultStyle
+			style.ErrorCorrection = "H"
+			style.Logo = LogoDomain
+			style.Format = tt.format
+			style.Shape = tt.shape
+			if got := scannableLogo("https://example.com/", "https://example.com/", "example.com", style, logo, nil); got != logo {
+				t.Errorf("scannableLogo() = %v, want the logo", got)
+			}
+		})
+	}
+}
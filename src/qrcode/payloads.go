@@ -0,0 +1,249 @@
+package qrcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+)
+
+// Kinds of structured payloads, other than authorized URLs, that can be encoded from the Dashboard.
+const (
+	PayloadWifi  = "wifi"
+	PayloadVCard = "vcard"
+	PayloadEvent = "event"
+	PayloadGeo   = "geo"
+	PayloadText  = "text"
+)
+
+// PayloadKinds lists all kinds of structured payloads, along with their labels.
+var PayloadKinds = []struct{ Kind, Label string }{
+	{PayloadWifi, "Wi-Fi"},
+	{PayloadVCard, "Contact (vCard)"},
+	{PayloadEvent, "Calendar Event"},
+	{PayloadGeo, "Location"},
+	{PayloadText, "Text"},
+}
+
+const (
+	// maxPayloadLength is well within the capacity of a QR Code at the highest level of error correction
+	// (1,273 bytes), while leaving room for lower levels to remain scannable when printed small.
+	maxPayloadLength = 1024
+
+	// eventTimeLayout is the format of “datetime-local” inputs.
+	eventTimeLayout = "2006-01-02T15:04"
+)
+
+// EncodePayload validates the fields of a structured payload, and encodes it as text in the format
+// understood by the camera apps of common phones.
+func EncodePayload(kind string, fields url.Values) (string, error) {
+	get := func(name string) string { return strings.TrimSpace(fields.Get(name)) }
+
+	var payload string
+	switch kind {
+	case PayloadWifi:
+		ssid := get("ssid")
+		if ssid == "" {
+			return "", errors.New("missing ssid")
+		}
+		security := get("security")
+		switch security {
+		case "WPA", "WEP":
+			if fields.Get("password") == "" {
+				return "", errors.New("missing password for " + security + " network")
+			}
+		case "nopass":
+		default:
+			return "", errors.New("invalid security: " + security + " (must be WPA, WEP, or nopass)")
+		}
+		payload = "WIFI:T:" + security + ";S:" + escapeWifi(ssid) + ";"
+		if security != "nopass" {
+			payload += "P:" + escapeWifi(fields.Get("password")) + ";"
+		}
+		if hidden, _ := strconv.ParseBool(get("hidden")); hidden {
+			payload += "H:true;"
+		}
+		payload += ";"
+
+	case PayloadVCard:
+		first, last := get("first_name"), get("last_name")
+		if first == "" && last == "" {
+			return "", errors.New("missing name")
+		}
+		lines := []string{
+			"BEGIN:VCARD",
+			"VERSION:3.0",
+			"N:" + escapeText(last) + ";" + escapeText(first) + ";;;",
+			"FN:" + escapeText(strings.TrimSpace(first+" "+last)),
+		}
+		for _, prop := range []struct{ name, field string }{
+			{"ORG", "org"},
+			{"TITLE", "title"},
+			{"TEL", "phone"},
+			{"EMAIL", "email"},
+			{"URL", "url"},
+			{"NOTE", "note"},
+		} {
+			if value := get(prop.field); value != "" {
+				lines = append(lines, prop.name+":"+escapeText(value))
+			}
+		}
+		if address := get("address"); address != "" {
+			lines = append(lines, "ADR:;;"+escapeText(address)+";;;;")
+		}
+		payload = strings.Join(append(lines, "END:VCARD"), "\r\n")
+
+	case PayloadEvent:
+		summary := get("summary")
+		if summary == "" {
+			return "", errors.New("missing summary")
+		}
+		start, err := time.Parse(eventTimeLayout, get("start"))
+		if err != nil {
+			return "", errors.New("invalid start: " + get("start"))
+		}
+		end := start.Add(time.Hour)
+		if s := get("end"); s != "" {
+			if end, err = time.Parse(eventTimeLayout, s); err != nil {
+				return "", errors.New("invalid end: " + s)
+			} else if !end.After(start) {
+				return "", errors.New("end must be after start")
+			}
+		}
+		// Times are “floating”, i.e. in the local time zone of whoever scans the QR Code.
+		lines := []string{
+			"BEGIN:VEVENT",
+			"SUMMARY:" + escapeText(summary),
+			"DTSTART:" + start.Format("20060102T150405"),
+			"DTEND:" + end.Format("20060102T150405"),
+		}
+		if location := get("location"); location != "" {
+			lines = append(lines, "LOCATION:"+escapeText(location))
+		}
+		if description := get("description"); description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeText(description))
+		}
+		payload = strings.Join(append(lines, "END:VEVENT"), "\r\n")
+
+	case PayloadGeo:
+		latitude, err := strconv.ParseFloat(get("latitude"), 64)
+		if err != nil || latitude < -90 || latitude > 90 {
+			return "", errors.New("invalid latitude: " + get("latitude") + " (must be -90–90)")
+		}
+		longitude, err := strconv.ParseFloat(get("longitude"), 64)
+		if err != nil || longitude < -180 || longitude > 180 {
+			return "", errors.New("invalid longitude: " + get("longitude") + " (must be -180–180)")
+		}
+		coordinates := strconv.FormatFloat(latitude, 'f', -1, 64) + "," + strconv.FormatFloat(longitude, 'f', -1, 64)
+		payload = "geo:" + coordinates
+		if label := get("label"); label != "" {
+			// Shows a pin with the label, rather than searching for it near the coordinates.
+			payload += "?q=" + coordinates + "(" + strings.ReplaceAll(url.QueryEscape(label), "+", "%20") + ")"
+		}
+
+	case PayloadText:
+		payload = fields.Get("text")
+		if strings.TrimSpace(payload) == "" {
+			return "", errors.New("missing text")
+		}
+
+	default:
+		return "", errors.New("invalid kind: " + kind)
+	}
+
+	if len(payload) > maxPayloadLength {
+		return "", fmt.Errorf("payload too long: %d bytes (max %d)", len(payload), maxPayloadLength)
+	}
+	return payload, nil
+}
+
+// EncryptPayload encrypts payloads containing secrets, i.e. Wi-Fi passwords, using the same key as domain
+// credentials, so that they are not stored as plaintext. Returns the payload to be stored as plaintext (empty
+// if encrypted), and the encrypted payload (nil if not).
+func EncryptPayload(kind, payload string) (string, []byte, error) {
+	if kind != PayloadWifi {
+		return payload, nil, nil
+	}
+	if !credentials.IsEnabled() {
+		return "", nil, errors.New("Wi-Fi QR Codes are stored encrypted, which requires domains.credentials.encryption_key to be configured")
+	}
+	encrypted, err := credentials.EncryptSecret(payload)
+	return "", encrypted, err
+}
+
+// DecryptPayload returns the text encoded in a stored QR Code, decrypting it if needed.
+func DecryptPayload(p db.QrPayload) (string, error) {
+	if p.Encrypted == nil {
+		return p.Payload, nil
+	}
+	return credentials.DecryptSecret(p.Encrypted)
+}
+
+// EncryptStoredPayloads encrypts Wi-Fi payloads saved before they were encrypted, once an encryption key has
+// been configured, and returns the number encrypted.
+func EncryptStoredPayloads(ctx context.Context, q *db.Queries) (int, error) {
+	if !credentials.IsEnabled() {
+		return 0, nil
+	}
+	payloads, err := q.ListPlaintextQrPayloads(ctx, PayloadWifi)
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range payloads {
+		_, encrypted, err := EncryptPayload(p.Kind, p.Payload)
+		if err == nil {
+			err = q.EncryptQrPayload(ctx, db.EncryptQrPayloadParams{ID: p.ID, Encrypted: encrypted})
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(payloads), nil
+}
+
+// escapeWifi escapes special characters in the fields of Wi-Fi payloads.
+func escapeWifi(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `"`, `\"`, `:`, `\:`).Replace(s)
+}
+
+// escapeText escapes special characters in the values of vCard & iCalendar properties.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// ParsePayloadStyle validates the styling parameters for a structured payload, and returns them in canonical
+// form, to be stored alongside it. Logos & tracking only apply to URLs, and the format is chosen when the QR
+// Code is downloaded.
+func ParsePayloadStyle(query url.Values) (string, error) {
+	style, variant, err := parseStyle(query)
+	if err != nil {
+		return "", err
+	}
+	if style.Logo != "" || style.Track {
+		return "", errors.New("logos & tracking are only available for URLs")
+	}
+	variant.Del("format")
+	return variant.Encode(), nil
+}
+
+// GeneratePayload creates a QR Code for a stored payload, in its stored style, and returns it along with
+// its MIME type.
+func GeneratePayload(payload, storedStyle, format string) ([]byte, string, error) {
+	query, err := url.ParseQuery(storedStyle)
+	if err != nil {
+		return nil, "", err
+	}
+	query.Set("format", format)
+	style, _, err := parseStyle(query)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := generateQrCode(payload, style, nil)
+	return data, style.contentType(), err
+}
@@ -0,0 +1,225 @@
+package qrcode
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/db"
+)
+
+func TestEscapeWifi(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"Home Network":     "Home Network",
+		`a;b,c:d"e\f`:      `a\;b\,c\:d\"e\\f`,
+		`\;`:               `\\\;`,
+		"Café 5G":          "Café 5G",
+		"pass:word;123,45": `pass\:word\;123\,45`,
+	}
+	for input, want := range tests {
+		if got := escapeWifi(input); got != want {
+			t.Errorf("escapeWifi(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"Acme, Inc.":            `Acme\, Inc.`,
+		`a;b\c`:                 `a\;b\\c`,
+		"line 1\nline 2":        `line 1\nline 2`,
+		"line 1\r\nline 2":      `line 1\nline 2`,
+		"Colons: are fine here": "Colons: are fine here",
+	}
+	for input, want := range tests {
+		if got := escapeText(input); got != want {
+			t.Errorf("escapeText(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestEncodePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		fields  url.Values
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "wifi",
+			kind:   PayloadWifi,
+			fields: url.Values{"ssid": {"Home;Net"}, "security": {"WPA"}, "password": {"p:ss"}},
+			want:   `WIFI:T:WPA;S:Home\;Net;P:p\:ss;;`,
+		},
+		{
+			name:   "wifi hidden",
+			kind:   PayloadWifi,
+			fields: url.Values{"ssid": {"Lab"}, "security": {"WEP"}, "password": {"secret"}, "hidden": {"true"}},
+			want:   "WIFI:T:WEP;S:Lab;P:secret;H:true;;",
+		},
+		{
+			name:   "wifi without password",
+			kind:   PayloadWifi,
+			fields: url.Values{"ssid": {"Cafe"}, "security": {"nopass"}, "password": {"ignored"}},
+			want:   "WIFI:T:nopass;S:Cafe;;",
+		},
+		{
+			name:    "wifi missing password",
+			kind:    PayloadWifi,
+			fields:  url.Values{"ssid": {"Home"}, "security": {"WPA"}},
+			wantErr: true,
+		},
+		{
+			name:    "wifi invalid security",
+			kind:    PayloadWifi,
+			fields:  url.Values{"ssid": {"Home"}, "security": {"WPA3"}, "password": {"secret"}},
+			wantErr: true,
+		},
+		{
+			name:   "vcard",
+			kind:   PayloadVCard,
+			fields: url.Values{"first_name": {"Ada"}, "last_name": {"Lovelace"}, "org": {"Analytical Engines, Ltd."}, "phone": {"+44 20 7946 0000"}, "address": {"12 St James’s Square\nLondon"}},
+			want: strings.Join([]string{
+				"BEGIN:VCARD",
+				"VERSION:3.0",
+				"N:Lovelace;Ada;;;",
+				"FN:Ada Lovelace",
+				`ORG:Analytical Engines\, Ltd.`,
+				"TEL:+44 20 7946 0000",
+				`ADR:;;12 St James’s Square\nLondon;;;;`,
+				"END:VCARD",
+			}, "\r\n"),
+		},
+		{
+			name:   "vcard first name only",
+			kind:   PayloadVCard,
+			fields: url.Values{"first_name": {"Ada"}},
+			want:   "BEGIN:VCARD\r\nVERSION:3.0\r\nN:;Ada;;;\r\nFN:Ada\r\nEND:VCARD",
+		},
+		{
+			name:    "vcard missing name",
+			kind:    PayloadVCard,
+			fields:  url.Values{"email": {"ada@example.com"}},
+			wantErr: true,
+		},
+		{
+			name:   "event",
+			kind:   PayloadEvent,
+			fields: url.Values{"summary": {"Launch; party"}, "start": {"2026-03-14T18:30"}, "end": {"2026-03-14T21:00"}, "location": {"Hall A, Floor 2"}},
+			want: strings.Join([]string{
+				"BEGIN:VEVENT",
+				`SUMMARY:Launch\; party`,
+				"DTSTART:20260314T183000",
+				"DTEND:20260314T210000",
+				`LOCATION:Hall A\, Floor 2`,
+				"END:VEVENT",
+			}, "\r\n"),
+		},
+		{
+			name:   "event default end",
+			kind:   PayloadEvent,
+			fields: url.Values{"summary": {"Standup"}, "start": {"2026-03-14T23:30"}},
+			want:   "BEGIN:VEVENT\r\nSUMMARY:Standup\r\nDTSTART:20260314T233000\r\nDTEND:20260315T003000\r\nEND:VEVENT",
+		},
+		{
+			name:    "event ends before start",
+			kind:    PayloadEvent,
+			fields:  url.Values{"summary": {"Standup"}, "start": {"2026-03-14T10:00"}, "end": {"2026-03-14T09:00"}},
+			wantErr: true,
+		},
+		{
+			name:    "event invalid start",
+			kind:    PayloadEvent,
+			fields:  url.Values{"summary": {"Standup"}, "start": {"tomorrow"}},
+			wantErr: true,
+		},
+		{
+			name:   "geo",
+			kind:   PayloadGeo,
+			fields: url.Values{"latitude": {"51.5074"}, "longitude": {"-0.1278"}},
+			want:   "geo:51.5074,-0.1278",
+		},
+		{
+			name:   "geo with label",
+			kind:   PayloadGeo,
+			fields: url.Values{"latitude": {"48.8584"}, "longitude": {"2.2945"}, "label": {"Tour Eiffel & café"}},
+			want:   "geo:48.8584,2.2945?q=48.8584,2.2945(Tour%20Eiffel%20%26%20caf%C3%A9)",
+		},
+		{
+			name:    "geo latitude out of range",
+			kind:    PayloadGeo,
+			fields:  url.Values{"latitude": {"91"}, "longitude": {"0"}},
+			wantErr: true,
+		},
+		{
+			name:    "geo invalid longitude",
+			kind:    PayloadGeo,
+			fields:  url.Values{"latitude": {"0"}, "longitude": {"east"}},
+			wantErr: true,
+		},
+		{
+			name:   "text",
+			kind:   PayloadText,
+			fields: url.Values{"text": {"  Hello, world!\n"}},
+			want:   "  Hello, world!\n",
+		},
+		{
+			name:    "text blank",
+			kind:    PayloadText,
+			fields:  url.Values{"text": {" \n "}},
+			wantErr: true,
+		},
+		{
+			name:    "text too long",
+			kind:    PayloadText,
+			fields:  url.Values{"text": {strings.Repeat("a", maxPayloadLength+1)}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid kind",
+			kind:    "sms",
+			fields:  url.Values{"text": {"Hello"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodePayload(tt.kind, tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("EncodePayload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptPayload(t *testing.T) {
+	t.Cleanup(func() { conf.Config.Domains.Credentials.EncryptionKey = "" })
+	wifi := "WIFI:T:WPA;S:Home;P:hunter2;;"
+
+	if _, _, err := EncryptPayload(PayloadWifi, wifi); err == nil {
+		t.Error("EncryptPayload() should fail for Wi-Fi without an encryption key")
+	}
+	if plaintext, encrypted, err := EncryptPayload(PayloadText, "Hello"); err != nil || plaintext != "Hello" || encrypted != nil {
+		t.Errorf("EncryptPayload(text) = %q, %v, %v; want it stored as plaintext", plaintext, encrypted, err)
+	}
+
+	conf.Config.Domains.Credentials.EncryptionKey = "test key"
+	plaintext, encrypted, err := EncryptPayload(PayloadWifi, wifi)
+	if err != nil {
+		t.Fatalf("EncryptPayload() error = %v", err)
+	}
+	if plaintext != "" || strings.Contains(string(encrypted), "hunter2") {
+		t.Errorf("EncryptPayload() stored the password as plaintext: %q, %q", plaintext, encrypted)
+	}
+	got, err := DecryptPayload(db.QrPayload{Kind: PayloadWifi, Payload: plaintext, Encrypted: encrypted})
+	if err != nil || got != wifi {
+		t.Errorf("DecryptPayload() = %q, %v; want %q", got, err, wifi)
+	}
+}
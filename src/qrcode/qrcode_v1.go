@@ -3,15 +3,21 @@ package qrcode
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"image"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"path/filepath"
+	"time"
 
 	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/core"
 	"butterfly.chimbori.dev/db"
 	"butterfly.chimbori.dev/validation"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/lmittmann/tint"
 	"github.com/yeqown/go-qrcode/v2"
 	"github.com/yeqown/go-qrcode/writer/standard"
@@ -29,15 +35,20 @@ func Init(mux *http.ServeMux) {
 	} // else cache will be nil
 
 	mux.HandleFunc("GET /qrcode/v1", handleQrCode)
+	mux.HandleFunc("GET /q/{slug}", handleShortLink)
 }
 
-// GET /qrcode/v1?url={url}
+// GET /qrcode/v1?url={url}&size={px}&fg={RRGGBB}&bg={RRGGBB}&transparent={true|false}&margin={modules}&ecc={L|M|Q|H}&shape={square|circle}&logo={domain|favicon}&format={png|svg|pdf}&track={true|false}
 // Validates the URL, checks if it’s cached, generates QR Code, and serves it.
 func handleQrCode(w http.ResponseWriter, req *http.Request) {
 	reqUrl := req.URL.Query().Get("url")
+	canonicalUserAgent := core.GetCanonicalUserAgent(req.Header.Get("User-Agent"))
 	queries := db.New(db.Pool)
 
-	url, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
+	// QR Codes encode the URL as requested, since every part of it may matter to whoever scans it, and are
+	// cached by it too. Accesses & cache keys are recorded under the canonical URL instead, so that every
+	// form of a URL is counted, listed, and deleted as one in the Dashboard.
+	canonicalUrl, url, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
 	if err != nil {
 		slog.Error("URL validation failed", tint.Err(err),
 			"method", req.Method,
@@ -49,11 +60,46 @@ func handleQrCode(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	style, variant, err := parseStyle(req.URL.Query())
+	if err != nil {
+		slog.Error("style validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if style.Logo == LogoDomain {
+		if version := logoVersion(req.Context(), queries, hostname); version != "" {
+			variant.Set("logo_version", version)
+		}
+	}
+
+	// Tracked QR Codes encode a short link instead, which records each scan before redirecting to the URL.
+	text := url
+	if style.Track {
+		link, err := findOrCreateShortLink(req.Context(), queries, url)
+		if err != nil {
+			slog.Error("failed to create short link", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		text = shortLinkUrl(link.Slug)
+	}
+	cacheKey := CacheKey(text, variant)
+
 	var cached []byte
 
 	// Only check cache if enabled
 	if *conf.Config.QrCodes.Cache.Enabled {
-		cached, err = Cache.Find(url)
+		cached, err = Cache.Find(cacheKey)
 		if err != nil {
 			slog.Error("error during cache lookup", tint.Err(err),
 				"method", req.Method,
@@ -73,15 +119,15 @@ func handleQrCode(w http.ResponseWriter, req *http.Request) {
 			"url", url,
 			"hostname", hostname,
 			"status", http.StatusOK)
-		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Type", style.contentType())
 		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
 		w.Write(cached)
-		recordQrCodeAccessed(url)
+		recordQrCodeAccessed(canonicalUrl, canonicalUserAgent)
 		return
 	}
 
 	// Generate new QR Code
-	png, err := generateQrCode(url)
+	generated, withLogo, err := generateQrCodeWithLogo(req.Context(), queries, text, url, hostname, style)
 	if err != nil {
 		slog.Error("error generating QR Code", tint.Err(err),
 			"method", req.Method,
@@ -100,24 +146,37 @@ func handleQrCode(w http.ResponseWriter, req *http.Request) {
 		"url", url,
 		"hostname", hostname,
 		"status", http.StatusOK)
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
-	w.Write(png)
-	recordQrCodeCreated(url)
-
-	// If cache is enabled, compress the generated QR Code and cache it, but without holding up the HTTP request
-	go func() {
-		if *conf.Config.QrCodes.Cache.Enabled {
-			dataToWrite := png
-			compressed, err := core.CompressPNG(png)
-			if err == nil {
-				dataToWrite = compressed
-				slog.Info("PNG compressed", "from", len(png), "to", len(compressed), "%", (len(compressed) * 100 / len(png)))
-			} else {
-				slog.Error("PNG compression failed", tint.Err(err), "url", url)
+	w.Header().Set("Content-Type", style.contentType())
+	if withLogo {
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+	} else {
+		// QR Codes without their requested logo are only a stopgap, so let clients retry soon, once the logo
+		// is fixed, or can be fetched again.
+		w.Header().Set("Cache-Control", "max-age=300") // 5 minutes
+	}
+	w.Write(generated)
+	recordQrCodeCreated(canonicalUrl, canonicalUserAgent)
+
+	// If cache is enabled, compress the generated QR Code and cache it, but without holding up the HTTP request.
+	// QR Codes without their requested logo are not cached, so that the logo is tried again next time.
+	if *conf.Config.QrCodes.Cache.Enabled && withLogo {
+		var dataToWrite []byte
+		core.Background.Submit("QR code: "+url, func() error {
+			// Compress only once, even if writing to the cache has to be retried. SVGs & PDFs are already compact.
+			if dataToWrite == nil {
+				dataToWrite = generated
+				if style.Format == "png" {
+					compressed, err := core.CompressPNG(generated)
+					if err == nil {
+						dataToWrite = compressed
+						slog.Info("PNG compressed", "from", len(generated), "to", len(compressed), "%", (len(compressed) * 100 / len(generated)))
+					} else {
+						slog.Error("PNG compression failed", tint.Err(err), "url", url)
+					}
+				}
 			}
 
-			if err := Cache.Write(url, dataToWrite); err != nil {
+			if err := writeCached(context.Background(), canonicalUrl, cacheKey, dataToWrite); err != nil {
 				err = fmt.Errorf("error writing to cache: %s, %w", url, err)
 				slog.Error("error writing to cache", tint.Err(err),
 					"method", req.Method,
@@ -125,9 +184,11 @@ func handleQrCode(w http.ResponseWriter, req *http.Request) {
 					"url", url,
 					"hostname", hostname,
 					"status", http.StatusInternalServerError)
+				return err
 			}
-		}
-	}()
+			return nil
+		})
+	}
 }
 
 // writeCloser wraps an io.Writer and adds a no-op Close method
@@ -139,17 +200,33 @@ func (wc *writeCloser) Close() error {
 	return nil
 }
 
-// generateQrCode creates a QR Code PNG for the given URL
-func generateQrCode(url string) ([]byte, error) {
-	qrc, err := qrcode.New(url)
+// generateQrCode creates a QR Code for the given URL, in the given style & format, with an optional logo
+func generateQrCode(url string, style Style, logo image.Image) ([]byte, error) {
+	qrc, err := qrcode.NewWith(url, style.encodeOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
+	switch style.Format {
+	case "svg", "pdf":
+		v, err := newVectorQrCode(qrc, style, logo)
+		if err != nil {
+			return nil, err
+		}
+		if style.Format == "svg" {
+			return v.svg()
+		}
+		return v.pdf()
+	}
+
 	// Create a buffer to write the QR code to
 	var buf bytes.Buffer
 	wc := &writeCloser{&buf}
-	writer := standard.NewWithWriter(wc, standard.WithBuiltinImageEncoder(standard.PNG_FORMAT))
+	opts := append(style.imageOptions(), standard.WithBuiltinImageEncoder(standard.PNG_FORMAT))
+	if logo != nil {
+		opts = append(opts, style.logoOptions(logo, qrc.Dimension())...)
+	}
+	writer := standard.NewWithWriter(wc, opts...)
 
 	if err := qrc.Save(writer); err != nil {
 		return nil, err
@@ -158,24 +235,99 @@ func generateQrCode(url string) ([]byte, error) {
 }
 
 // recordQrCodeCreated records when a QR Code is created (for the first time)
-func recordQrCodeCreated(url string) {
+func recordQrCodeCreated(url string, canonicalUserAgent string) {
 	queries := db.New(db.Pool)
-	err := queries.RecordQrCodeCreated(context.Background(), url)
+	err := queries.RecordQrCodeCreated(context.Background(), db.RecordQrCodeCreatedParams{
+		Url:                url,
+		CanonicalUserAgent: &canonicalUserAgent,
+	})
 	if err != nil {
 		slog.Error("failed to log QR Code created", tint.Err(err))
 	}
+	recordQrCodeDailyAccess(url, canonicalUserAgent)
 }
 
 // recordQrCodeAccessed records when a QR Code is accessed from the cache
-func recordQrCodeAccessed(url string) {
+func recordQrCodeAccessed(url string, canonicalUserAgent string) {
 	queries := db.New(db.Pool)
-	err := queries.RecordQrCodeAccessed(context.Background(), url)
+	rowsUpdated, err := queries.RecordQrCodeAccessed(context.Background(), db.RecordQrCodeAccessedParams{
+		Url:                url,
+		CanonicalUserAgent: &canonicalUserAgent,
+	})
 	if err != nil {
 		slog.Error("failed to log QR Code accessed", tint.Err(err))
 	}
+	if rowsUpdated == 0 { // If not already in the database, add it now.
+		recordQrCodeCreated(url, canonicalUserAgent)
+		return
+	}
+	recordQrCodeDailyAccess(url, canonicalUserAgent)
 }
 
-// DeleteCached removes a cached QR Code file from disk.
-func DeleteCached(url string) error {
-	return Cache.Delete(url)
+// recordQrCodeDailyAccess adds an access to the daily rollup charted in the Dashboard.
+func recordQrCodeDailyAccess(url string, canonicalUserAgent string) {
+	queries := db.New(db.Pool)
+	err := queries.RecordQrCodeDailyAccess(context.Background(), db.RecordQrCodeDailyAccessParams{
+		Url:                url,
+		CanonicalUserAgent: canonicalUserAgent,
+	})
+	if err != nil {
+		slog.Error("failed to log QR Code daily access", tint.Err(err))
+	}
+}
+
+// CacheKey returns the key under which a styled variant of a QR Code is stored in [Cache].
+// The default style is keyed by the URL alone.
+func CacheKey(url string, variant neturl.Values) string {
+	if len(variant) == 0 {
+		return url
+	}
+	return url + " " + variant.Encode() // A URL can never contain an unescaped space.
+}
+
+// cacheName identifies [Cache] in the cache_keys table.
+const cacheName = "qr-codes"
+
+// writeCached stores a variant of a QR Code in [Cache], and records its key under the canonical URL it links to,
+// so that it can be deleted along with every other variant, including those of other forms of the URL, and
+// those of tracked QR Codes, keyed by short links.
+func writeCached(ctx context.Context, url, cacheKey string, data []byte) error {
+	if err := Cache.Write(cacheKey, data); err != nil {
+		return err
+	}
+	return db.New(db.Pool).RecordCacheKey(ctx, db.RecordCacheKeyParams{Cache: cacheName, CacheKey: cacheKey, Url: url})
+}
+
+// DeleteCached removes every cached variant of a QR Code from disk, in each format, style, and with each logo.
+func DeleteCached(ctx context.Context, q *db.Queries, url string) error {
+	keys, err := q.DeleteCacheKeys(ctx, db.DeleteCacheKeysParams{Cache: cacheName, Url: url})
+	if err != nil || Cache == nil {
+		return err
+	}
+	// Also the unstyled variants, in case they were cached before their keys were recorded.
+	for format := range contentTypes {
+		variant := neturl.Values{}
+		if format != defaultStyle.Format {
+			variant.Set("format", format)
+		}
+		keys = append(keys, CacheKey(url, variant))
+	}
+	var errs []error
+	for _, key := range keys {
+		if err := Cache.Delete(key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteExpiredCacheKeys forgets the keys of variants that have expired from [Cache].
+func DeleteExpiredCacheKeys(ctx context.Context, q *db.Queries) (int64, error) {
+	if Cache == nil || Cache.TTL == 0 {
+		return 0, nil
+	}
+	return q.DeleteExpiredCacheKeys(ctx, db.DeleteExpiredCacheKeysParams{
+		Cache: cacheName,
+		Ttl:   pgtype.Interval{Microseconds: int64(Cache.TTL / time.Microsecond), Valid: true},
+	})
 }
@@ -0,0 +1,560 @@
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"github.com/lmittmann/tint"
+	"github.com/yeqown/go-qrcode/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	mm   = 72 / 25.4 // Points per millimeter.
+	inch = 72        // Points per inch.
+
+	// maxSheetUrls limits the number of QR Codes generated for a single sheet, and maxFetchedSheetUrls limits
+	// those whose titles or favicons must be fetched.
+	maxSheetUrls        = 500
+	maxFetchedSheetUrls = 100
+
+	maxGridSize      = 10
+	maxCaptionLength = 200
+
+	// sheetConcurrency limits the number of pages fetched simultaneously for titles & favicons.
+	sheetConcurrency = 8
+	captionTimeout   = 10 * time.Second
+	// sheetFetchTimeout limits the time spent fetching titles & favicons for an entire sheet; once it
+	// expires, the remaining QR Codes are captioned with their URLs, and generated without logos not found by then.
+	sheetFetchTimeout = 60 * time.Second
+)
+
+// Captions, other than literal text, printed below each QR Code on a sheet.
+const (
+	CaptionTitle = "title" // The title of the page.
+	CaptionUrl   = "url"   // The URL itself, without its scheme.
+)
+
+// SheetTemplate is the layout of a sheet of paper or labels; all sizes are in points.
+type SheetTemplate struct {
+	Name          string
+	Description   string
+	Width, Height float64
+	Columns, Rows int
+	// Top & Left are the margins to the first label; PitchX & PitchY are the distances between the top-left
+	// corners of adjacent labels.
+	Top, Left               float64
+	LabelWidth, LabelHeight float64
+	PitchX, PitchY          float64
+}
+
+// SheetTemplates are the supported layouts. Plain paper is divided into a grid of configurable size, while
+// labels match the dimensions of commonly-available sheets.
+var SheetTemplates = []SheetTemplate{
+	{Name: "a4", Description: "A4 paper", Width: 210 * mm, Height: 297 * mm},
+	{Name: "letter", Description: "US Letter paper", Width: 8.5 * inch, Height: 11 * inch},
+	{
+		Name: "avery-l7160", Description: "Avery L7160 (A4, 21 labels, 63.5 × 38.1 mm)", Width: 210 * mm, Height: 297 * mm,
+		Columns: 3, Rows: 7, Top: 15.15 * mm, Left: 7.25 * mm,
+		LabelWidth: 63.5 * mm, LabelHeight: 38.1 * mm, PitchX: 66.04 * mm, PitchY: 38.1 * mm,
+	},
+	{
+		Name: "avery-l7163", Description: "Avery L7163 (A4, 14 labels, 99.1 × 38.1 mm)", Width: 210 * mm, Height: 297 * mm,
+		Columns: 2, Rows: 7, Top: 15.15 * mm, Left: 4.65 * mm,
+		LabelWidth: 99.1 * mm, LabelHeight: 38.1 * mm, PitchX: 101.6 * mm, PitchY: 38.1 * mm,
+	},
+	{
+		Name: "avery-5160", Description: "Avery 5160 (Letter, 30 labels, 2⅝ × 1 in)", Width: 8.5 * inch, Height: 11 * inch,
+		Columns: 3, Rows: 10, Top: 0.5 * inch, Left: 0.1875 * inch,
+		LabelWidth: 2.625 * inch, LabelHeight: 1 * inch, PitchX: 2.75 * inch, PitchY: 1 * inch,
+	},
+	{
+		Name: "avery-5163", Description: "Avery 5163 (Letter, 10 labels, 4 × 2 in)", Width: 8.5 * inch, Height: 11 * inch,
+		Columns: 2, Rows: 5, Top: 0.5 * inch, Left: 0.15625 * inch,
+		LabelWidth: 4 * inch, LabelHeight: 2 * inch, PitchX: 4.1875 * inch, PitchY: 2 * inch,
+	},
+}
+
+// plainPaperMargin & defaultGrid lay out QR Codes on plain paper, where the grid is configurable.
+const (
+	plainPaperMargin = 0.5 * inch
+	defaultColumns   = 3
+	defaultRows      = 4
+)
+
+// SheetOptions customizes a printable sheet of QR Codes.
+type SheetOptions struct {
+	Template SheetTemplate
+	// Caption is printed below each QR Code: [CaptionTitle], [CaptionUrl], any other text (e.g. “Scan me”),
+	// or empty for none.
+	Caption string
+	// Frame draws a border around each QR Code & its caption, e.g. to cut them out.
+	Frame bool
+	// Style of each QR Code; Format is “pdf” or “svg”.
+	Style Style
+}
+
+// ParseSheetOptions validates the parameters of a request for a sheet, which accepts all the styling
+// parameters of individual QR Codes, along with “template”, “columns” & “rows” (for plain paper only),
+// “caption”, and “frame”.
+func ParseSheetOptions(query neturl.Values) (SheetOptions, error) {
+	style, _, err := parseStyle(query)
+	if err != nil {
+		return SheetOptions{}, err
+	}
+	if query.Get("format") == "" {
+		style.Format = "pdf"
+	}
+	if style.Format != "pdf" && style.Format != "svg" {
+		return SheetOptions{}, errors.New("invalid format: " + style.Format + " (must be pdf or svg)")
+	}
+	opts := SheetOptions{Style: style}
+
+	name := query.Get("template")
+	if name == "" {
+		name = SheetTemplates[0].Name
+	}
+	var found bool
+	for _, t := range SheetTemplates {
+		if t.Name == name {
+			opts.Template, found = t, true
+		}
+	}
+	if !found {
+		return opts, errors.New("invalid template: " + name)
+	}
+	if opts.Template.Columns == 0 { // Plain paper.
+		columns, err := gridSize(query.Get("columns"), defaultColumns)
+		if err != nil {
+			return opts, fmt.Errorf("invalid columns: %w", err)
+		}
+		rows, err := gridSize(query.Get("rows"), defaultRows)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rows: %w", err)
+		}
+		t := &opts.Template
+		t.Columns, t.Rows = columns, rows
+		t.Top, t.Left = plainPaperMargin, plainPaperMargin
+		t.LabelWidth = (t.Width - 2*plainPaperMargin) / float64(columns)
+		t.LabelHeight = (t.Height - 2*plainPaperMargin) / float64(rows)
+		t.PitchX, t.PitchY = t.LabelWidth, t.LabelHeight
+	}
+
+	opts.Caption = strings.TrimSpace(query.Get("caption"))
+	if len([]rune(opts.Caption)) > maxCaptionLength {
+		return opts, fmt.Errorf("caption too long (max %d characters)", maxCaptionLength)
+	}
+	if s := query.Get("frame"); s != "" {
+		if opts.Frame, err = strconv.ParseBool(s); err != nil {
+			return opts, errors.New("invalid frame: " + s)
+		}
+	}
+	return opts, nil
+}
+
+func gridSize(s string, defaultSize int) (int, error) {
+	if s == "" {
+		return defaultSize, nil
+	}
+	size, err := strconv.Atoi(s)
+	if err != nil || size < 1 || size > maxGridSize {
+		return 0, fmt.Errorf("%s (must be 1–%d)", s, maxGridSize)
+	}
+	return size, nil
+}
+
+// ContentType returns the MIME type of the sheet.
+func (opts SheetOptions) ContentType() string {
+	return opts.Style.contentType()
+}
+
+// CheckCount validates the number of QR Codes requested for a sheet.
+func (opts SheetOptions) CheckCount(count int) error {
+	perPage := opts.Template.Columns * opts.Template.Rows
+	if count == 0 {
+		return errors.New("no URLs")
+	} else if count > maxSheetUrls {
+		return fmt.Errorf("too many URLs: %d (max %d)", count, maxSheetUrls)
+	} else if opts.fetches() && count > maxFetchedSheetUrls {
+		return fmt.Errorf("too many URLs with titles or favicons: %d (max %d)", count, maxFetchedSheetUrls)
+	} else if opts.Style.Format == "svg" && count > perPage {
+		return fmt.Errorf("too many URLs for a single SVG page: %d (max %d); use format=pdf instead", count, perPage)
+	}
+	return nil
+}
+
+// fetches reports whether the title or favicon of each page must be fetched.
+func (opts SheetOptions) fetches() bool {
+	return opts.Caption == CaptionTitle || opts.Style.Logo == LogoFavicon
+}
+
+// sheetCode is a single QR Code on a sheet, along with its caption.
+type sheetCode struct {
+	qr      vectorQrCode
+	caption string
+}
+
+// RenderSheet generates QR Codes for the given URLs, which must already have been validated, and lays them
+// out on as many pages as needed. SVGs can only contain a single page.
+func RenderSheet(req *http.Request, urls []string, opts SheetOptions) ([]byte, error) {
+	if err := opts.CheckCount(len(urls)); err != nil {
+		return nil, err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(req.Context(), sheetFetchTimeout)
+	defer cancel()
+	logos := &sheetLogos{logos: map[string]*sheetLogo{}}
+
+	codes := make([]sheetCode, len(urls))
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, sheetConcurrency)
+	for i, url := range urls {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			codes[i], errs[i] = prepareSheetCode(req.Context(), fetchCtx, logos, url, opts)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if opts.Style.Format == "svg" {
+		return renderSheetSvg(codes, opts)
+	}
+	return renderSheetPdf(codes, opts)
+}
+
+// prepareSheetCode generates a QR Code for a URL, along with its logo & caption, if requested. Titles &
+// favicons are fetched using fetchCtx, which is shared by the entire sheet.
+func prepareSheetCode(ctx, fetchCtx context.Context, logos *sheetLogos, url string, opts SheetOptions) (sheetCode, error) {
+	queries := db.New(db.Pool)
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return sheetCode{}, err
+	}
+	hostname := u.Hostname()
+
+	text := url
+	if opts.Style.Track {
+		link, err := findOrCreateShortLink(ctx, queries, url)
+		if err != nil {
+			return sheetCode{}, err
+		}
+		text = shortLinkUrl(link.Slug)
+	}
+	qrc, err := qrcode.NewWith(text, opts.Style.encodeOptions()...)
+	if err != nil {
+		return sheetCode{}, err
+	}
+	var logo image.Image
+	if opts.Style.Logo != "" {
+		found, err := logos.find(fetchCtx, queries, opts.Style.Logo, url, hostname)
+		logo = scannableLogo(text, url, hostname, opts.Style, found, err)
+	}
+	v, err := newVectorQrCode(qrc, opts.Style, logo)
+	if err != nil {
+		return sheetCode{}, err
+	}
+
+	code := sheetCode{qr: v, caption: opts.Caption}
+	switch opts.Caption {
+	case CaptionUrl:
+		code.caption = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	case CaptionTitle:
+		code.caption = fetchTitle(fetchCtx, queries, url, hostname)
+	}
+	return code, nil
+}
+
+// sheetLogos finds the logo of each hostname on a sheet only once, since pages on the same site almost always
+// share the same favicon, so that it is neither fetched nor embedded for every URL.
+type sheetLogos struct {
+	mu    sync.Mutex
+	logos map[string]*sheetLogo
+}
+
+type sheetLogo struct {
+	once sync.Once
+	img  image.Image
+	err  error
+}
+
+// find returns the logo for the hostname of a URL, finding it using the first URL on the sheet for that host.
+func (l *sheetLogos) find(ctx context.Context, q *db.Queries, source, url, hostname string) (image.Image, error) {
+	l.mu.Lock()
+	logo, ok := l.logos[hostname]
+	if !ok {
+		logo = &sheetLogo{}
+		l.logos[hostname] = logo
+	}
+	l.mu.Unlock()
+
+	logo.once.Do(func() {
+		logo.img, logo.err = findLogo(ctx, q, source, url, hostname)
+	})
+	return logo.img, logo.err
+}
+
+// fetchTitle returns the title of a page, or the URL if the title cannot be fetched.
+func fetchTitle(ctx context.Context, q *db.Queries, url, hostname string) string {
+	creds, err := credentials.Find(ctx, q, hostname)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, captionTimeout)
+		defer cancel()
+		var title string
+		title, _, err = core.FetchTitleAndDescription(ctx, url, core.WithCredentials(creds))
+		if err == nil && title != "" {
+			return title
+		}
+	}
+	slog.Warn("title unavailable; using URL as caption", tint.Err(err),
+		"url", url,
+		"hostname", hostname)
+	return url
+}
+
+// cell is the layout of a QR Code & its caption within a label.
+type cell struct {
+	x, y, width, height float64 // The label itself.
+	padding             float64
+	qrX, qrY, qrSize    float64
+	fontSize            float64
+	// Each line of the caption is drawn from (textX, textY + i × lineHeight); it is centered horizontally
+	// around textX when below the QR Code, or left-aligned when beside it, on wide labels.
+	textX, textY, lineHeight float64
+	centered                 bool
+	lines                    []string
+}
+
+// layoutCell places a QR Code & its caption within the label at the given index on a page.
+func layoutCell(t SheetTemplate, index int, caption string) cell {
+	col, row := index%t.Columns, index/t.Columns
+	c := cell{
+		x: t.Left + float64(col)*t.PitchX, y: t.Top + float64(row)*t.PitchY,
+		width: t.LabelWidth, height: t.LabelHeight,
+	}
+	short := min(c.width, c.height)
+	c.padding = short * 0.06
+	c.fontSize = max(6, min(14, short*0.08))
+	c.lineHeight = c.fontSize * 1.2
+
+	inner := short - 2*c.padding
+	if caption == "" {
+		c.qrSize = inner
+		c.qrX, c.qrY = c.x+(c.width-c.qrSize)/2, c.y+(c.height-c.qrSize)/2
+		return c
+	}
+
+	if c.width >= 1.5*c.height { // Caption beside the QR Code, on wide labels.
+		c.qrSize = inner
+		c.qrX, c.qrY = c.x+c.padding, c.y+c.padding
+		c.textX = c.qrX + c.qrSize + c.padding
+		maxLines := max(1, int(inner/c.lineHeight))
+		c.lines = wrapText(caption, c.fontSize, c.x+c.width-c.padding-c.textX, maxLines)
+		textHeight := float64(len(c.lines)) * c.lineHeight
+		c.textY = c.y + (c.height-textHeight)/2 + c.fontSize*0.9
+		return c
+	}
+
+	// Caption below the QR Code, in up to 2 lines.
+	c.centered = true
+	c.lines = wrapText(caption, c.fontSize, c.width-2*c.padding, 2)
+	textHeight := float64(len(c.lines)) * c.lineHeight
+	c.qrSize = min(c.width-2*c.padding, c.height-2*c.padding-textHeight-c.padding/2)
+	blockY := c.y + (c.height-c.qrSize-c.padding/2-textHeight)/2
+	c.qrX, c.qrY = c.x+(c.width-c.qrSize)/2, blockY
+	c.textX = c.x + c.width/2
+	c.textY = blockY + c.qrSize + c.padding/2 + c.fontSize*0.9
+	return c
+}
+
+// renderSheetSvg lays out all QR Codes on a single page.
+func renderSheetSvg(codes []sheetCode, opts SheetOptions) ([]byte, error) {
+	t := opts.Template
+	fg := "#" + hexColor(opts.Style.Foreground)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%spt" height="%spt" viewBox="0 0 %s %s">`,
+		formatFloat(t.Width), formatFloat(t.Height), formatFloat(t.Width), formatFloat(t.Height))
+	for i, code := range codes {
+		c := layoutCell(t, i, code.caption)
+		if opts.Frame {
+			fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" fill="none" stroke="%s" stroke-width="0.75"/>`,
+				formatFloat(c.x+c.padding/2), formatFloat(c.y+c.padding/2), formatFloat(c.width-c.padding), formatFloat(c.height-c.padding),
+				formatFloat(c.padding), fg)
+		}
+		attrs := fmt.Sprintf(`x="%s" y="%s" width="%s" height="%s"`, formatFloat(c.qrX), formatFloat(c.qrY), formatFloat(c.qrSize), formatFloat(c.qrSize))
+		if err := code.qr.writeSvg(&buf, attrs); err != nil {
+			return nil, err
+		}
+		anchor := "start"
+		if c.centered {
+			anchor = "middle"
+		}
+		for j, line := range c.lines {
+			fmt.Fprintf(&buf, `<text x="%s" y="%s" text-anchor="%s" font-family="Helvetica, Arial, sans-serif" font-size="%s" fill="%s">%s</text>`,
+				formatFloat(c.textX), formatFloat(c.textY+float64(j)*c.lineHeight), anchor, formatFloat(c.fontSize), fg, html.EscapeString(line))
+		}
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// renderSheetPdf lays out all QR Codes on as many pages as needed.
+func renderSheetPdf(codes []sheetCode, opts SheetOptions) ([]byte, error) {
+	t := opts.Template
+	perPage := t.Columns * t.Rows
+	doc := newPdfWriter()
+	font := doc.useFont()
+	for start := 0; start < len(codes); start += perPage {
+		var content bytes.Buffer
+		pdfFlipY(&content, t.Height)
+		for i, code := range codes[start:min(start+perPage, len(codes))] {
+			c := layoutCell(t, i, code.caption)
+			if opts.Frame {
+				fmt.Fprintf(&content, "%s RG 0.75 w\n", pdfColor(opts.Style.Foreground))
+				pdfRoundedRect(&content, c.x+c.padding/2, c.y+c.padding/2, c.width-c.padding, c.height-c.padding, c.padding)
+				content.WriteString("S\n")
+			}
+			if err := code.qr.writePdf(&content, doc, c.qrX, c.qrY, c.qrSize); err != nil {
+				return nil, err
+			}
+			if len(c.lines) > 0 {
+				fmt.Fprintf(&content, "%s rg\n", pdfColor(opts.Style.Foreground))
+			}
+			for j, line := range c.lines {
+				x := c.textX
+				if c.centered {
+					x -= textWidth(line, c.fontSize) / 2
+				}
+				pdfText(&content, font, c.fontSize, x, c.textY+float64(j)*c.lineHeight, line)
+			}
+		}
+		if err := doc.addPage(t.Width, t.Height, content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return doc.bytes(), nil
+}
+
+// pdfRoundedRect appends a rectangle with rounded corners to the current path.
+func pdfRoundedRect(buf *bytes.Buffer, x, y, width, height, r float64) {
+	k := r * (1 - 0.5523) // Distance of control points from the corners.
+	f := formatFloat
+	fmt.Fprintf(buf, "%s %s m ", f(x+r), f(y))
+	fmt.Fprintf(buf, "%s %s l %s %s %s %s %s %s c ", f(x+width-r), f(y), f(x+width-k), f(y), f(x+width), f(y+k), f(x+width), f(y+r))
+	fmt.Fprintf(buf, "%s %s l %s %s %s %s %s %s c ", f(x+width), f(y+height-r), f(x+width), f(y+height-k), f(x+width-k), f(y+height), f(x+width-r), f(y+height))
+	fmt.Fprintf(buf, "%s %s l %s %s %s %s %s %s c ", f(x+r), f(y+height), f(x+k), f(y+height), f(x), f(y+height-k), f(x), f(y+height-r))
+	fmt.Fprintf(buf, "%s %s l %s %s %s %s %s %s c h\n", f(x), f(y+r), f(x), f(y+k), f(x+k), f(y), f(x+r), f(y))
+}
+
+// pdfText appends a line of text, with its baseline at (x, y) on a page flipped using [pdfFlipY]. Characters
+// that cannot be represented in the standard fonts are replaced with “?”.
+func pdfText(buf *bytes.Buffer, font string, size, x, y float64, text string) {
+	var encoded bytes.Buffer
+	for _, r := range text {
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			b = '?'
+		}
+		if b == '(' || b == ')' || b == '\\' {
+			encoded.WriteByte('\\')
+		}
+		encoded.WriteByte(b)
+	}
+	// Flip the text back upright, since the page is flipped.
+	fmt.Fprintf(buf, "BT /%s %s Tf 1 0 0 -1 %s %s Tm (", font, formatFloat(size), formatFloat(x), formatFloat(y))
+	buf.Write(encoded.Bytes())
+	buf.WriteString(") Tj ET\n")
+}
+
+// wrapText breaks text into lines that fit within maxWidth, truncating the last line with an ellipsis if
+// there are more than maxLines.
+func wrapText(text string, size, maxWidth float64, maxLines int) []string {
+	var lines []string
+	var line string
+	words := strings.Fields(text)
+	for i := 0; i < len(words); i++ {
+		candidate := words[i]
+		if line != "" {
+			candidate = line + " " + words[i]
+		}
+		if textWidth(candidate, size) <= maxWidth {
+			line = candidate
+			continue
+		}
+		if line == "" { // A single word that is too long, e.g. a URL: break it anywhere.
+			line = truncateText(words[i], size, maxWidth, "")
+			words[i] = strings.TrimPrefix(words[i], line)
+			i--
+		} else {
+			i--
+		}
+		if len(lines) == maxLines-1 {
+			return append(lines, truncateText(strings.Join(append([]string{line}, words[i+1:]...), " "), size, maxWidth, "…"))
+		}
+		lines = append(lines, line)
+		line = ""
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncateText returns the longest prefix of text that fits within maxWidth, followed by suffix, if the
+// text does not fit as is.
+func truncateText(text string, size, maxWidth float64, suffix string) string {
+	if textWidth(text, size) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		if prefix := strings.TrimSpace(string(runes[:n])) + suffix; textWidth(prefix, size) <= maxWidth {
+			return prefix
+		}
+	}
+	return string(runes[:1])
+}
+
+// textWidth estimates the width of text set in Helvetica, in points.
+func textWidth(text string, size float64) float64 {
+	var width int
+	for _, r := range text {
+		switch {
+		case r >= ' ' && r <= '~':
+			width += helveticaWidths[r-' ']
+		case r == '…':
+			width += 1000
+		default:
+			width += 556 // Typical of accented letters.
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// helveticaWidths are the widths of printable ASCII characters in Helvetica, in thousandths of an em.
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // ␠ to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
@@ -0,0 +1,207 @@
+package qrcode
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Digits are 5.56pt wide, and spaces 2.78pt, in 10pt Helvetica, so 5 digits fit within 30pt, but 6 do not.
+const testFontSize = 10
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth float64
+		maxLines int
+		want     []string
+	}{
+		{"empty", "", 30, 2, nil},
+		{"whitespace", " \n\t ", 30, 2, nil},
+		{"fits", "00 00", 30, 2, []string{"00 00"}},
+		{"fits exactly", "00000", textWidth("00000", testFontSize), 2, []string{"00000"}},
+		{"collapses whitespace", "  00 \n 00  ", 30, 2, []string{"00 00"}},
+		{"wraps", "000 000", 30, 2, []string{"000", "000"}},
+		{"wraps several words per line", "00 00 00 00", 30, 2, []string{"00 00", "00 00"}},
+		{"truncates the last line", "000 000 000", 30, 2, []string{"000", "000…"}},
+		{"truncates a single line", "000 000", 30, 1, []string{"000…"}},
+		{"breaks a long word", "0000000000", 30, 3, []string{"00000", "00000"}},
+		{"breaks & truncates a long word", "000000000000000", 30, 2, []string{"00000", "000…"}},
+		{"breaks a long word after others", "00 0000000", 30, 3, []string{"00", "00000", "00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapText(tt.text, testFontSize, tt.maxWidth, tt.maxLines)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("wrapText(%q, %v, %d) = %q, want %q", tt.text, tt.maxWidth, tt.maxLines, got, tt.want)
+			}
+			for _, line := range got {
+				if w := textWidth(line, testFontSize); w > tt.maxWidth {
+					t.Errorf("line %q is %vpt wide, more than %vpt", line, w, tt.maxWidth)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxWidth float64
+		suffix   string
+		want     string
+	}{
+		{"000", 30, "…", "000"},
+		{"000000", 30, "…", "000…"},
+		{"000000", 30, "", "00000"},
+		{"00 0000", 25, "…", "00…"}, // Without a space before the ellipsis.
+		{"café latte", 30, "…", "café…"},
+		{"000", 1, "…", "0"}, // Nothing fits, but something must be printed.
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.text, testFontSize, tt.maxWidth, tt.suffix); got != tt.want {
+			t.Errorf("truncateText(%q, %v, %q) = %q, want %q", tt.text, tt.maxWidth, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	if got := textWidth("", testFontSize); got != 0 {
+		t.Errorf("textWidth(\"\") = %v, want 0", got)
+	}
+	if got, want := textWidth("0 0", testFontSize), 13.9; got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("textWidth(\"0 0\") = %v, want %v", got, want)
+	}
+	if textWidth("W", testFontSize) <= textWidth("i", testFontSize) {
+		t.Error("textWidth() should be proportional")
+	}
+}
+
+func parseTestTemplate(t *testing.T, query url.Values) SheetTemplate {
+	t.Helper()
+	opts, err := ParseSheetOptions(query)
+	if err != nil {
+		t.Fatalf("ParseSheetOptions(%v) error = %v", query, err)
+	}
+	return opts.Template
+}
+
+// checkWithinLabel verifies that the QR Code & caption of a cell are drawn within its label.
+func checkWithinLabel(t *testing.T, c cell) {
+	t.Helper()
+	const epsilon = 1e-9
+	if c.qrSize <= 0 {
+		t.Fatalf("qrSize = %v, want > 0", c.qrSize)
+	}
+	if c.qrX < c.x-epsilon || c.qrY < c.y-epsilon || c.qrX+c.qrSize > c.x+c.width+epsilon || c.qrY+c.qrSize > c.y+c.height+epsilon {
+		t.Errorf("QR Code at (%v, %v) of size %v is outside the label at (%v, %v) of size %v × %v",
+			c.qrX, c.qrY, c.qrSize, c.x, c.y, c.width, c.height)
+	}
+	if len(c.lines) > 0 {
+		if last := c.textY + float64(len(c.lines)-1)*c.lineHeight; last > c.y+c.height-c.padding/2+epsilon {
+			t.Errorf("last line of caption at %v is below the label, which ends at %v", last, c.y+c.height)
+		}
+	}
+}
+
+func TestLayoutCell_Position(t *testing.T) {
+	tmpl := parseTestTemplate(t, url.Values{"template": {"a4"}})
+	c := layoutCell(tmpl, 4, "") // Second column of the second row, on a 3 × 4 grid.
+	if c.x != tmpl.Left+tmpl.PitchX || c.y != tmpl.Top+tmpl.PitchY {
+		t.Errorf("layoutCell(4) at (%v, %v), want (%v, %v)", c.x, c.y, tmpl.Left+tmpl.PitchX, tmpl.Top+tmpl.PitchY)
+	}
+	last := layoutCell(tmpl, tmpl.Columns*tmpl.Rows-1, "")
+	if right := last.x + last.width; right > tmpl.Width-plainPaperMargin+1e-9 {
+		t.Errorf("last label ends at %v, beyond the margin at %v", right, tmpl.Width-plainPaperMargin)
+	}
+	if bottom := last.y + last.height; bottom > tmpl.Height-plainPaperMargin+1e-9 {
+		t.Errorf("last label ends at %v, beyond the margin at %v", bottom, tmpl.Height-plainPaperMargin)
+	}
+}
+
+func TestLayoutCell_WithoutCaption(t *testing.T) {
+	tmpl := parseTestTemplate(t, url.Values{"template": {"a4"}})
+	c := layoutCell(tmpl, 0, "")
+	checkWithinLabel(t, c)
+	if len(c.lines) != 0 {
+		t.Errorf("lines = %q, want none", c.lines)
+	}
+	// Centered in the label.
+	if dx := (c.qrX - c.x) - (c.x + c.width - c.qrX - c.qrSize); dx > 1e-9 || dx < -1e-9 {
+		t.Errorf("QR Code is not centered horizontally: off by %v", dx)
+	}
+	if dy := (c.qrY - c.y) - (c.y + c.height - c.qrY - c.qrSize); dy > 1e-9 || dy < -1e-9 {
+		t.Errorf("QR Code is not centered vertically: off by %v", dy)
+	}
+}
+
+func TestLayoutCell_CaptionBelow(t *testing.T) {
+	tmpl := parseTestTemplate(t, url.Values{"template": {"a4"}})
+	caption := strings.Repeat("A very long title for a page ", 10)
+	c := layoutCell(tmpl, 0, caption)
+	checkWithinLabel(t, c)
+	if !c.centered {
+		t.Error("caption should be centered below the QR Code on a portrait label")
+	}
+	if len(c.lines) != 2 || !strings.HasSuffix(c.lines[1], "…") {
+		t.Errorf("lines = %q, want 2 lines, truncated with an ellipsis", c.lines)
+	}
+	if c.textY-c.fontSize < c.qrY+c.qrSize {
+		t.Errorf("caption at %v overlaps the QR Code, which ends at %v", c.textY-c.fontSize, c.qrY+c.qrSize)
+	}
+	if withoutCaption := layoutCell(tmpl, 0, ""); c.qrSize >= withoutCaption.qrSize {
+		t.Errorf("qrSize = %v, want less than %v, to make room for the caption", c.qrSize, withoutCaption.qrSize)
+	}
+}
+
+func TestLayoutCell_CaptionBeside(t *testing.T) {
+	tmpl := parseTestTemplate(t, url.Values{"template": {"avery-l7163"}})
+	c := layoutCell(tmpl, 1, "example.com/a/page/with/a/very/long/path/that/must/be/broken/across/lines")
+	checkWithinLabel(t, c)
+	if c.centered {
+		t.Error("caption should be beside the QR Code on a wide label")
+	}
+	if c.textX < c.qrX+c.qrSize {
+		t.Errorf("caption at %v overlaps the QR Code, which ends at %v", c.textX, c.qrX+c.qrSize)
+	}
+	if len(c.lines) < 2 {
+		t.Errorf("lines = %q, want the URL broken across lines", c.lines)
+	}
+	for _, line := range c.lines {
+		if right := c.textX + textWidth(line, c.fontSize); right > c.x+c.width {
+			t.Errorf("line %q ends at %v, beyond the label at %v", line, right, c.x+c.width)
+		}
+	}
+}
+
+func TestCheckCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   url.Values
+		count   int
+		wantErr bool
+	}{
+		{"none", url.Values{}, 0, true},
+		{"max", url.Values{}, maxSheetUrls, false},
+		{"too many", url.Values{}, maxSheetUrls + 1, true},
+		{"titles", url.Values{"caption": {CaptionTitle}}, maxFetchedSheetUrls, false},
+		{"too many titles", url.Values{"caption": {CaptionTitle}}, maxFetchedSheetUrls + 1, true},
+		{"too many favicons", url.Values{"logo": {LogoFavicon}}, maxFetchedSheetUrls + 1, true},
+		{"literal caption", url.Values{"caption": {"Scan me"}}, maxSheetUrls, false},
+		{"single SVG page", url.Values{"format": {"svg"}}, defaultColumns * defaultRows, false},
+		{"too many for an SVG", url.Values{"format": {"svg"}}, defaultColumns*defaultRows + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseSheetOptions(tt.query)
+			if err != nil {
+				t.Fatalf("ParseSheetOptions() error = %v", err)
+			}
+			if err := opts.CheckCount(tt.count); (err != nil) != tt.wantErr {
+				t.Errorf("CheckCount(%d) error = %v, wantErr %v", tt.count, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package qrcode
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/validation"
+	"github.com/jackc/pgx/v5"
+	"github.com/lmittmann/tint"
+)
+
+const (
+	// slugLength gives 62⁸ possible short links, so that they cannot be enumerated.
+	slugLength   = 8
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// maxRefererLength limits the size of referers recorded for each scan.
+	maxRefererLength = 2048
+)
+
+// GET /q/{slug}
+// Records a scan of a tracked QR Code, and redirects to its current target URL.
+func handleShortLink(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	queries := db.New(db.Pool)
+
+	link, err := queries.GetShortLink(ctx, req.PathValue("slug"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Warn("short link not found",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusNotFound)
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		slog.Error("failed to find short link", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The target was authorized when it was set, but its domain may have been blocked since.
+	target, err := neturl.Parse(link.TargetUrl)
+	if err != nil {
+		slog.Error("invalid short link target", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", link.TargetUrl,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if authorized, err := validation.IsAuthorized(ctx, queries, target); err != nil || !authorized {
+		if err == nil {
+			err = errors.New("domain " + target.Hostname() + " not authorized")
+		}
+		slog.Error("short link target not authorized", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", link.TargetUrl,
+			"hostname", target.Hostname(),
+			"status", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	slog.Info("short link scanned",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"url", link.TargetUrl,
+		"hostname", target.Hostname(),
+		"status", http.StatusFound)
+	w.Header().Set("Cache-Control", "no-store") // So that every scan reaches the server.
+	http.Redirect(w, req, link.TargetUrl, http.StatusFound)
+	recordShortLinkScan(link.Slug, req)
+}
+
+// findOrCreateShortLink returns the short link encoded in tracked QR Codes for a URL, creating it if needed.
+// Each URL keeps the same short link even after it is retargeted, so that printed QR Codes remain valid.
+func findOrCreateShortLink(ctx context.Context, q *db.Queries, url string) (db.ShortLink, error) {
+	link, err := q.GetShortLinkByUrl(ctx, url)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return link, err
+	}
+	slug, err := newSlug()
+	if err != nil {
+		return db.ShortLink{}, err
+	}
+	return q.CreateShortLink(ctx, db.CreateShortLinkParams{Slug: slug, Url: url})
+}
+
+// newSlug returns a random identifier for a short link.
+func newSlug() (string, error) {
+	slug := make([]byte, 0, slugLength)
+	b := make([]byte, 1)
+	for len(slug) < slugLength {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		// Skip bytes beyond the largest multiple of the alphabet’s size, so that each character is equally likely.
+		if int(b[0]) < 256-256%len(slugAlphabet) {
+			slug = append(slug, slugAlphabet[int(b[0])%len(slugAlphabet)])
+		}
+	}
+	return string(slug), nil
+}
+
+// shortLinkUrl returns the absolute URL of a short link, as encoded in QR Codes.
+func shortLinkUrl(slug string) string {
+	return strings.TrimRight(conf.Config.QrCodes.ShortLinks.BaseUrl, "/") + "/q/" + slug
+}
+
+// recordShortLinkScan records a scan of a short link, along with the canonical user agent & referer.
+func recordShortLinkScan(slug string, req *http.Request) {
+	canonicalUserAgent := core.GetCanonicalUserAgent(req.UserAgent())
+	params := db.RecordShortLinkScanParams{Slug: slug, CanonicalUserAgent: &canonicalUserAgent}
+	if referer := req.Referer(); referer != "" {
+		referer = referer[:min(len(referer), maxRefererLength)]
+		params.Referer = &referer
+	}
+	queries := db.New(db.Pool)
+	if err := queries.RecordShortLinkScan(context.Background(), params); err != nil {
+		slog.Error("failed to log short link scanned", tint.Err(err))
+	}
+}
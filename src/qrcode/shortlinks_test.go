@@ -0,0 +1,52 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+	for range 1000 {
+		slug, err := newSlug()
+		if err != nil {
+			t.Fatalf("newSlug() error = %v", err)
+		}
+		if len(slug) != slugLength {
+			t.Errorf("newSlug() = %q, want %d characters", slug, slugLength)
+		}
+		if i := strings.IndexFunc(slug, func(r rune) bool { return !strings.ContainsRune(slugAlphabet, r) }); i >= 0 {
+			t.Errorf("newSlug() = %q, contains %q, which is not in the alphabet", slug, slug[i])
+		}
+		if seen[slug] {
+			t.Errorf("newSlug() = %q, repeated", slug)
+		}
+		seen[slug] = true
+	}
+}
+
+func TestShortLinkUrl(t *testing.T) {
+	baseUrl := conf.Config.QrCodes.ShortLinks.BaseUrl
+	t.Cleanup(func() { conf.Config.QrCodes.ShortLinks.BaseUrl = baseUrl })
+
+	tests := []struct {
+		name    string
+		baseUrl string
+		want    string
+	}{
+		{"no trailing slash", "https://butterfly.example.com", "https://butterfly.example.com/q/Ab3dE6gH"},
+		{"trailing slash", "https://butterfly.example.com/", "https://butterfly.example.com/q/Ab3dE6gH"},
+		{"trailing slashes", "https://butterfly.example.com//", "https://butterfly.example.com/q/Ab3dE6gH"},
+		{"path", "https://example.com/butterfly/", "https://example.com/butterfly/q/Ab3dE6gH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf.Config.QrCodes.ShortLinks.BaseUrl = tt.baseUrl
+			if got := shortLinkUrl("Ab3dE6gH"); got != tt.want {
+				t.Errorf("shortLinkUrl() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/validation"
+	"github.com/disintegration/imaging"
+	"github.com/yeqown/go-qrcode/v2"
+	"github.com/yeqown/go-qrcode/writer/standard"
+)
+
+// Style customizes the appearance of a QR Code.
+type Style struct {
+	// ModuleWidth is the width of each module (i.e. each black or white square), in pixels.
+	ModuleWidth int
+	Foreground  color.RGBA
+	Background  color.RGBA
+	// Transparent omits the background, but Background is still used to check the contrast.
+	Transparent bool
+	// QuietZone is the width of the blank margin around the QR Code, in modules.
+	QuietZone int
+	// ErrorCorrection is one of “L” (7% of the QR Code can be restored), “M” (15%), “Q” (25%), or “H” (30%).
+	ErrorCorrection string
+	// Shape of each module: “square” or “circle”.
+	Shape string
+	// Logo is the source of a logo to overlay in the center: [LogoDomain], [LogoFavicon], or empty for none.
+	Logo string
+	// Format of the output: “png”, or the vector formats “svg” & “pdf”, for print.
+	Format string
+	// Track encodes a short link to this server instead of the URL itself, so that scans can be recorded.
+	// Not part of the cache variant, since the encoded text already differs.
+	Track bool
+}
+
+// defaultStyle matches the defaults of the QR Code library, so that unstyled QR Codes look the same as
+// they always have.
+var defaultStyle = Style{
+	ModuleWidth:     20,
+	Foreground:      color.RGBA{A: 0xff},
+	Background:      color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	QuietZone:       2,
+	ErrorCorrection: "Q",
+	Shape:           "square",
+	Format:          "png",
+}
+
+const (
+	maxModuleWidth = 40
+	maxQuietZone   = 16
+	// minContrast is the WCAG contrast ratio required for graphics; lower contrast fails to scan reliably.
+	minContrast = 3.0
+)
+
+var contentTypes = map[string]string{
+	"png": "image/png",
+	"svg": "image/svg+xml",
+	"pdf": "application/pdf",
+}
+
+var errorCorrectionLevels = map[string]qrcode.EncodeOption{
+	"L": qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionLow),
+	"M": qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionMedium),
+	"Q": qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionQuart),
+	"H": qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest),
+}
+
+// parseStyle validates the styling parameters of a request, and returns the resulting style, along with
+// the canonical form of all parameters that differ from the defaults, to be used as the cache variant.
+func parseStyle(query url.Values) (Style, url.Values, error) {
+	style := defaultStyle
+	variant := url.Values{}
+
+	if s := query.Get("size"); s != "" {
+		width, err := strconv.Atoi(s)
+		if err != nil || width < 1 || width > maxModuleWidth {
+			return style, nil, fmt.Errorf("invalid size: %s (must be 1–%d pixels per module)", s, maxModuleWidth)
+		}
+		style.ModuleWidth = width
+	}
+	if s := query.Get("fg"); s != "" {
+		fg, err := validation.ValidateHexColor(s)
+		if err != nil {
+			return style, nil, err
+		}
+		style.Foreground = fg
+	}
+	if s := query.Get("bg"); s != "" {
+		bg, err := validation.ValidateHexColor(s)
+		if err != nil {
+			return style, nil, err
+		}
+		style.Background = bg
+	}
+	if s := query.Get("transparent"); s != "" {
+		transparent, err := strconv.ParseBool(s)
+		if err != nil {
+			return style, nil, errors.New("invalid transparent: " + s)
+		}
+		style.Transparent = transparent
+	}
+	if s := query.Get("margin"); s != "" {
+		margin, err := strconv.Atoi(s)
+		if err != nil || margin < 0 || margin > maxQuietZone {
+			return style, nil, fmt.Errorf("invalid margin: %s (must be 0–%d modules)", s, maxQuietZone)
+		}
+		style.QuietZone = margin
+	}
+	if s := query.Get("ecc"); s != "" {
+		level := strings.ToUpper(s)
+		if _, ok := errorCorrectionLevels[level]; !ok {
+			return style, nil, errors.New("invalid ecc: " + s + " (must be L, M, Q, or H)")
+		}
+		style.ErrorCorrection = level
+	}
+	if s := query.Get("format"); s != "" {
+		if _, ok := contentTypes[s]; !ok {
+			return style, nil, errors.New("invalid format: " + s + " (must be png, svg, or pdf)")
+		}
+		style.Format = s
+	}
+	if s := query.Get("logo"); s != "" {
+		switch s {
+		case LogoDomain, LogoFavicon:
+			style.Logo = s
+			// Logos obscure part of the QR Code, which must then be restored using error correction.
+			style.ErrorCorrection = "H"
+		default:
+			return style, nil, errors.New("invalid logo: " + s + " (must be domain or favicon)")
+		}
+	}
+	if s := query.Get("track"); s != "" {
+		track, err := strconv.ParseBool(s)
+		if err != nil {
+			return style, nil, errors.New("invalid track: " + s)
+		}
+		// Short links must not be derived from request headers, which anyone can set to redirect scans elsewhere.
+		if track && conf.Config.QrCodes.ShortLinks.BaseUrl == "" {
+			return style, nil, errors.New("tracked QR Codes require qr-codes.short_links.base_url to be configured")
+		}
+		style.Track = track
+	}
+	if s := query.Get("shape"); s != "" {
+		switch s {
+		case "square", "circle":
+			style.Shape = s
+		default:
+			return style, nil, errors.New("invalid shape: " + s + " (must be square or circle)")
+		}
+	}
+
+	// Scanners expect dark modules on a light background; inverted or low-contrast codes often fail.
+	if !validation.IsDarker(style.Foreground, style.Background) {
+		return style, nil, errors.New("fg must be darker than bg")
+	}
+	if ratio := validation.ContrastRatio(style.Foreground, style.Background); ratio < minContrast {
+		return style, nil, fmt.Errorf("insufficient contrast between fg & bg: %.1f:1 (must be at least %.0f:1)", ratio, minContrast)
+	}
+
+	if style.ModuleWidth != defaultStyle.ModuleWidth {
+		variant.Set("size", strconv.Itoa(style.ModuleWidth))
+	}
+	if style.Foreground != defaultStyle.Foreground {
+		variant.Set("fg", hexColor(style.Foreground))
+	}
+	if style.Background != defaultStyle.Background {
+		variant.Set("bg", hexColor(style.Background))
+	}
+	if style.Transparent {
+		variant.Set("transparent", "true")
+	}
+	if style.QuietZone != defaultStyle.QuietZone {
+		variant.Set("margin", strconv.Itoa(style.QuietZone))
+	}
+	if style.ErrorCorrection != defaultStyle.ErrorCorrection {
+		variant.Set("ecc", style.ErrorCorrection)
+	}
+	if style.Shape != defaultStyle.Shape {
+		variant.Set("shape", style.Shape)
+	}
+	if style.Logo != "" {
+		variant.Set("logo", style.Logo)
+	}
+	if style.Format != defaultStyle.Format {
+		variant.Set("format", style.Format)
+	}
+	return style, variant, nil
+}
+
+// contentType returns the MIME type of QR Codes in this style.
+func (s Style) contentType() string {
+	return contentTypes[s.Format]
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// encodeOptions returns the options for encoding data into a QR Code in this style.
+func (s Style) encodeOptions() []qrcode.EncodeOption {
+	return []qrcode.EncodeOption{errorCorrectionLevels[s.ErrorCorrection]}
+}
+
+// imageOptions returns the options for drawing a QR Code in this style.
+func (s Style) imageOptions() []standard.ImageOption {
+	opts := []standard.ImageOption{
+		standard.WithQRWidth(uint8(s.ModuleWidth)),
+		standard.WithFgColor(s.Foreground),
+		standard.WithBgColor(s.Background),
+		standard.WithBorderWidth(s.QuietZone * s.ModuleWidth),
+	}
+	if s.Transparent {
+		opts = append(opts, standard.WithBgTransparent())
+	}
+	if s.Shape == "circle" {
+		opts = append(opts, standard.WithCircleShape())
+	}
+	return opts
+}
+
+// logoOptions returns the options for overlaying a logo onto a QR Code of the given dimension (in modules),
+// scaled down to at most 1/[logoSizeDivisor] of its width. Modules behind the logo are left blank.
+func (s Style) logoOptions(logo image.Image, dimension int) []standard.ImageOption {
+	maxSize := dimension * s.ModuleWidth / logoSizeDivisor
+	if b := logo.Bounds(); b.Dx() > maxSize || b.Dy() > maxSize {
+		logo = imaging.Fit(logo, maxSize, maxSize, imaging.Lanczos)
+	}
+	return []standard.ImageOption{
+		standard.WithLogoImage(logo),
+		standard.WithLogoSizeMultiplier(logoSizeDivisor),
+		standard.WithLogoSafeZone(),
+	}
+}
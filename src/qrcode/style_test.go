@@ -0,0 +1,91 @@
+package qrcode
+
+import (
+	"net/url"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+)
+
+func TestParseStyle(t *testing.T) {
+	baseUrl := conf.Config.QrCodes.ShortLinks.BaseUrl
+	t.Cleanup(func() { conf.Config.QrCodes.ShortLinks.BaseUrl = baseUrl })
+	conf.Config.QrCodes.ShortLinks.BaseUrl = ""
+
+	tests := []struct {
+		name        string
+		query       string
+		wantVariant string
+		wantEcc     string
+		wantErr     bool
+	}{
+		{name: "defaults", query: "", wantVariant: "", wantEcc: "Q"},
+		{name: "defaults given explicitly", query: "size=20&fg=000000&bg=ffffff&margin=2&ecc=q&shape=square&format=png", wantVariant: "", wantEcc: "Q"},
+		{name: "lowercase ecc", query: "ecc=h", wantVariant: "ecc=H", wantEcc: "H"},
+		{name: "colors normalized", query: "fg=%23123&bg=FFF", wantVariant: "fg=112233", wantEcc: "Q"},
+		{name: "all options", query: "size=8&fg=1a237e&bg=fff8e1&transparent=true&margin=0&ecc=L&shape=circle&format=svg",
+			wantVariant: "bg=fff8e1&ecc=L&fg=1a237e&format=svg&margin=0&shape=circle&size=8&transparent=true", wantEcc: "L"},
+		{name: "logo forces ecc H", query: "logo=domain&ecc=L", wantVariant: "ecc=H&logo=domain", wantEcc: "H"},
+		{name: "favicon", query: "logo=favicon", wantVariant: "ecc=H&logo=favicon", wantEcc: "H"},
+		{name: "untracked", query: "track=false", wantVariant: "", wantEcc: "Q"},
+
+		{name: "size too small", query: "size=0", wantErr: true},
+		{name: "size too large", query: "size=41", wantErr: true},
+		{name: "size largest", query: "size=40", wantVariant: "size=40", wantEcc: "Q"},
+		{name: "size not a number", query: "size=big", wantErr: true},
+		{name: "margin negative", query: "margin=-1", wantErr: true},
+		{name: "margin too large", query: "margin=17", wantErr: true},
+		{name: "margin largest", query: "margin=16", wantVariant: "margin=16", wantEcc: "Q"},
+		{name: "invalid ecc", query: "ecc=X", wantErr: true},
+		{name: "invalid format", query: "format=gif", wantErr: true},
+		{name: "invalid logo", query: "logo=other", wantErr: true},
+		{name: "invalid shape", query: "shape=star", wantErr: true},
+		{name: "invalid color", query: "fg=black", wantErr: true},
+		{name: "invalid transparent", query: "transparent=maybe", wantErr: true},
+		{name: "tracked without base url", query: "track=true", wantErr: true},
+
+		{name: "inverted", query: "fg=ffffff&bg=000000", wantErr: true},
+		{name: "same colors", query: "fg=777777&bg=777777", wantErr: true},
+		{name: "low contrast", query: "fg=999999&bg=ffffff", wantErr: true},
+		{name: "sufficient contrast", query: "fg=767676&bg=ffffff", wantVariant: "fg=767676", wantEcc: "Q"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error = %v", tt.query, err)
+			}
+			style, variant, err := parseStyle(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStyle(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := variant.Encode(); got != tt.wantVariant {
+				t.Errorf("parseStyle(%q) variant = %q, want %q", tt.query, got, tt.wantVariant)
+			}
+			if style.ErrorCorrection != tt.wantEcc {
+				t.Errorf("parseStyle(%q) ErrorCorrection = %q, want %q", tt.query, style.ErrorCorrection, tt.wantEcc)
+			}
+		})
+	}
+}
+
+func TestParseStyle_Tracked(t *testing.T) {
+	baseUrl := conf.Config.QrCodes.ShortLinks.BaseUrl
+	t.Cleanup(func() { conf.Config.QrCodes.ShortLinks.BaseUrl = baseUrl })
+	conf.Config.QrCodes.ShortLinks.BaseUrl = "https://butterfly.example.com"
+
+	style, variant, err := parseStyle(url.Values{"track": {"true"}})
+	if err != nil {
+		t.Fatalf("parseStyle() error = %v", err)
+	}
+	if !style.Track {
+		t.Error("parseStyle() Track = false, want true")
+	}
+	// Tracked QR Codes encode a different text, so they need not differ in their variant.
+	if len(variant) != 0 {
+		t.Errorf("parseStyle() variant = %q, want none", variant.Encode())
+	}
+}
@@ -0,0 +1,365 @@
+package qrcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/yeqown/go-qrcode/v2"
+)
+
+// pointsPerPixel converts pixels to PDF points, at the 96 DPI used by CSS, so that a PDF prints at the
+// same size as the equivalent PNG would.
+const pointsPerPixel = 0.75
+
+// matrixWriter captures the modules of a QR Code, so that they can be drawn as vector graphics.
+type matrixWriter struct {
+	bitmap [][]bool
+}
+
+func (m *matrixWriter) Write(mat qrcode.Matrix) error {
+	m.bitmap = mat.Bitmap()
+	return nil
+}
+
+func (m *matrixWriter) Close() error {
+	return nil
+}
+
+// vectorQrCode is the layout of a QR Code to be drawn as vector graphics. All coordinates are in modules,
+// including the quiet zone.
+type vectorQrCode struct {
+	style   Style
+	modules [][]bool
+	size    int // Width & height, including the quiet zone.
+
+	logo image.Image // Nil if none.
+	// Position & size of the logo, centered & scaled down the same way as in PNGs.
+	logoX, logoY, logoWidth, logoHeight float64
+}
+
+func newVectorQrCode(qrc *qrcode.QRCode, style Style, logo image.Image) (vectorQrCode, error) {
+	var m matrixWriter
+	if err := qrc.Save(&m); err != nil {
+		return vectorQrCode{}, err
+	}
+	v := vectorQrCode{style: style, modules: m.bitmap, size: len(m.bitmap) + 2*style.QuietZone}
+	if logo != nil {
+		b := logo.Bounds()
+		maxSize := float64(len(m.bitmap)) / logoSizeDivisor
+		scale := min(1/float64(style.ModuleWidth), maxSize/float64(b.Dx()), maxSize/float64(b.Dy()))
+		v.logo = logo
+		v.logoWidth, v.logoHeight = float64(b.Dx())*scale, float64(b.Dy())*scale
+		v.logoX, v.logoY = (float64(v.size)-v.logoWidth)/2, (float64(v.size)-v.logoHeight)/2
+	}
+	return v, nil
+}
+
+// eachRun calls fn for each horizontal run of adjacent dark modules, excluding those behind the logo.
+// Circular modules are never merged into runs.
+func (v vectorQrCode) eachRun(fn func(x, y, length int)) {
+	for y, row := range v.modules {
+		for x := 0; x < len(row); x++ {
+			if !v.isDrawn(x, y) {
+				continue
+			}
+			length := 1
+			for v.style.Shape != "circle" && x+length < len(row) && v.isDrawn(x+length, y) {
+				length++
+			}
+			fn(x+v.style.QuietZone, y+v.style.QuietZone, length)
+			x += length - 1
+		}
+	}
+}
+
+func (v vectorQrCode) isDrawn(x, y int) bool {
+	if !v.modules[y][x] {
+		return false
+	}
+	if v.logo == nil {
+		return true
+	}
+	left, top := float64(x+v.style.QuietZone), float64(y+v.style.QuietZone)
+	return !(left+1 > v.logoX && left < v.logoX+v.logoWidth && top+1 > v.logoY && top < v.logoY+v.logoHeight)
+}
+
+// logoPng returns the logo, encoded as a PNG.
+func (v vectorQrCode) logoPng() ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, v.logo)
+	return buf.Bytes(), err
+}
+
+// svg draws the QR Code as a standalone SVG, sized in pixels like the equivalent PNG.
+func (v vectorQrCode) svg() ([]byte, error) {
+	var buf bytes.Buffer
+	pixels := v.size * v.style.ModuleWidth
+	attrs := fmt.Sprintf(`xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"`, pixels, pixels)
+	if err := v.writeSvg(&buf, attrs); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// writeSvg writes the QR Code as an <svg> element with additional attributes, so that it can also be nested
+// within a larger SVG. The modules are drawn as a single path.
+func (v vectorQrCode) writeSvg(buf *bytes.Buffer, attrs string) error {
+	fmt.Fprintf(buf, `<svg %s viewBox="0 0 %d %d"`, attrs, v.size, v.size)
+	if v.style.Shape != "circle" {
+		buf.WriteString(` shape-rendering="crispEdges"`)
+	}
+	buf.WriteString(">")
+	if !v.style.Transparent {
+		fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="#%s"/>`, hexColor(v.style.Background))
+	}
+
+	fmt.Fprintf(buf, `<path fill="#%s" d="`, hexColor(v.style.Foreground))
+	v.eachRun(func(x, y, length int) {
+		if v.style.Shape == "circle" {
+			fmt.Fprintf(buf, "M%d %d.5a.5.5 0 1 0 1 0a.5.5 0 1 0-1 0z", x, y)
+		} else {
+			fmt.Fprintf(buf, "M%d %dh%dv1h-%dz", x, y, length, length)
+		}
+	})
+	buf.WriteString(`"/>`)
+
+	if v.logo != nil {
+		logo, err := v.logoPng()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, `<image x="%s" y="%s" width="%s" height="%s" href="data:image/png;base64,%s"/>`,
+			formatFloat(v.logoX), formatFloat(v.logoY), formatFloat(v.logoWidth), formatFloat(v.logoHeight),
+			base64.StdEncoding.EncodeToString(logo))
+	}
+	buf.WriteString("</svg>")
+	return nil
+}
+
+// pdf draws the QR Code on a single page of the same size, using vector graphics for the modules.
+func (v vectorQrCode) pdf() ([]byte, error) {
+	page := float64(v.size*v.style.ModuleWidth) * pointsPerPixel
+	doc := newPdfWriter()
+	var content bytes.Buffer
+	pdfFlipY(&content, page)
+	if err := v.writePdf(&content, doc, 0, 0, page); err != nil {
+		return nil, err
+	}
+	if err := doc.addPage(page, page, content.Bytes()); err != nil {
+		return nil, err
+	}
+	return doc.bytes(), nil
+}
+
+// writePdf appends operators that draw the QR Code into a square of the given size, at (x, y) from the top
+// left of a page whose y-axis has been flipped using [pdfFlipY].
+func (v vectorQrCode) writePdf(content *bytes.Buffer, doc *pdfWriter, x, y, size float64) error {
+	// Switch to coordinates in modules, like in SVGs.
+	scale := size / float64(v.size)
+	fmt.Fprintf(content, "q %s 0 0 %s %s %s cm\n", formatFloat(scale), formatFloat(scale), formatFloat(x), formatFloat(y))
+	if !v.style.Transparent {
+		fmt.Fprintf(content, "%s rg 0 0 %d %d re f\n", pdfColor(v.style.Background), v.size, v.size)
+	}
+	fmt.Fprintf(content, "%s rg\n", pdfColor(v.style.Foreground))
+	v.eachRun(func(x, y, length int) {
+		if v.style.Shape == "circle" {
+			pdfCircle(content, float64(x)+0.5, float64(y)+0.5, 0.5)
+		} else {
+			fmt.Fprintf(content, "%d %d %d 1 re\n", x, y, length)
+		}
+	})
+	content.WriteString("f\n")
+
+	if v.logo != nil {
+		name, err := doc.addImage(v.logo)
+		if err != nil {
+			return err
+		}
+		// Images are drawn into a unit square, with their first row at the top, so flip the y-axis back.
+		fmt.Fprintf(content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+			formatFloat(v.logoWidth), formatFloat(-v.logoHeight), formatFloat(v.logoX), formatFloat(v.logoY+v.logoHeight), name)
+	}
+	content.WriteString("Q\n")
+	return nil
+}
+
+// pdfWriter assembles a PDF document, whose pages share a single dictionary of resources.
+type pdfWriter struct {
+	objects [][]byte // Object n is at index n-1.
+	pages   []int
+	images  []int
+	names   map[image.Image]string // Of images already embedded, so that logos repeated on a sheet are only embedded once.
+	font    bool
+}
+
+const (
+	pdfCatalog = iota + 1
+	pdfPages
+	pdfResources
+	pdfFont
+)
+
+func newPdfWriter() *pdfWriter {
+	doc := &pdfWriter{names: map[image.Image]string{}}
+	for range pdfFont {
+		doc.add(nil) // Written by [pdfWriter.bytes], once all pages & resources are known.
+	}
+	return doc
+}
+
+// add appends an object, and returns its number.
+func (doc *pdfWriter) add(object []byte) int {
+	doc.objects = append(doc.objects, object)
+	return len(doc.objects)
+}
+
+// addPage appends a page of the given size, in points, drawn by a content stream.
+func (doc *pdfWriter) addPage(width, height float64, content []byte) error {
+	stream, err := pdfStream("", content)
+	if err != nil {
+		return err
+	}
+	contents := doc.add(stream)
+	doc.pages = append(doc.pages, doc.add(fmt.Appendf(nil, "<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources %d 0 R >>",
+		pdfPages, formatFloat(width), formatFloat(height), contents, pdfResources)))
+	return nil
+}
+
+// addImage embeds an image, with its alpha channel as a soft mask, and returns its name as a resource.
+// Images that have already been embedded are reused.
+func (doc *pdfWriter) addImage(img image.Image) (string, error) {
+	if name, ok := doc.names[img]; ok {
+		return name, nil
+	}
+	rgb, alpha := pdfImageData(img)
+	b := img.Bounds()
+	alphaStream, err := pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", b.Dx(), b.Dy()), alpha)
+	if err != nil {
+		return "", err
+	}
+	mask := doc.add(alphaStream)
+	rgbStream, err := pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /SMask %d 0 R", b.Dx(), b.Dy(), mask), rgb)
+	if err != nil {
+		return "", err
+	}
+	doc.images = append(doc.images, doc.add(rgbStream))
+	name := fmt.Sprintf("Im%d", len(doc.images))
+	doc.names[img] = name
+	return name, nil
+}
+
+// useFont returns the name of the standard Helvetica font as a resource, for text drawn using [pdfText].
+func (doc *pdfWriter) useFont() string {
+	doc.font = true
+	return "F1"
+}
+
+// bytes returns the complete PDF document.
+func (doc *pdfWriter) bytes() []byte {
+	doc.objects[pdfCatalog-1] = fmt.Appendf(nil, "<< /Type /Catalog /Pages %d 0 R >>", pdfPages)
+
+	kids := make([]string, len(doc.pages))
+	for i, page := range doc.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	doc.objects[pdfPages-1] = fmt.Appendf(nil, "<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(doc.pages))
+
+	resources := "<<"
+	if doc.font {
+		resources += fmt.Sprintf(" /Font << /F1 %d 0 R >>", pdfFont)
+	}
+	if len(doc.images) > 0 {
+		resources += " /XObject <<"
+		for i, image := range doc.images {
+			resources += fmt.Sprintf(" /Im%d %d 0 R", i+1, image)
+		}
+		resources += " >>"
+	}
+	doc.objects[pdfResources-1] = []byte(resources + " >>")
+	doc.objects[pdfFont-1] = []byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(doc.objects))
+	for i, object := range doc.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(object)
+		buf.WriteString("\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(doc.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(doc.objects)+1, pdfCatalog, xref)
+	return buf.Bytes()
+}
+
+// pdfFlipY flips the y-axis of a page of the given height, so that coordinates are from the top left, as in
+// SVGs. Text must then be flipped back, as in [pdfText].
+func pdfFlipY(content *bytes.Buffer, height float64) {
+	fmt.Fprintf(content, "1 0 0 -1 0 %s cm\n", formatFloat(height))
+}
+
+// pdfStream returns a stream object, compressed using Flate, with additional entries in its dictionary.
+func pdfStream(dict string, data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if dict != "" {
+		dict += " "
+	}
+	stream := fmt.Appendf(nil, "<< %s/Filter /FlateDecode /Length %d >>\nstream\n", dict, compressed.Len())
+	stream = append(stream, compressed.Bytes()...)
+	return append(stream, "\nendstream"...), nil
+}
+
+// pdfImageData returns the RGB & alpha channels of an image, as expected by PDF image streams.
+func pdfImageData(img image.Image) (rgb, alpha []byte) {
+	b := img.Bounds()
+	rgb = make([]byte, 0, b.Dx()*b.Dy()*3)
+	alpha = make([]byte, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+		}
+	}
+	return rgb, alpha
+}
+
+// pdfCircle appends a circle, approximated by four Bézier curves, to the current path.
+func pdfCircle(buf *bytes.Buffer, cx, cy, r float64) {
+	k := r * 0.5523 // Distance of control points from the ends of each quarter-circle.
+	f := formatFloat
+	fmt.Fprintf(buf, "%s %s m ", f(cx+r), f(cy))
+	fmt.Fprintf(buf, "%s %s %s %s %s %s c ", f(cx+r), f(cy+k), f(cx+k), f(cy+r), f(cx), f(cy+r))
+	fmt.Fprintf(buf, "%s %s %s %s %s %s c ", f(cx-k), f(cy+r), f(cx-r), f(cy+k), f(cx-r), f(cy))
+	fmt.Fprintf(buf, "%s %s %s %s %s %s c ", f(cx-r), f(cy-k), f(cx-k), f(cy-r), f(cx), f(cy-r))
+	fmt.Fprintf(buf, "%s %s %s %s %s %s c\n", f(cx+k), f(cy-r), f(cx+r), f(cy-k), f(cx+r), f(cy))
+}
+
+func pdfColor(c color.RGBA) string {
+	return fmt.Sprintf("%s %s %s", formatFloat(float64(c.R)/255), formatFloat(float64(c.G)/255), formatFloat(float64(c.B)/255))
+}
+
+// formatFloat formats a coordinate compactly, with at most 4 decimal places.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,185 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yeqown/go-qrcode/v2"
+)
+
+// checkPdfStructure verifies the header, trailer & cross-reference table of a PDF, i.e. that each object
+// starts exactly at the offset recorded for it, and returns the number of objects.
+func checkPdfStructure(t *testing.T, pdf []byte) int {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header: %q", pdf[:min(len(pdf), 16)])
+	}
+	if !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("missing %%%%EOF: %q", pdf[max(0, len(pdf)-16):])
+	}
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d does not point to the xref table: %q", xref, pdf[xref:min(len(pdf), xref+16)])
+	}
+
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	count, err := strconv.Atoi(strings.TrimPrefix(lines[1], "0 "))
+	if err != nil {
+		t.Fatalf("invalid xref subsection: %q", lines[1])
+	}
+	if lines[2] != "0000000000 65535 f " {
+		t.Errorf("xref entry 0 = %q, want the free list head", lines[2])
+	}
+	for n := 1; n < count; n++ {
+		entry := lines[2+n]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("xref entry %d = %q, want 20 bytes in the form “oooooooooo 00000 n ”", n, entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		if want := strconv.Itoa(n) + " 0 obj\n"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points to %q, want %q", n, pdf[offset:min(len(pdf), offset+len(want))], want)
+		}
+	}
+	if want := "trailer\n<< /Size " + strconv.Itoa(count) + " /Root 1 0 R >>"; !strings.Contains(string(pdf[xref:]), want) {
+		t.Errorf("trailer does not contain %q", want)
+	}
+	return count - 1
+}
+
+func newTestVectorQrCode(t *testing.T, style Style, logo image.Image) vectorQrCode {
+	t.Helper()
+	qrc, err := qrcode.NewWith("https://example.com/", style.encodeOptions()...)
+	if err != nil {
+		t.Fatalf("qrcode.NewWith() error = %v", err)
+	}
+	v, err := newVectorQrCode(qrc, style, logo)
+	if err != nil {
+		t.Fatalf("newVectorQrCode() error = %v", err)
+	}
+	return v
+}
+
+func testLogo() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	return img
+}
+
+func TestVectorQrCode_Pdf(t *testing.T) {
+	pdf, err := newTestVectorQrCode(t, defaultStyle, nil).pdf()
+	if err != nil {
+		t.Fatalf("pdf() error = %v", err)
+	}
+	// Catalog, pages, resources, font, and a page with its contents.
+	if got := checkPdfStructure(t, pdf); got != 6 {
+		t.Errorf("pdf() has %d objects, want 6", got)
+	}
+	if !bytes.Contains(pdf, []byte("/Type /Page ")) || !bytes.Contains(pdf, []byte("/Count 1 >>")) {
+		t.Error("pdf() does not contain a single page")
+	}
+	if bytes.Contains(pdf, []byte("/XObject")) {
+		t.Error("pdf() without a logo should not contain images")
+	}
+}
+
+func TestPdfWriter_ImagesAreEmbeddedOnce(t *testing.T) {
+	logo, other := testLogo(), testLogo()
+	doc := newPdfWriter()
+	var content bytes.Buffer
+	names := map[string]bool{}
+	for _, img := range []image.Image{logo, logo, other, logo} {
+		name, err := doc.addImage(img)
+		if err != nil {
+			t.Fatalf("addImage() error = %v", err)
+		}
+		names[name] = true
+		content.WriteString("/" + name + " Do\n")
+	}
+	if len(names) != 2 {
+		t.Errorf("addImage() returned %d names, want 2 for 2 distinct images", len(names))
+	}
+	if err := doc.addPage(100, 100, content.Bytes()); err != nil {
+		t.Fatalf("addPage() error = %v", err)
+	}
+
+	pdf := doc.bytes()
+	checkPdfStructure(t, pdf)
+	// Each image is embedded with a soft mask for its alpha channel.
+	if got := bytes.Count(pdf, []byte("/Subtype /Image")); got != 4 {
+		t.Errorf("PDF contains %d image streams, want 4", got)
+	}
+	if !bytes.Contains(pdf, []byte("/XObject << /Im1 ")) || !bytes.Contains(pdf, []byte(" /Im2 ")) {
+		t.Error("resources do not list both images")
+	}
+}
+
+func TestVectorQrCode_Svg(t *testing.T) {
+	v := newTestVectorQrCode(t, defaultStyle, nil)
+	svg, err := v.svg()
+	if err != nil {
+		t.Fatalf("svg() error = %v", err)
+	}
+	s := string(svg)
+	pixels := strconv.Itoa(v.size * defaultStyle.ModuleWidth)
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="` + pixels + `" height="` + pixels + `"`,
+		`viewBox="0 0 ` + strconv.Itoa(v.size) + " " + strconv.Itoa(v.size) + `"`,
+		`shape-rendering="crispEdges"`,
+		`<rect width="100%" height="100%" fill="#ffffff"/>`,
+		`<path fill="#000000" d="M2 2h7v1h-7z`, // The top row of the finder pattern, within the quiet zone.
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("svg() does not contain %q", want)
+		}
+	}
+	if !strings.HasSuffix(s, "</svg>\n") {
+		t.Errorf("svg() is not terminated: %q", s[max(0, len(s)-16):])
+	}
+
+	style := defaultStyle
+	style.Shape, style.Transparent = "circle", true
+	style.Foreground = color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}
+	svg, err = newTestVectorQrCode(t, style, testLogo()).svg()
+	if err != nil {
+		t.Fatalf("svg() error = %v", err)
+	}
+	s = string(svg)
+	if strings.Contains(s, "crispEdges") || strings.Contains(s, "<rect") {
+		t.Error("svg() with circles on a transparent background should not use crispEdges, or draw a background")
+	}
+	if !strings.Contains(s, `<path fill="#1a237e" d="M2 2.5a.5.5 0 1 0 1 0`) {
+		t.Error("svg() does not draw circular modules")
+	}
+	if !strings.Contains(s, `<image x="`) || !strings.Contains(s, `href="data:image/png;base64,`) {
+		t.Error("svg() does not embed the logo")
+	}
+}
+
+func TestEachRun_SkipsModulesBehindLogo(t *testing.T) {
+	v := newTestVectorQrCode(t, defaultStyle, testLogo())
+	var drawn int
+	v.eachRun(func(x, y, length int) {
+		for i := range length {
+			left, top := float64(x+i), float64(y)
+			if left+1 > v.logoX && left < v.logoX+v.logoWidth && top+1 > v.logoY && top < v.logoY+v.logoHeight {
+				t.Errorf("module (%d, %d) is drawn behind the logo", x+i, y)
+			}
+		}
+		drawn += length
+	})
+	if drawn == 0 {
+		t.Error("eachRun() did not draw any modules")
+	}
+}
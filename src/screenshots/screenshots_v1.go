@@ -0,0 +1,216 @@
+package screenshots
+
+// Screenshots of entire Web pages (instead of a single element), e.g. for link directories.
+// Shares the cache, the Chrome queue, and domain authorization with Link Previews.
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"butterfly.chimbori.dev/linkpreviews"
+	"butterfly.chimbori.dev/validation"
+	"github.com/lmittmann/tint"
+)
+
+func Init(mux *http.ServeMux) {
+	mux.HandleFunc("GET /screenshots/v1", handleScreenshot)
+}
+
+// GET /screenshots/v1?url={url}&mode={viewport|fullpage|clip}&x={x}&y={y}&w={w}&h={h}
+// Validates the URL, checks if it’s cached, takes a screenshot of the page, and serves it.
+func handleScreenshot(w http.ResponseWriter, req *http.Request) {
+	slog.Debug("handleScreenshot", "url", req.Method+" "+req.URL.String())
+
+	reqUrl := req.URL.Query().Get("url")
+	userAgent := req.Header.Get("User-Agent")
+	queries := db.New(db.Pool)
+
+	url, fetchUrl, hostname, err := validation.ValidateUrl(req.Context(), queries, reqUrl)
+	if err != nil {
+		slog.Error("URL validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", reqUrl,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	capture, variant, err := parseCapture(req.URL.Query())
+	if err != nil {
+		slog.Error("capture validation failed", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := linkpreviews.CacheKey(url, variant)
+
+	var cached []byte
+
+	// Only check cache if enabled
+	if *conf.Config.LinkPreviews.Cache.Enabled {
+		cached, err = linkpreviews.Cache.Find(cacheKey)
+		if err != nil {
+			err = fmt.Errorf("url: %s, %w", url, err)
+			slog.Error("error during cache lookup", tint.Err(err),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"url", url,
+				"hostname", hostname,
+				"user-agent", userAgent,
+				"status", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if cached != nil {
+		slog.Info("cached screenshot served",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusOK)
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+		w.Write(cached)
+		return
+	}
+
+	creds, err := credentials.Find(req.Context(), queries, hostname)
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error loading credentials", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), conf.Config.LinkPreviews.Screenshot.Timeout)
+	defer cancel()
+	diagnostics := &core.Diagnostics{}
+	screenshot, err := core.TakePageScreenshot(ctx, fetchUrl, capture, core.WithCredentials(creds), core.WithDiagnostics(diagnostics))
+	if err != nil {
+		err = fmt.Errorf("url: %s, %w", url, err)
+		slog.Error("error taking screenshot", tint.Err(err),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"url", url,
+			"hostname", hostname,
+			"user-agent", userAgent,
+			"details", diagnostics.Summary(),
+			"status", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Serve the screenshot immediately after generation, without waiting for compression.
+	slog.Info("new screenshot generated",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"url", url,
+		"hostname", hostname,
+		"user-agent", userAgent,
+		"status", http.StatusOK)
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "max-age=31536000, immutable") // 1 year
+	w.Write(screenshot)
+
+	// If cache is enabled, compress the generated screenshot and cache it, but without holding up the HTTP request
+	if *conf.Config.LinkPreviews.Cache.Enabled {
+		var dataToWrite []byte
+		core.Background.Submit("screenshot: "+url, func() error {
+			// Compress only once, even if writing to the cache has to be retried.
+			if dataToWrite == nil {
+				dataToWrite = screenshot
+				compressed, err := core.CompressPNG(screenshot)
+				if err == nil {
+					dataToWrite = compressed
+					slog.Info("PNG compressed", "from", len(screenshot), "to", len(compressed), "%", (len(compressed) * 100 / len(screenshot)))
+				} else {
+					slog.Error("PNG compression failed", tint.Err(err), "url", url)
+				}
+			}
+
+			if err := linkpreviews.WriteCached(context.Background(), url, cacheKey, dataToWrite); err != nil {
+				err = fmt.Errorf("error writing to cache: %s, %w", url, err)
+				slog.Error("error writing to cache", tint.Err(err),
+					"method", req.Method,
+					"path", req.URL.Path,
+					"url", url,
+					"hostname", hostname,
+					"user-agent", userAgent,
+					"status", http.StatusInternalServerError)
+				return err
+			}
+			return nil
+		})
+	}
+}
+
+// parseCapture validates the capture mode & clip parameters, and returns the [core.Capture] to use,
+// along with the subset of parameters that identifies this variant in the cache.
+func parseCapture(query neturl.Values) (core.Capture, neturl.Values, error) {
+	capture := core.Capture{
+		Mode:           core.CaptureMode(query.Get("mode")),
+		ViewportWidth:  conf.Config.Screenshots.Viewport.Width,
+		ViewportHeight: conf.Config.Screenshots.Viewport.Height,
+		MaxHeight:      conf.Config.Screenshots.MaxHeight,
+	}
+	if capture.Mode == "" {
+		capture.Mode = core.CaptureViewport
+	}
+	variant := neturl.Values{"mode": {string(capture.Mode)}}
+
+	switch capture.Mode {
+	case core.CaptureViewport, core.CaptureFullPage:
+		return capture, variant, nil
+
+	case core.CaptureClip:
+		var coords [4]int
+		for i, name := range []string{"x", "y", "w", "h"} {
+			v, err := strconv.Atoi(query.Get(name))
+			if err != nil {
+				return capture, nil, fmt.Errorf("invalid or missing clip parameter: %s", name)
+			}
+			coords[i] = v
+			variant.Set(name, strconv.Itoa(v))
+		}
+		x, y, width, height := coords[0], coords[1], coords[2], coords[3]
+		if x < 0 || y < 0 || width <= 0 || height <= 0 {
+			return capture, nil, fmt.Errorf("clip must have non-negative x, y and positive w, h")
+		}
+		// Compare each value on its own, instead of adding them, which could overflow.
+		if x >= capture.ViewportWidth || width > capture.ViewportWidth-x ||
+			y >= capture.MaxHeight || height > capture.MaxHeight-y {
+			return capture, nil, fmt.Errorf("clip must lie within %dx%d", capture.ViewportWidth, capture.MaxHeight)
+		}
+		capture.Clip = image.Rect(x, y, x+width, y+height)
+		return capture, variant, nil
+
+	default:
+		return capture, nil, fmt.Errorf("unsupported mode: %s", capture.Mode)
+	}
+}
@@ -0,0 +1,61 @@
+package screenshots
+
+import (
+	"image"
+	neturl "net/url"
+	"testing"
+
+	"butterfly.chimbori.dev/conf"
+	"butterfly.chimbori.dev/core"
+)
+
+func TestParseCapture(t *testing.T) {
+	screenshots := conf.Config.Screenshots
+	t.Cleanup(func() { conf.Config.Screenshots = screenshots })
+	conf.Config.Screenshots.Viewport.Width = 1280
+	conf.Config.Screenshots.Viewport.Height = 800
+	conf.Config.Screenshots.MaxHeight = 10000
+
+	tests := []struct {
+		query       string
+		wantMode    core.CaptureMode
+		wantClip    image.Rectangle
+		wantVariant string
+		wantErr     bool
+	}{
+		{"", core.CaptureViewport, image.Rectangle{}, "mode=viewport", false},
+		{"mode=fullpage", core.CaptureFullPage, image.Rectangle{}, "mode=fullpage", false},
+		{"mode=clip&x=10&y=20&w=300&h=200", core.CaptureClip, image.Rect(10, 20, 310, 220), "h=200&mode=clip&w=300&x=10&y=20", false},
+		{"mode=clip&x=0&y=0&w=1280&h=10000", core.CaptureClip, image.Rect(0, 0, 1280, 10000), "h=10000&mode=clip&w=1280&x=0&y=0", false},
+		{"mode=clip&x=%2B05&y=0&w=10&h=10", core.CaptureClip, image.Rect(5, 0, 15, 10), "h=10&mode=clip&w=10&x=5&y=0", false},
+		{"mode=clip&x=0&y=0&w=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=a&y=0&w=10&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=-1&y=0&w=10&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=0&y=0&w=0&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=1&y=0&w=1280&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=1280&y=0&w=1&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=0&y=1&w=10&h=10000", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=1&y=0&w=9223372036854775807&h=10", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=0&y=1&w=10&h=9223372036854775807", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=9223372036854775807&y=0&w=1&h=1", "", image.Rectangle{}, "", true},
+		{"mode=clip&x=0&y=0&w=99999999999999999999&h=10", "", image.Rectangle{}, "", true},
+		{"mode=element", "", image.Rectangle{}, "", true},
+	}
+	for _, tt := range tests {
+		query, _ := neturl.ParseQuery(tt.query)
+		capture, variant, err := parseCapture(query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCapture(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if capture.Mode != tt.wantMode || capture.Clip != tt.wantClip {
+			t.Errorf("parseCapture(%q) = %s %v, want %s %v", tt.query, capture.Mode, capture.Clip, tt.wantMode, tt.wantClip)
+		}
+		if got := variant.Encode(); got != tt.wantVariant {
+			t.Errorf("parseCapture(%q) variant = %q, want %q", tt.query, got, tt.wantVariant)
+		}
+	}
+}
@@ -93,6 +93,7 @@ func (h *DBHandler) writeToDatabase(ctx context.Context, r slog.Record) {
 		url           *string
 		hostname      *string
 		userAgent     *string
+		details       *string
 	)
 
 	r.Attrs(func(a slog.Attr) bool {
@@ -121,6 +122,10 @@ func (h *DBHandler) writeToDatabase(ctx context.Context, r slog.Record) {
 			if s := a.Value.String(); s != "" {
 				userAgent = &s
 			}
+		case "details":
+			if s := a.Value.String(); s != "" {
+				details = &s
+			}
 		case "status":
 			if v := a.Value.Any(); v != nil {
 				var i int32
@@ -150,6 +155,7 @@ func (h *DBHandler) writeToDatabase(ctx context.Context, r slog.Record) {
 		UserAgent:     userAgent,
 		Message:       &message,
 		Err:           slogErr,
+		Details:       details,
 	})
 	// If we fail to write to the database, log it to the parent handler,
 	// but don’t propagate the error to avoid infinite loops.
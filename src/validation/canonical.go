@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"butterfly.chimbori.dev/core"
+	"butterfly.chimbori.dev/credentials"
+	"butterfly.chimbori.dev/db"
+	"github.com/lmittmann/tint"
+)
+
+const (
+	// canonicalLinkTTL is how long the canonical link of a page is remembered, so that pages are not
+	// fetched for every request, including those served from the cache.
+	canonicalLinkTTL = 1 * time.Hour
+	// canonicalLinkTimeout bounds how long fetching a page for its canonical link may hold up a request.
+	canonicalLinkTimeout = 10 * time.Second
+	// maxCanonicalLinks bounds memory use; all links are forgotten once there are more than this.
+	maxCanonicalLinks = 10000
+)
+
+var canonicalLinks = struct {
+	sync.Mutex
+	links map[string]canonicalLink
+}{links: map[string]canonicalLink{}}
+
+type canonicalLink struct {
+	url     *url.URL // nil if the page has no usable canonical link.
+	expires time.Time
+}
+
+// followCanonicalLink returns the canonicalized URL from the page’s <link rel="canonical">, if it is on
+// an authorized domain, or u itself otherwise. Verdicts are only remembered if the page could be fetched,
+// so that a transient failure does not split a page across two URLs for a whole canonicalLinkTTL.
+func followCanonicalLink(ctx context.Context, q *db.Queries, u *url.URL) *url.URL {
+	key := u.String()
+	canonicalLinks.Lock()
+	link, ok := canonicalLinks.links[key]
+	canonicalLinks.Unlock()
+	if !ok || time.Now().After(link.expires) {
+		found, err := findCanonicalLink(ctx, q, u)
+		if err != nil {
+			slog.Warn("failed to fetch canonical link", tint.Err(err), "url", key)
+			return u
+		}
+		link = canonicalLink{url: found, expires: time.Now().Add(canonicalLinkTTL)}
+		canonicalLinks.Lock()
+		if len(canonicalLinks.links) >= maxCanonicalLinks {
+			clear(canonicalLinks.links)
+		}
+		canonicalLinks.links[key] = link
+		canonicalLinks.Unlock()
+	}
+	if link.url == nil {
+		return u
+	}
+	return link.url
+}
+
+// findCanonicalLink fetches a page, using the credentials of its domain, if any, and returns its
+// canonical link, or nil if it has no usable one. Returns an error if the page could not be fetched.
+func findCanonicalLink(ctx context.Context, q *db.Queries, u *url.URL) (*url.URL, error) {
+	// The verdict is shared by all requests for this page, so it must not depend on whether the client
+	// that happened to trigger the fetch is still connected.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), canonicalLinkTimeout)
+	defer cancel()
+
+	creds, err := credentials.Find(ctx, q, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	href, err := core.FetchCanonicalLink(ctx, u.String(), core.WithCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	if href == "" {
+		return nil, nil
+	}
+	canonical, err := Canonicalize(href)
+	if err != nil || (canonical.Scheme != "http" && canonical.Scheme != "https") {
+		return nil, nil
+	}
+	// Never follow a canonical link to a domain that could not have been requested directly.
+	authorized, err := q.IsAuthorized(ctx, canonical.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, nil
+	}
+	return canonical, nil
+}
@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValidateHexColor parses an opaque color provided by the user in the “RRGGBB” or “RGB” notation, with
+// or without a leading “#”.
+func ValidateHexColor(hex string) (color.RGBA, error) {
+	s := strings.TrimPrefix(hex, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, errors.New("invalid color: " + hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, errors.New("invalid color: " + hex)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two opaque colors, from 1 (for identical colors)
+// to 21 (for black on white).
+func ContrastRatio(a, b color.RGBA) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// relativeLuminance is defined by WCAG 2: https://www.w3.org/TR/WCAG21/#dfn-relative-luminance
+func relativeLuminance(c color.RGBA) float64 {
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B)
+}
+
+// IsDarker returns true if a is darker than b, i.e. has a lower relative luminance.
+func IsDarker(a, b color.RGBA) bool {
+	return relativeLuminance(a) < relativeLuminance(b)
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestValidateHexColor(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{"000000", color.RGBA{0, 0, 0, 0xff}, false},
+		{"#1a2B3c", color.RGBA{0x1a, 0x2b, 0x3c, 0xff}, false},
+		{"fff", color.RGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#f0a", color.RGBA{0xff, 0x00, 0xaa, 0xff}, false},
+		{"", color.RGBA{}, true},
+		{"12345", color.RGBA{}, true},
+		{"gggggg", color.RGBA{}, true},
+		{"#ff000080", color.RGBA{}, true},
+		{"red", color.RGBA{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ValidateHexColor(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateHexColor(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ValidateHexColor(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	gray := color.RGBA{0x77, 0x77, 0x77, 0xff}
+
+	if got := ContrastRatio(black, white); math.Abs(got-21) > 0.01 {
+		t.Errorf("ContrastRatio(black, white) = %v, want 21", got)
+	}
+	if got := ContrastRatio(white, black); math.Abs(got-21) > 0.01 {
+		t.Errorf("ContrastRatio(white, black) = %v, want 21", got)
+	}
+	if got := ContrastRatio(gray, gray); got != 1 {
+		t.Errorf("ContrastRatio(gray, gray) = %v, want 1", got)
+	}
+	if got := ContrastRatio(gray, white); got < 4.4 || got > 4.6 {
+		t.Errorf("ContrastRatio(gray, white) = %v, want ~4.5", got)
+	}
+	if !IsDarker(black, white) || IsDarker(white, black) {
+		t.Error("IsDarker() is inconsistent with luminance")
+	}
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// ValidateLocale validates a BCP 47 language tag provided by the user, such as “en-us”, and returns it in
+// canonical form, such as “en-US”.
+func ValidateLocale(lang string) (string, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", errors.New("invalid lang: " + lang)
+	}
+	return tag.String(), nil
+}
+
+// ValidateTimezone validates an IANA timezone ID provided by the user, such as “Europe/Zurich”.
+func ValidateTimezone(tz string) (string, error) {
+	// “Local” is valid for Go, but means nothing to Chrome.
+	if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+		return "", errors.New("invalid tz: " + tz)
+	}
+	return tz, nil
+}
+
+// ValidateColorScheme validates a color scheme provided by the user: “light” or “dark”.
+func ValidateColorScheme(scheme string) (string, error) {
+	switch scheme {
+	case "light", "dark":
+		return scheme, nil
+	}
+	return "", errors.New("invalid scheme: " + scheme)
+}
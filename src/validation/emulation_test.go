@@ -0,0 +1,51 @@
+package validation
+
+import "testing"
+
+func TestValidateLocale(t *testing.T) {
+	tests := []struct {
+		lang    string
+		want    string
+		wantErr bool
+	}{
+		{"en", "en", false},
+		{"en-us", "en-US", false},
+		{"pt_BR", "pt-BR", false},
+		{"zh-Hant-TW", "zh-Hant-TW", false},
+		{"", "", true},
+		{"not a locale", "", true},
+		{"en-US;q=0.9", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ValidateLocale(tt.lang)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ValidateLocale(%q) = %q, %v; want %q, error: %v", tt.lang, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	for _, tz := range []string{"UTC", "Europe/Zurich", "America/Los_Angeles"} {
+		if got, err := ValidateTimezone(tz); err != nil || got != tz {
+			t.Errorf("ValidateTimezone(%q) = %q, %v", tz, got, err)
+		}
+	}
+	for _, tz := range []string{"", "Local", "Mars/Olympus_Mons", "../../etc/passwd"} {
+		if _, err := ValidateTimezone(tz); err == nil {
+			t.Errorf("ValidateTimezone(%q) should fail", tz)
+		}
+	}
+}
+
+func TestValidateColorScheme(t *testing.T) {
+	for _, scheme := range []string{"light", "dark"} {
+		if _, err := ValidateColorScheme(scheme); err != nil {
+			t.Errorf("ValidateColorScheme(%q) error = %v", scheme, err)
+		}
+	}
+	for _, scheme := range []string{"", "Dark", "no-preference"} {
+		if _, err := ValidateColorScheme(scheme); err == nil {
+			t.Errorf("ValidateColorScheme(%q) should fail", scheme)
+		}
+	}
+}
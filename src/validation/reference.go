@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MatchesReference returns true if any of the image URLs referenced by a page (see
+// [core.FetchImageReferences]) requests exactly the same image as a request to host & path with params:
+// the target URL (“url”) must be the same after canonicalization, and all other parameters identical.
+// Schemes are not compared, since Butterfly is often served over HTTPS by a reverse proxy.
+func MatchesReference(references []string, host, path string, params url.Values) bool {
+	for _, reference := range references {
+		u, err := url.Parse(reference)
+		if err != nil || !strings.EqualFold(u.Host, host) || u.Path != path {
+			continue
+		}
+		if sameParams(u.Query(), params) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameParams(a, b url.Values) bool {
+	for key := range a {
+		if _, ok := b[key]; !ok && a.Get(key) != "" {
+			return false
+		}
+	}
+	for key := range b {
+		if key == "url" {
+			continue
+		}
+		if a.Get(key) != b.Get(key) {
+			return false
+		}
+	}
+	want, err := Canonicalize(b.Get("url"))
+	if err != nil {
+		return false
+	}
+	got, err := Canonicalize(a.Get("url"))
+	return err == nil && got.String() == want.String()
+}
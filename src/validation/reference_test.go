@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMatchesReference(t *testing.T) {
+	params := url.Values{"url": {"https://example.com/blog/post"}, "sel": {"#card"}}
+	tests := []struct {
+		name      string
+		reference string
+		want      bool
+	}{
+		{"exact", "https://butterfly.example.com/link-previews/v1?url=https://example.com/blog/post&sel=%23card", true},
+		{"canonicalized url", "https://Butterfly.example.com/link-previews/v1?sel=%23card&url=example.com/blog/post", true},
+		{"other scheme", "http://butterfly.example.com/link-previews/v1?url=https://example.com/blog/post&sel=%23card", true},
+		{"other host", "https://evil.example.com/link-previews/v1?url=https://example.com/blog/post&sel=%23card", false},
+		{"other path", "https://butterfly.example.com/screenshots/v1?url=https://example.com/blog/post&sel=%23card", false},
+		{"other url", "https://butterfly.example.com/link-previews/v1?url=https://example.com/other&sel=%23card", false},
+		{"missing param", "https://butterfly.example.com/link-previews/v1?url=https://example.com/blog/post", false},
+		{"extra param", "https://butterfly.example.com/link-previews/v1?url=https://example.com/blog/post&sel=%23card&renderer=native", false},
+		{"empty extra param", "https://butterfly.example.com/link-previews/v1?url=https://example.com/blog/post&sel=%23card&budget=", true},
+		{"not a preview", "https://example.com/preview.png", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchesReference([]string{tt.reference}, "butterfly.example.com", "/link-previews/v1", params)
+			if got != tt.want {
+				t.Errorf("MatchesReference(%q) = %v, want %v", tt.reference, got, tt.want)
+			}
+		})
+	}
+
+	if MatchesReference(nil, "butterfly.example.com", "/link-previews/v1", params) {
+		t.Error("MatchesReference() = true for a page without any references")
+	}
+}
@@ -4,14 +4,27 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"slices"
 	"strings"
 
+	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/db"
 )
 
 // Canonicalize parses a user-provided URL string and returns a *url.URL.
-// It tries to fix missing schemes by defaulting to https://.
+// It tries to fix missing schemes by defaulting to https://, and then normalizes the URL according to
+// conf.Config.UrlCanonicalization, so that trivially-different URLs for the same page are treated alike.
 func Canonicalize(userUrl string) (*url.URL, error) {
+	u, err := parse(userUrl)
+	if err != nil {
+		return nil, err
+	}
+	normalize(u, conf.Config.UrlCanonicalization)
+	return u, nil
+}
+
+// parse parses a user-provided URL string, defaulting to https:// if it has no scheme.
+func parse(userUrl string) (*url.URL, error) {
 	if userUrl == "" {
 		return nil, errors.New("missing URL")
 	}
@@ -25,20 +38,95 @@ func Canonicalize(userUrl string) (*url.URL, error) {
 	return u, nil
 }
 
-// ValidateUrl validates a URL provided by the user, and returns a formatted URL as a string.
-func ValidateUrl(ctx context.Context, q *db.Queries, userUrl string) (validatedUrl, hostname string, err error) {
-	u, err := Canonicalize(userUrl)
+// normalize applies a canonicalization policy to u, in place.
+func normalize(u *url.URL, policy conf.UrlCanonicalization) {
+	if enabled(policy.LowercaseHost) {
+		u.Host = strings.ToLower(u.Host)
+	}
+	if enabled(policy.StripDefaultPorts) {
+		if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			u.Host = strings.TrimSuffix(u.Host, ":"+port)
+		}
+	}
+	if enabled(policy.StripFragments) {
+		u.Fragment, u.RawFragment = "", ""
+	}
+
+	// Query parameters are filtered & sorted without decoding them, so that their encoding is preserved.
+	var params []string
+	for param := range strings.SplitSeq(u.RawQuery, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if param != "" && !isTrackingParam(key, policy.TrackingParams) {
+			params = append(params, param)
+		}
+	}
+	if enabled(policy.SortQuery) {
+		slices.SortStableFunc(params, func(a, b string) int {
+			keyA, _, _ := strings.Cut(a, "=")
+			keyB, _, _ := strings.Cut(b, "=")
+			return strings.Compare(keyA, keyB)
+		})
+	}
+	u.RawQuery = strings.Join(params, "&")
+	u.ForceQuery = false
+
+	switch policy.TrailingSlash {
+	case "strip":
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	case "add":
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+			if u.RawPath != "" {
+				u.RawPath += "/"
+			}
+		}
+	}
+}
+
+// isTrackingParam returns true if key matches any of patterns; a trailing “*” matches any suffix.
+func isTrackingParam(key string, patterns []string) bool {
+	if decoded, err := url.QueryUnescape(key); err == nil {
+		key = decoded
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(key, prefix) {
+			return true
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func enabled(setting *bool) bool {
+	return setting == nil || *setting
+}
+
+// ValidateUrl validates a URL provided by the user. It returns its canonical form, which identifies the page
+// in caches & the database, along with the URL as requested, with only a missing scheme added, which is the
+// one to fetch or encode: canonicalization may remove parts of a URL that matter to some servers & apps.
+func ValidateUrl(ctx context.Context, q *db.Queries, userUrl string) (canonicalUrl, requestedUrl, hostname string, err error) {
+	requested, err := parse(userUrl)
 	if err != nil {
-		return "", "", errors.New("invalid URL")
+		return "", "", "", errors.New("invalid URL")
 	}
-	authorized, err := IsAuthorized(ctx, q, u)
+	u := *requested
+	normalize(&u, conf.Config.UrlCanonicalization)
+	authorized, err := IsAuthorized(ctx, q, &u)
 	if err != nil {
-		return "", u.Hostname(), err
+		return "", "", u.Hostname(), err
 	}
 	if !authorized {
-		return "", u.Hostname(), errors.New("domain " + u.Hostname() + " not authorized")
+		return "", "", u.Hostname(), errors.New("domain " + u.Hostname() + " not authorized")
+	}
+	if conf.Config.UrlCanonicalization.FollowCanonical {
+		// The page itself declares its canonical URL, so it is also the one to fetch.
+		if canonical := followCanonicalLink(ctx, q, &u); canonical != &u {
+			return canonical.String(), canonical.String(), canonical.Hostname(), nil
+		}
 	}
-	return u.String(), u.Hostname(), nil
+	return u.String(), requested.String(), u.Hostname(), nil
 }
 
 // IsAuthorized returns true if the given URL’s domain is in the list of authorized domains.
@@ -2,9 +2,11 @@ package validation
 
 import (
 	"context"
+	"net/url"
 	"os"
 	"testing"
 
+	"butterfly.chimbori.dev/conf"
 	"butterfly.chimbori.dev/core"
 	"butterfly.chimbori.dev/db"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -127,7 +129,7 @@ func TestValidateUrl_RejectsUnauthorizedDomains(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url, hostname, err := ValidateUrl(ctx, queries, tt.url)
+			url, _, hostname, err := ValidateUrl(ctx, queries, tt.url)
 
 			if hostname != tt.hostname {
 				t.Errorf("Expected valid hostname, even for unauthorized domain, but got [%s]", hostname)
@@ -158,7 +160,7 @@ func TestValidateUrl_EmptyUrl(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	url, hostname, err := ValidateUrl(ctx, queries, "")
+	url, _, hostname, err := ValidateUrl(ctx, queries, "")
 	if err == nil {
 		t.Error("Expected error for empty URL")
 	}
@@ -178,7 +180,7 @@ func TestValidateUrl_InvalidUrl(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	url, hostname, err := ValidateUrl(ctx, queries, "ht!tp://invalid url with spaces")
+	url, _, hostname, err := ValidateUrl(ctx, queries, "ht!tp://invalid url with spaces")
 	if err == nil {
 		t.Error("Expected error for invalid URL")
 	}
@@ -206,14 +208,123 @@ func TestValidateUrl_AddsHttpsPrefix(t *testing.T) {
 		t.Fatalf("Failed to insert test domain: %v", err)
 	}
 
-	url, hostname, err := ValidateUrl(ctx, queries, "chimbori.com/page")
+	url, requestedUrl, hostname, err := ValidateUrl(ctx, queries, "chimbori.com/page")
 	if err != nil {
 		t.Errorf("Expected no error, but got: %s", err.Error())
 	}
 	if url != "https://chimbori.com/page" {
 		t.Errorf("Expected https:// prefix to be added, got: [%s]", url)
 	}
+	if requestedUrl != "https://chimbori.com/page" {
+		t.Errorf("Expected https:// prefix to be added to requested URL, got: [%s]", requestedUrl)
+	}
 	if hostname != "chimbori.com" {
 		t.Errorf("Expected correct hostname for URL without https:// prefix, got: [%s]", hostname)
 	}
 }
+
+func TestValidateUrl_KeepsRequestedUrl(t *testing.T) {
+	pool, queries := setupTestDB(t)
+	defer pool.Close()
+
+	ctx := context.Background()
+	_, err := queries.UpsertDomain(ctx, db.UpsertDomainParams{
+		Domain:            "chimbori.com",
+		IncludeSubdomains: core.Ptr(true),
+		Authorized:        core.Ptr(true),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert test domain: %v", err)
+	}
+
+	conf.Config.UrlCanonicalization = conf.UrlCanonicalization{
+		TrackingParams: conf.DefaultTrackingParams,
+		StripFragments: core.Ptr(true),
+		TrailingSlash:  "strip",
+	}
+	defer func() { conf.Config.UrlCanonicalization = conf.UrlCanonicalization{} }()
+
+	url, requestedUrl, _, err := ValidateUrl(ctx, queries, "https://Chimbori.com/app/?utm_source=qr#/route")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %s", err.Error())
+	}
+	if url != "https://chimbori.com/app" {
+		t.Errorf("Expected canonical URL, got: [%s]", url)
+	}
+	if requestedUrl != "https://Chimbori.com/app/?utm_source=qr#/route" {
+		t.Errorf("Expected requested URL to be kept as-is, got: [%s]", requestedUrl)
+	}
+}
+
+func TestCanonicalize_DefaultPolicy(t *testing.T) {
+	conf.Config.UrlCanonicalization = conf.UrlCanonicalization{
+		StripFragments: core.Ptr(false),
+		TrackingParams: conf.DefaultTrackingParams,
+		TrailingSlash:  "keep",
+	}
+	defer func() { conf.Config.UrlCanonicalization = conf.UrlCanonicalization{} }()
+
+	want := "https://example.com/a"
+	for _, userUrl := range []string{
+		"example.com/a",
+		"https://EXAMPLE.com/a?utm_source=x",
+		"https://example.com:443/a",
+		"https://example.com/a?fbclid=123&utm_medium=social&gclid=456",
+	} {
+		u, err := Canonicalize(userUrl)
+		if err != nil {
+			t.Fatalf("Canonicalize(%q) error = %v", userUrl, err)
+		}
+		if u.String() != want {
+			t.Errorf("Canonicalize(%q) = %q, want %q", userUrl, u.String(), want)
+		}
+	}
+
+	// Fragments & trailing slashes can change which page is served, so they are kept by default.
+	for _, userUrl := range []string{"https://example.com/a/", "https://example.com/#/route"} {
+		u, err := Canonicalize(userUrl)
+		if err != nil {
+			t.Fatalf("Canonicalize(%q) error = %v", userUrl, err)
+		}
+		if u.String() != userUrl {
+			t.Errorf("Canonicalize(%q) = %q, want unchanged", userUrl, u.String())
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		policy conf.UrlCanonicalization
+		want   string
+	}{
+		{"sorts query", "https://example.com/?b=2&a=1&a=0", conf.UrlCanonicalization{}, "https://example.com/?a=1&a=0&b=2"},
+		{"keeps query order", "https://example.com/?b=2&a=1", conf.UrlCanonicalization{SortQuery: core.Ptr(false)}, "https://example.com/?b=2&a=1"},
+		{"keeps encoding", "https://example.com/?q=a%20b&utm_id=1", conf.UrlCanonicalization{TrackingParams: []string{"utm_*"}}, "https://example.com/?q=a%20b"},
+		{"custom tracking param", "https://example.com/?ref=hn&id=1", conf.UrlCanonicalization{TrackingParams: []string{"ref"}}, "https://example.com/?id=1"},
+		{"drops empty query", "https://example.com/a?", conf.UrlCanonicalization{}, "https://example.com/a"},
+		{"keeps other ports", "http://example.com:8080/", conf.UrlCanonicalization{}, "http://example.com:8080/"},
+		{"strips http port", "http://example.com:80/", conf.UrlCanonicalization{}, "http://example.com/"},
+		{"keeps https port on http", "http://example.com:443/", conf.UrlCanonicalization{}, "http://example.com:443/"},
+		{"keeps host case", "https://Example.com/", conf.UrlCanonicalization{LowercaseHost: core.Ptr(false)}, "https://Example.com/"},
+		{"keeps fragment", "https://example.com/#top", conf.UrlCanonicalization{StripFragments: core.Ptr(false)}, "https://example.com/#top"},
+		{"strips root slash", "https://example.com/", conf.UrlCanonicalization{TrailingSlash: "strip"}, "https://example.com"},
+		{"strips trailing slashes", "https://example.com/a//", conf.UrlCanonicalization{TrailingSlash: "strip"}, "https://example.com/a"},
+		{"adds trailing slash", "https://example.com/a", conf.UrlCanonicalization{TrailingSlash: "add"}, "https://example.com/a/"},
+		{"adds root slash", "https://example.com", conf.UrlCanonicalization{TrailingSlash: "add"}, "https://example.com/"},
+		{"keeps trailing slash", "https://example.com/a/", conf.UrlCanonicalization{TrailingSlash: "keep"}, "https://example.com/a/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalize(u, tt.policy)
+			if u.String() != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.url, u.String(), tt.want)
+			}
+		})
+	}
+}